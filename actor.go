@@ -0,0 +1,113 @@
+package main
+
+import (
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// actorIdle is how long a game's goroutine waits for a command before
+// exiting; the next command starts it again.
+const actorIdle = time.Minute
+
+// gameActor runs a game's commands one at a time on a goroutine of its
+// own, so everything reading or changing the game is serialized without a
+// lock, and tells the game's subscribers of each update. The zero value is
+// ready to use; the goroutine is started by the first command.
+type gameActor struct {
+	mu      sync.Mutex // guards running and pending
+	running bool
+	pending int // commands waiting to be received
+	cmds    chan func()
+	subs    map[chan struct{}]bool // read and changed by commands only
+}
+
+// do runs fn on the game's goroutine and waits for it to finish. fn may
+// read and change the game freely, but must not call do itself. A panic of
+// fn is raised again by do, as a commandPanic, leaving the goroutine
+// running the game's other commands.
+func (g *Game) do(fn func()) {
+	a := &g.actor
+	done := make(chan struct{})
+	a.mu.Lock()
+	if a.cmds == nil {
+		a.cmds = make(chan func())
+	}
+	if !a.running {
+		a.running = true
+		go a.run()
+	}
+	a.pending++
+	a.mu.Unlock()
+
+	var panicked *commandPanic
+	a.cmds <- func() {
+		defer close(done)
+		defer func() {
+			if p := recover(); p != nil {
+				panicked = &commandPanic{value: p, stack: debug.Stack()}
+			}
+		}()
+		fn()
+	}
+	<-done
+	if panicked != nil {
+		panic(*panicked)
+	}
+}
+
+// run receives commands until none came for actorIdle.
+func (a *gameActor) run() {
+	idle := time.NewTimer(actorIdle)
+	defer idle.Stop()
+	for {
+		select {
+		case cmd := <-a.cmds:
+			a.mu.Lock()
+			a.pending--
+			a.mu.Unlock()
+			cmd()
+			idle.Reset(actorIdle)
+		case <-idle.C:
+			a.mu.Lock()
+			if a.pending == 0 {
+				a.running = false
+				a.mu.Unlock()
+				return
+			}
+			a.mu.Unlock()
+			idle.Reset(actorIdle)
+		}
+	}
+}
+
+// update runs fn as do does, then tells the subscribers the game may have
+// changed.
+func (g *Game) update(fn func()) {
+	g.do(func() {
+		fn()
+		for ch := range g.actor.subs {
+			// A subscriber yet to catch up on the last update is not
+			// told again
+			select {
+			case ch <- struct{}{}:
+			default:
+			}
+		}
+	})
+}
+
+// subscribe returns a channel receiving a value after updates of the game,
+// and a function ending the subscription.
+func (g *Game) subscribe() (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+	g.do(func() {
+		if g.actor.subs == nil {
+			g.actor.subs = make(map[chan struct{}]bool)
+		}
+		g.actor.subs[ch] = true
+	})
+	return ch, func() {
+		g.do(func() { delete(g.actor.subs, ch) })
+	}
+}
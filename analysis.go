@@ -0,0 +1,134 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/rigurd/chess"
+)
+
+const (
+	// defaultAnalysisTime is how long a position is analysed for when the
+	// request does not say.
+	defaultAnalysisTime = time.Second
+	// maxAnalysisTime bounds /api/v1/games/{id}/analysis so a request
+	// cannot tie up the engine.
+	maxAnalysisTime = 5 * time.Second
+	// maxAnalysisDepth bounds the built-in search, which stops sooner on
+	// its time.
+	maxAnalysisDepth = 20
+)
+
+// builtinEngine names the built-in search in an analysis.
+const builtinEngine = "built-in"
+
+// apiAnalysis is an analysis of a position as the API returns it. Scores
+// are for the side to move.
+type apiAnalysis struct {
+	Engine   string   `json:"engine"`
+	Depth    int      `json:"depth"`
+	Score    *int     `json:"score,omitempty"` // in centipawns
+	Mate     *int     `json:"mate,omitempty"`  // moves to mate, negative when being mated
+	BestMove string   `json:"best_move"`       // in UCI
+	SAN      string   `json:"san"`             // the best move in SAN
+	PV       []string `json:"pv"`              // the line expected, in UCI, starting with the best move
+}
+
+// handleAPIAnalysis analyses the game's position for movetime=
+// milliseconds with the engine if one is configured and answers, and with
+// the built-in search otherwise.
+func (s *Server) handleAPIAnalysis(w http.ResponseWriter, r *http.Request, g *Game) {
+	if !requireAPIMethod(w, r, http.MethodGet) {
+		return
+	}
+	if !s.requireRate(w, r, rateMoves) {
+		return
+	}
+	movetime := defaultAnalysisTime
+	if v := r.FormValue("movetime"); v != "" {
+		ms, err := strconv.Atoi(v)
+		if err != nil || ms < 1 || time.Duration(ms)*time.Millisecond > maxAnalysisTime {
+			writeAPIError(w, http.StatusBadRequest, codeBadRequest, "movetime must be between 1 and "+strconv.FormatInt(maxAnalysisTime.Milliseconds(), 10))
+			return
+		}
+		movetime = time.Duration(ms) * time.Millisecond
+	}
+
+	var pos *chess.GameState
+	var line uciPosition
+	var variant chess.Variant
+	var over bool
+	g.do(func() {
+		pos = chess.CopyPosition(&g.GameState)
+		line = uciPositionOf(g)
+		variant = g.Variant
+		over = g.Result != chess.Ongoing
+	})
+	switch {
+	case over:
+		writeAPIError(w, http.StatusConflict, codeGameOver, chess.ErrGameOver.Error())
+		return
+	case variant != chess.Standard:
+		writeAPIError(w, http.StatusConflict, codeConflict, "only standard chess can be analysed")
+		return
+	}
+
+	if s.games.engine != nil {
+		a, err := engineAnalysis(s.games.engine, g.ID, pos, line, movetime)
+		if err == nil {
+			writeJSON(w, a)
+			return
+		}
+		log.Printf("engine analysis of game %s: %v; searching instead", g.ID, err)
+	}
+	found, ok := chess.Search(pos, chess.SearchLimits{Depth: maxAnalysisDepth, Time: movetime})
+	if !ok {
+		writeAPIError(w, http.StatusConflict, codeGameOver, chess.ErrGameOver.Error())
+		return
+	}
+	a := apiAnalysis{
+		Engine:   builtinEngine,
+		Depth:    found.Depth,
+		BestMove: chess.UCI(found.Move),
+		SAN:      chess.SANWithSuffix(pos, found.Move),
+		PV:       []string{chess.UCI(found.Move)},
+	}
+	if plies := chess.MateScore - max(found.Score, -found.Score); plies <= found.Depth {
+		mate := (plies + 1) / 2
+		if found.Score < 0 {
+			mate = -mate
+		}
+		a.Mate = &mate
+	} else {
+		a.Score = &found.Score
+	}
+	writeJSON(w, a)
+}
+
+// engineAnalysis returns the engine's analysis of pos, the position of the
+// game given by line, searched for movetime.
+func engineAnalysis(e *engine, game string, pos *chess.GameState, line uciPosition, movetime time.Duration) (apiAnalysis, error) {
+	res, name, err := e.analyse(game, line, movetime)
+	if err != nil {
+		return apiAnalysis{}, err
+	}
+	best, err := chess.ParseUCI(pos, res.bestMove)
+	if err != nil {
+		return apiAnalysis{}, err
+	}
+	a := apiAnalysis{
+		Engine:   name,
+		Depth:    res.depth,
+		Score:    res.cp,
+		Mate:     res.mate,
+		BestMove: res.bestMove,
+		SAN:      chess.SANWithSuffix(pos, best),
+		PV:       res.pv,
+	}
+	if len(a.PV) == 0 || a.PV[0] != a.BestMove {
+		a.PV = []string{a.BestMove}
+	}
+	return a, nil
+}
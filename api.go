@@ -0,0 +1,488 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"math/rand/v2"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rigurd/chess"
+)
+
+// maxAPIBody bounds the size of a request body sent to the JSON API.
+const maxAPIBody = 1 << 20
+
+// The JSON API lets scripts and other non-browser clients play without the
+// HTML board. As in the browser, a client is identified by its session
+// cookie, so it must keep the cookies it is sent to stay seated.
+
+// apiGame is the state of a game as the JSON API returns it.
+type apiGame struct {
+	ID        string           `json:"id"`
+	FEN       string           `json:"fen"`
+	Variant   chess.Variant    `json:"variant"`
+	Turn      chess.PieceColor `json:"turn"`
+	InCheck   bool             `json:"in_check"`
+	Result    chess.EndState   `json:"result"`
+	EndReason string           `json:"end_reason,omitempty"`
+	DrawOffer chess.PieceColor `json:"draw_offer,omitempty"`
+	Ply       int              `json:"ply"` // moves played so far
+	LastMove  *apiMove         `json:"last_move,omitempty"`
+	You       chess.PieceColor `json:"you,omitempty"`      // side the client plays, if any
+	Computer  chess.PieceColor `json:"computer,omitempty"` // side the computer plays, if any
+	Level     string           `json:"level,omitempty"`    // difficulty the computer plays at
+}
+
+// apiMove is a move played in a game.
+type apiMove struct {
+	Ply   int              `json:"ply"`
+	Color chess.PieceColor `json:"color"`
+	SAN   string           `json:"san"`
+	UCI   string           `json:"uci"`
+	Time  time.Time        `json:"time"`
+}
+
+// apiError is the body of every error response of the JSON APIs: a code
+// for programs to act on, a message for people, and details depending on
+// the code.
+type apiError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Details any    `json:"details,omitempty"`
+}
+
+// The codes of apiError. A client may rely on them, unlike on messages.
+const (
+	codeBadRequest           = "bad_request"
+	codeUnauthorized         = "unauthorized"
+	codeForbidden            = "forbidden"
+	codeNotFound             = "not_found"
+	codeMethodNotAllowed     = "method_not_allowed"
+	codeNotAcceptable        = "not_acceptable"
+	codeConflict             = "conflict"
+	codeUnsupportedMediaType = "unsupported_media_type"
+	codeUnavailable          = "unavailable"
+	codeRateLimited          = "rate_limited"
+	codeInternal             = "internal_error"
+
+	codeGameNotFound = "game_not_found"
+	codeNotAPlayer   = "not_a_player"  // the client plays neither side
+	codeNotYourTurn  = "not_your_turn" // the client plays the side not to move
+	codeGameOver     = "game_over"
+	codeTouchMove    = "touch_move"   // another piece than the one touched was moved
+	codeIllegalMove  = "illegal_move" // details are a moveRejection
+)
+
+// moveRejection details why a move was illegal.
+type moveRejection struct {
+	Move   string `json:"move"`
+	Reason string `json:"reason,omitempty"` // chess.MoveError code, if the move could be read
+}
+
+// newAPIGame returns the state of g as seen by session. It must be called
+// from a command of g's goroutine.
+func newAPIGame(g *Game, session string) apiGame {
+	ag := apiGame{
+		ID:        g.ID,
+		FEN:       g.FEN(),
+		Variant:   g.Variant,
+		Turn:      g.CurrentPlayer,
+		InCheck:   g.InCheck,
+		Result:    g.Result,
+		EndReason: g.EndReason,
+		DrawOffer: g.DrawOffer,
+		Ply:       len(g.History),
+	}
+	if n := len(g.History); n > 0 {
+		m := newAPIMove(n-1, g.History[n-1])
+		ag.LastMove = &m
+	}
+	ag.You, _ = g.sideOf(session)
+	if side, ok := g.computerSide(); ok {
+		ag.Computer, ag.Level = side, cmp.Or(g.Settings.ComputerLevel, defaultComputerLevel)
+	}
+	return ag
+}
+
+// newAPIMove returns rec, played at ply, as the JSON API returns it.
+func newAPIMove(ply int, rec chess.MoveRecord) apiMove {
+	return apiMove{Ply: ply, Color: rec.Color, SAN: rec.SAN, UCI: chess.UCI(rec.Move), Time: rec.Time}
+}
+
+// writeAPIError answers the request with status and an apiError giving
+// code and msg.
+func writeAPIError(w http.ResponseWriter, status int, code, msg string) {
+	writeAPIStatus(w, status, apiError{Code: code, Message: msg})
+}
+
+// errorWriter answers a request with an error, as writeAPIError does, or as
+// writeTextError does for the pages.
+type errorWriter func(w http.ResponseWriter, status int, code, msg string)
+
+// writeTextError answers the request with status and msg as plain text,
+// leaving the code out.
+func writeTextError(w http.ResponseWriter, status int, _, msg string) {
+	http.Error(w, msg, status)
+}
+
+// errorsFor returns how errors are answered to r: as apiErrors on the API
+// paths, as plain text on the pages.
+func errorsFor(r *http.Request) errorWriter {
+	if corsPath(r.URL.Path) {
+		return writeAPIError
+	}
+	return writeTextError
+}
+
+// writeAPIStatus answers the request with status and v as JSON.
+func writeAPIStatus(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// acceptsJSON reports whether the request's Accept header allows a JSON
+// response. A client sending none accepts anything.
+func acceptsJSON(r *http.Request) bool {
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return true
+	}
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		if q, err := strconv.ParseFloat(params["q"], 64); err == nil && q == 0 {
+			continue
+		}
+		switch mediaType {
+		case "application/json", "application/*", "*/*":
+			return true
+		}
+	}
+	return false
+}
+
+// requireAPIMethod answers 405 Method Not Allowed unless the request uses
+// one of methods, and 406 Not Acceptable unless it accepts JSON.
+func requireAPIMethod(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	if !acceptsJSON(r) {
+		writeAPIError(w, http.StatusNotAcceptable, codeNotAcceptable, "this API only returns application/json")
+		return false
+	}
+	for _, m := range methods {
+		if r.Method == m {
+			return true
+		}
+	}
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	writeAPIError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method not allowed")
+	return false
+}
+
+// apiFields reads the fields of a request body sent to the JSON API, either
+// a JSON object of strings or a form. A bad body is answered here,
+// returning ok false.
+func apiFields(w http.ResponseWriter, r *http.Request) (fields map[string]string, ok bool) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxAPIBody)
+	fields = make(map[string]string)
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/json":
+		err := json.NewDecoder(r.Body).Decode(&fields)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, codeBadRequest, "invalid JSON body: "+err.Error())
+			return nil, false
+		}
+	case "", "application/x-www-form-urlencoded", "multipart/form-data":
+		if err := r.ParseMultipartForm(maxAPIBody); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+			writeAPIError(w, http.StatusBadRequest, codeBadRequest, "invalid form: "+err.Error())
+			return nil, false
+		}
+		for k := range r.Form {
+			fields[k] = r.Form.Get(k)
+		}
+	default:
+		writeAPIError(w, http.StatusUnsupportedMediaType, codeUnsupportedMediaType, "send application/json or a form")
+		return nil, false
+	}
+	return fields, true
+}
+
+// apiGameHandler adapts a JSON API handler of a single game, as
+// gameHandler does for the pages, answering in JSON when there is no such
+// game.
+func (s *Server) apiGameHandler(h func(http.ResponseWriter, *http.Request, *Game)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		g, ok := s.games.Get(id)
+		if !ok {
+			writeAPIError(w, http.StatusNotFound, codeGameNotFound, "unknown game "+id)
+			return
+		}
+		h(w, r, g)
+		s.games.Save(g)
+	}
+}
+
+// handleAPICreateGame starts a new game, from the position given as fen, or
+// from the start of the variant given, and returns it with 201 Created.
+// With opponent "computer" the client plays the computer, on the side
+// color names, at the difficulty level names.
+func (s *Server) handleAPICreateGame(w http.ResponseWriter, r *http.Request) {
+	if !requireAPIMethod(w, r, http.MethodPost) {
+		return
+	}
+	fields, ok := apiFields(w, r)
+	if !ok {
+		return
+	}
+	start, err := parseGameStart(fields["fen"], fields["variant"])
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+	computer, err := parseOpponent(fields["opponent"], fields["color"], fields["level"], start.variantOf())
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+	if !s.requireRate(w, r, rateCreates) {
+		return
+	}
+
+	g := s.games.Create()
+	var ag apiGame
+	g.update(func() {
+		start.apply(g)
+		if computer != nil {
+			computer.seat(g, sessionOf(r))
+		}
+		s.audit(r, g, "create", g.FEN())
+		ag = newAPIGame(g, sessionOf(r))
+	})
+	s.games.Save(g)
+	w.Header().Set("Location", "/api/v1/games/"+g.ID)
+	writeAPIStatus(w, http.StatusCreated, ag)
+}
+
+// gameStart is where a game created through an API starts: from pos when
+// it is set, otherwise from the start of variant.
+type gameStart struct {
+	pos     *chess.GameState
+	variant chess.Variant
+}
+
+// parseGameStart reads the start of a game from a FEN or a variant name,
+// at most one of which may be given.
+func parseGameStart(fen, variant string) (gameStart, error) {
+	switch {
+	case fen != "" && variant != "":
+		return gameStart{}, errors.New("give either fen or variant, not both")
+	case fen != "":
+		pos, err := chess.ParseFEN(fen)
+		if err != nil {
+			return gameStart{}, errors.New("invalid FEN: " + err.Error())
+		}
+		return gameStart{pos: pos}, nil
+	case variant != "" && chess.Variant(variant) != chess.ParseVariant(variant):
+		return gameStart{}, errors.New("unknown variant " + variant)
+	}
+	return gameStart{variant: chess.ParseVariant(variant)}, nil
+}
+
+// parseColorChoice reads the side a player chose to play: "white",
+// "black", or "random" or "" for either.
+func parseColorChoice(s string) (chess.PieceColor, error) {
+	switch s {
+	case "white":
+		return chess.White, nil
+	case "black":
+		return chess.Black, nil
+	case "", "random":
+		if rand.IntN(2) == 0 {
+			return chess.Black, nil
+		}
+		return chess.White, nil
+	}
+	return "", errors.New(`color must be "white", "black" or "random"`)
+}
+
+// variantOf returns the variant of the game started at st.
+func (st gameStart) variantOf() chess.Variant {
+	if st.pos != nil {
+		return st.pos.Variant
+	}
+	return st.variant
+}
+
+// apply sets the new game g up at the start. It must be called from a
+// command of g's goroutine.
+func (st gameStart) apply(g *Game) {
+	if st.pos != nil {
+		g.setPosition(st.pos)
+	} else {
+		g.Variant = st.variant
+	}
+}
+
+// handleAPIGame returns the state of the game.
+func (s *Server) handleAPIGame(w http.ResponseWriter, r *http.Request, g *Game) {
+	if !requireAPIMethod(w, r, http.MethodGet) {
+		return
+	}
+	var ag apiGame
+	g.do(func() { ag = newAPIGame(g, sessionOf(r)) })
+	writeJSON(w, ag)
+}
+
+// handleAPIMoves returns the moves played in the game on GET, and on POST
+// plays the move given as move, in SAN or UCI, returning the game after it.
+func (s *Server) handleAPIMoves(w http.ResponseWriter, r *http.Request, g *Game) {
+	if !requireAPIMethod(w, r, http.MethodGet, http.MethodPost) {
+		return
+	}
+	if r.Method == http.MethodGet {
+		var moves []apiMove
+		g.do(func() {
+			moves = make([]apiMove, len(g.History))
+			for ply, rec := range g.History {
+				moves[ply] = newAPIMove(ply, rec)
+			}
+		})
+		writeJSON(w, moves)
+		return
+	}
+
+	fields, ok := apiFields(w, r)
+	if !ok {
+		return
+	}
+	text := fields["move"]
+	if text == "" {
+		writeAPIError(w, http.StatusBadRequest, codeBadRequest, "move is required")
+		return
+	}
+	s.apiPlay(w, r, g, text)
+}
+
+// apiPlay plays the move text, in SAN or UCI, for the request's session and
+// answers with the game after it, or with why the move was refused.
+func (s *Server) apiPlay(w http.ResponseWriter, r *http.Request, g *Game, text string) {
+	if !s.requireRate(w, r, rateMoves) {
+		return
+	}
+	g.update(func() {
+		session := sessionOf(r)
+		if g.Result != chess.Ongoing {
+			writeAPIError(w, http.StatusConflict, codeGameOver, chess.ErrGameOver.Error())
+			return
+		}
+		if !g.claimSide(session, g.CurrentPlayer) {
+			if _, seated := g.sideOf(session); seated {
+				writeAPIError(w, http.StatusForbidden, codeNotYourTurn, "It is "+string(g.CurrentPlayer)+"'s turn")
+			} else {
+				writeAPIError(w, http.StatusForbidden, codeNotAPlayer, "You are not playing "+string(g.CurrentPlayer)+" in this game")
+			}
+			return
+		}
+		plies := len(g.History)
+		err := playText(g, text)
+		s.auditMoves(r, g, plies)
+		var moveErr chess.MoveError
+		switch {
+		case err == nil:
+			writeJSON(w, newAPIGame(g, session))
+		case errors.Is(err, errMoveNotLogged):
+			writeAPIError(w, http.StatusServiceUnavailable, codeUnavailable, err.Error())
+		case errors.Is(err, chess.ErrGameOver):
+			writeAPIError(w, http.StatusConflict, codeGameOver, err.Error())
+		case errors.Is(err, errTouchMove):
+			writeAPIError(w, http.StatusConflict, codeTouchMove, err.Error())
+		default:
+			errors.As(err, &moveErr)
+			writeAPIStatus(w, http.StatusUnprocessableEntity, apiError{
+				Code:    codeIllegalMove,
+				Message: err.Error(),
+				Details: moveRejection{Move: text, Reason: string(moveErr)},
+			})
+		}
+	})
+}
+
+// requireAPIPlayer answers 403 Forbidden, as requirePlayer does for the
+// pages, unless the request's session plays either side of g. It must be
+// called from a command of g's goroutine.
+func requireAPIPlayer(w http.ResponseWriter, r *http.Request, g *Game) bool {
+	if _, seated := g.sideOf(sessionOf(r)); !seated {
+		writeAPIError(w, http.StatusForbidden, codeNotAPlayer, "Only the players can do that")
+		return false
+	}
+	return true
+}
+
+// handleAPILegalMoves returns the legal moves of the player to move, or
+// only those of the piece on square= when it is given, with the drops of
+// Crazyhouse.
+func (s *Server) handleAPILegalMoves(w http.ResponseWriter, r *http.Request, g *Game) {
+	if !requireAPIMethod(w, r, http.MethodGet) {
+		return
+	}
+	var from *chess.Square
+	if name := r.FormValue("square"); name != "" {
+		sq, err := chess.ParseSquareName(name)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, codeBadRequest, "invalid square "+name)
+			return
+		}
+		from = &sq
+	}
+
+	type legalMove struct {
+		SAN string `json:"san"`
+		UCI string `json:"uci"`
+	}
+	moves := []legalMove{}
+	g.do(func() {
+		if g.Result != chess.Ongoing {
+			return
+		}
+		for _, m := range legalMoves(&g.GameState) {
+			if from != nil && (m.Drop != chess.Empty || m.From != *from) {
+				continue
+			}
+			moves = append(moves, legalMove{SAN: chess.SANWithSuffix(&g.GameState, m), UCI: chess.UCI(m)})
+		}
+	})
+	writeJSON(w, moves)
+}
+
+// legalMoves returns every legal move of the player to move in g, drops
+// included.
+func legalMoves(g *chess.GameState) []chess.Move {
+	moves := chess.GenerateAllLegalMoves(g)
+	if g.Variant != chess.Crazyhouse {
+		return moves
+	}
+	for _, letter := range reserveOrder {
+		p := chess.PieceFromLetter(letter, g.CurrentPlayer)
+		if g.Reserves[g.CurrentPlayer][p] == 0 {
+			continue
+		}
+		for row := 0; row < 8; row++ {
+			for col := 0; col < 8; col++ {
+				to := chess.Square{Row: row, Col: col}
+				if chess.IsValidDrop(g, p, to) {
+					moves = append(moves, chess.Move{To: to, Drop: p})
+				}
+			}
+		}
+	}
+	return moves
+}
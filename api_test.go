@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"testing"
+)
+
+func TestHandleAPILegalMovesSAN(t *testing.T) {
+	s, ts := newTestServer(t)
+	g := newTestGame(t, s, "6k1/5ppp/8/8/8/8/8/R3K3 w Q - 0 1")
+	c := newTestClient(t, s, ts)
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"Kd1", "Kd2", "Ke2", "Kf1", "Kf2", "O-O-O", "Ra2", "Ra3", "Ra4", "Ra5", "Ra6", "Ra7", "Ra8#", "Rb1", "Rc1", "Rd1"}},
+		{"?square=a1", []string{"Ra2", "Ra3", "Ra4", "Ra5", "Ra6", "Ra7", "Ra8#", "Rb1", "Rc1", "Rd1"}},
+	}
+	for _, tt := range tests {
+		status, body := c.get("/api/v1/games/" + g.ID + "/legal-moves" + tt.query)
+		if status != http.StatusOK {
+			t.Fatalf("%q: status %d: %s", tt.query, status, body)
+		}
+		var moves []struct {
+			SAN string `json:"san"`
+			UCI string `json:"uci"`
+		}
+		if err := json.Unmarshal([]byte(body), &moves); err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, m := range moves {
+			got = append(got, m.SAN)
+		}
+		slices.Sort(got)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%q:\n got %v\nwant %v", tt.query, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// apiKeyPrefix starts every API key, telling it from the admin token
+	// in an Authorization header.
+	apiKeyPrefix = "rgd_"
+	// apiKeyPlayerPrefix is put before the ID of an API key to make the
+	// session of the client using it, on the HTTP API and over gRPC alike.
+	apiKeyPlayerPrefix = "key:"
+)
+
+// errAPIKeyNotFound is returned by a GameStore for an unknown API key.
+var errAPIKeyNotFound = errors.New("API key not found")
+
+// apiScope is what an API key lets its client do.
+type apiScope string
+
+const (
+	scopeRead apiScope = "read" // look at games and follow them
+	scopePlay apiScope = "play" // also start games, move and chat
+)
+
+// APIKey lets a bot or script use the APIs without a browser session. Only
+// a hash of the key itself is stored, so it is shown once, when issued.
+type APIKey struct {
+	ID      string    `json:"id"`
+	Name    string    `json:"name"` // who the key was issued to
+	Scope   apiScope  `json:"scope"`
+	Created time.Time `json:"created"`
+}
+
+// compareAPIKeys orders API keys oldest first.
+func compareAPIKeys(a, b APIKey) int {
+	return cmp.Or(a.Created.Compare(b.Created), strings.Compare(a.ID, b.ID))
+}
+
+// apiKeyKey is the context key of the request's API key.
+type apiKeyKey struct{}
+
+// newAPIKey returns a random, unguessable key to give a client.
+func newAPIKey() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return apiKeyPrefix + hex.EncodeToString(b)
+}
+
+// hashAPIKey returns the hash an API key is stored and looked up by.
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// bearerAPIKey returns the API key the request carries as
+// "Authorization: Bearer <key>", if any.
+func bearerAPIKey(r *http.Request) (string, bool) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || !strings.HasPrefix(token, apiKeyPrefix) {
+		return "", false
+	}
+	return token, true
+}
+
+// withAPIKey authenticates a request carrying an API key, attaching the key
+// and the key's own session to it in place of a browser session. An
+// unknown key, or a read-only key used to change anything, is answered
+// here, returning ok false.
+func (s *Server) withAPIKey(w http.ResponseWriter, r *http.Request, token string) (_ *http.Request, ok bool) {
+	key, err := s.games.store.APIKey(hashAPIKey(token))
+	if err != nil {
+		if !errors.Is(err, errAPIKeyNotFound) {
+			writeAPIError(w, http.StatusServiceUnavailable, codeUnavailable, "could not check the API key")
+			return nil, false
+		}
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		writeAPIError(w, http.StatusUnauthorized, codeUnauthorized, "invalid API key")
+		return nil, false
+	}
+	safe := r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions
+	// GraphQL takes queries by POST too, so its mutations check the scope
+	if key.Scope != scopePlay && !safe && r.URL.Path != "/graphql" {
+		writeAPIError(w, http.StatusForbidden, codeForbidden, "this API key is read-only")
+		return nil, false
+	}
+	ctx := context.WithValue(r.Context(), apiKeyKey{}, key)
+	ctx = context.WithValue(ctx, sessionKey{}, apiKeyPlayerPrefix+key.ID)
+	return r.WithContext(ctx), true
+}
+
+// canPlay reports whether the request may start games, move and chat: any
+// browser session may, but only API keys scoped to play.
+func canPlay(r *http.Request) bool {
+	key, ok := r.Context().Value(apiKeyKey{}).(APIKey)
+	return !ok || key.Scope == scopePlay
+}
+
+// handleAPIKeys lists the API keys issued on GET, and issues one to name=
+// with scope= read or play on POST, returning the key itself only then.
+func (s *Server) handleAPIKeys(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		writeAPIError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if !s.requireAdmin(w, r) {
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		keys, err := s.games.store.APIKeys()
+		if err != nil {
+			writeAPIError(w, http.StatusInternalServerError, codeInternal, "could not list the API keys: "+err.Error())
+			return
+		}
+		if keys == nil {
+			keys = []APIKey{}
+		}
+		writeJSON(w, keys)
+		return
+	}
+
+	name := strings.TrimSpace(r.FormValue("name"))
+	scope := apiScope(r.FormValue("scope"))
+	if name == "" {
+		writeAPIError(w, http.StatusBadRequest, codeBadRequest, "name is required")
+		return
+	}
+	if scope != scopeRead && scope != scopePlay {
+		writeAPIError(w, http.StatusBadRequest, codeBadRequest, `scope must be "read" or "play"`)
+		return
+	}
+	token := newAPIKey()
+	key := APIKey{ID: newGameID(), Name: name, Scope: scope, Created: time.Now().UTC()}
+	if err := s.games.store.CreateAPIKey(key, hashAPIKey(token)); err != nil {
+		writeAPIError(w, http.StatusInternalServerError, codeInternal, "could not issue the API key: "+err.Error())
+		return
+	}
+	writeAPIStatus(w, http.StatusCreated, struct {
+		APIKey
+		Key string `json:"key"`
+	}{key, token})
+}
+
+// handleRevokeAPIKey revokes the API key with the ID in the path, at once.
+func (s *Server) handleRevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		writeAPIError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if !s.requireAdmin(w, r) {
+		return
+	}
+	err := s.games.store.RevokeAPIKey(r.PathValue("id"))
+	switch {
+	case errors.Is(err, errAPIKeyNotFound):
+		writeAPIError(w, http.StatusNotFound, codeNotFound, "unknown API key")
+	case err != nil:
+		writeAPIError(w, http.StatusInternalServerError, codeInternal, "could not revoke the API key: "+err.Error())
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
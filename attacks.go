@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/rigurd/chess"
+)
+
+// handleEnPrise returns the current player's pieces that are hanging.
+func (s *Server) handleEnPrise(w http.ResponseWriter, r *http.Request, g *Game) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var squares []chess.Square
+	g.do(func() { squares = chess.EnPrise(&g.GameState, g.CurrentPlayer) })
+
+	writeJSON(w, map[string][]chess.Square{"squares": squares})
+}
+
+// writeJSON encodes v as the JSON response body.
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, "failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// handleDefends returns the squares defended by the piece on row/col.
+func (s *Server) handleDefends(w http.ResponseWriter, r *http.Request, g *Game) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	sq, ok := squareParam(w, r)
+	if !ok {
+		return
+	}
+
+	var squares []chess.Square
+	g.do(func() { squares = chess.DefendedBy(&g.GameState, sq) })
+
+	writeJSON(w, map[string][]chess.Square{"squares": squares})
+}
+
+// handleLegalDestinations returns the squares the piece on row/col can
+// legally move to, none if it is not the side to move's or the game is
+// over, so clients can highlight them or check a move before sending it.
+func (s *Server) handleLegalDestinations(w http.ResponseWriter, r *http.Request, g *Game) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	sq, ok := squareParam(w, r)
+	if !ok {
+		return
+	}
+
+	squares := []chess.Square{}
+	g.do(func() {
+		if g.Result == chess.Ongoing {
+			squares = append(squares, chess.GenerateLegalMoves(&g.GameState, sq)...)
+		}
+	})
+
+	writeJSON(w, map[string][]chess.Square{"squares": squares})
+}
+
+// squareParam reads the square given as row= and col=, each from 0 to 7.
+// A bad square is answered here, returning ok false.
+func squareParam(w http.ResponseWriter, r *http.Request) (sq chess.Square, ok bool) {
+	row, err1 := strconv.Atoi(r.FormValue("row"))
+	col, err2 := strconv.Atoi(r.FormValue("col"))
+	if err1 != nil || err2 != nil || row < 0 || row > 7 || col < 0 || col > 7 {
+		writeAPIError(w, http.StatusBadRequest, codeBadRequest, "row and col must be between 0 and 7")
+		return sq, false
+	}
+	return chess.Square{Row: row, Col: col}, true
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/rigurd/chess"
+)
+
+func TestHandleEnPrise(t *testing.T) {
+	s, ts := newTestServer(t)
+	g := newTestGame(t, s, "7k/8/2b5/8/4R3/8/8/4K3 w - - 0 1")
+	c := newTestClient(t, s, ts)
+
+	if status, _ := c.post(gamePath(g, "/api/enprise"), nil); status != http.StatusMethodNotAllowed {
+		t.Errorf("POST: status %d, want %d", status, http.StatusMethodNotAllowed)
+	}
+	status, body := c.get(gamePath(g, "/api/enprise"))
+	if status != http.StatusOK {
+		t.Fatalf("status %d: %s", status, body)
+	}
+	var got struct {
+		Squares []chess.Square `json:"squares"`
+	}
+	if err := json.Unmarshal([]byte(body), &got); err != nil {
+		t.Fatal(err)
+	}
+	if want := (chess.Square{Row: 4, Col: 4}); len(got.Squares) != 1 || got.Squares[0] != want {
+		t.Errorf("squares %v, want only the rook on e4, %v", got.Squares, want)
+	}
+}
+
+func TestHandleDefends(t *testing.T) {
+	s, ts := newTestServer(t)
+	g := newTestGame(t, s, "4k3/8/8/8/8/8/P3K3/R6n w - - 0 1")
+	c := newTestClient(t, s, ts)
+
+	if status, _ := c.get(gamePath(g, "/api/defends?row=8&col=0")); status != http.StatusBadRequest {
+		t.Errorf("row 8: status %d, want %d", status, http.StatusBadRequest)
+	}
+	status, body := c.get(gamePath(g, "/api/defends?row=7&col=0"))
+	if status != http.StatusOK {
+		t.Fatalf("status %d: %s", status, body)
+	}
+	var got struct {
+		Squares []chess.Square `json:"squares"`
+	}
+	if err := json.Unmarshal([]byte(body), &got); err != nil {
+		t.Fatal(err)
+	}
+	if want := (chess.Square{Row: 6, Col: 0}); len(got.Squares) != 1 || got.Squares[0] != want {
+		t.Errorf("squares %v, want only the pawn on a2, %v", got.Squares, want)
+	}
+}
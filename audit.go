@@ -0,0 +1,135 @@
+package main
+
+import (
+	"crypto/subtle"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxAuditEvents caps how many audit events one request to the audit log
+// returns.
+const maxAuditEvents = 1000
+
+// AuditEvent records who did what to a game and when, to investigate
+// disputes and abuse.
+type AuditEvent struct {
+	Time    time.Time `json:"time"`
+	Game    string    `json:"game"`
+	Action  string    `json:"action"`            // e.g. "move", "resign", "offer-draw"
+	Detail  string    `json:"detail,omitempty"`  // e.g. the move's SAN
+	Session string    `json:"session,omitempty"` // "" for the server itself, as when the reaper ends a game
+	IP      string    `json:"ip,omitempty"`
+}
+
+// auditFilter narrows a listing of audit events; empty fields match every
+// event.
+type auditFilter struct {
+	Game    string
+	Session string
+	IP      string
+	Action  string
+}
+
+// matches reports whether e passes the filter.
+func (f auditFilter) matches(e AuditEvent) bool {
+	return (f.Game == "" || e.Game == f.Game) &&
+		(f.Session == "" || e.Session == f.Session) &&
+		(f.IP == "" || e.IP == f.IP) &&
+		(f.Action == "" || e.Action == f.Action)
+}
+
+// clientIP returns the address the request came from, without its port.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// audit records that the request's session did action, with detail, to
+// g. A failure is logged rather than failing the request, as with saves.
+func (s *Server) audit(r *http.Request, g *Game, action, detail string) {
+	s.games.audit(AuditEvent{
+		Time:    time.Now(),
+		Game:    g.ID,
+		Action:  action,
+		Detail:  detail,
+		Session: sessionOf(r),
+		IP:      clientIP(r),
+	})
+}
+
+// auditMoves records the moves of g played by the request, those from ply
+// on.
+func (s *Server) auditMoves(r *http.Request, g *Game, ply int) {
+	for _, rec := range g.History[min(ply, len(g.History)):] {
+		s.audit(r, g, "move", rec.SAN)
+	}
+}
+
+// audit writes e to the store's audit log.
+func (m *GameManager) audit(e AuditEvent) {
+	if err := m.store.Audit(e); err != nil {
+		log.Printf("auditing %s of game %s: %v", e.Action, e.Game, err)
+	}
+}
+
+// isAdmin reports whether the request carries the admin token as
+// "Authorization: Bearer <token>". Without a token configured, nobody is an
+// admin.
+func (s *Server) isAdmin(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return s.config.AdminToken != "" && ok &&
+		subtle.ConstantTimeCompare([]byte(token), []byte(s.config.AdminToken)) == 1
+}
+
+// requireAdmin answers 403 Forbidden unless the request is an admin's.
+func (s *Server) requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if !s.isAdmin(r) {
+		writeAPIError(w, http.StatusForbidden, codeForbidden, "Admin token required")
+		return false
+	}
+	return true
+}
+
+// handleAuditLog returns the audit events matching game=, session=, ip=
+// and action=, most recent first, up to limit= of them.
+func (s *Server) handleAuditLog(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if !s.requireAdmin(w, r) {
+		return
+	}
+
+	limit := 100
+	if v := r.FormValue("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			writeAPIError(w, http.StatusBadRequest, codeBadRequest, "limit must be a positive number")
+			return
+		}
+		limit = min(n, maxAuditEvents)
+	}
+	f := auditFilter{
+		Game:    r.FormValue("game"),
+		Session: r.FormValue("session"),
+		IP:      r.FormValue("ip"),
+		Action:  r.FormValue("action"),
+	}
+	events, err := s.games.store.AuditLog(f, limit)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, codeInternal, "could not read the audit log: "+err.Error())
+		return
+	}
+	if events == nil {
+		events = []AuditEvent{}
+	}
+	writeJSON(w, events)
+}
@@ -0,0 +1,565 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rigurd/chess"
+)
+
+const (
+	// challengeTTL is how long a challenge waits for its bot to answer.
+	challengeTTL = 10 * time.Minute
+	// botKeepAlive is how often an idle bot stream sends an empty line, so
+	// proxies do not close it.
+	botKeepAlive = 7 * time.Second
+)
+
+// Challenge statuses.
+const (
+	challengePending  = "pending"
+	challengeAccepted = "accepted"
+	challengeDeclined = "declined"
+	challengeCanceled = "canceled"
+)
+
+// challenge invites a bot to a game.
+type challenge struct {
+	ID         string           `json:"id"`
+	Bot        string           `json:"bot"`   // ID of the bot's API key
+	Color      chess.PieceColor `json:"color"` // side the challenger plays
+	FEN        string           `json:"fen,omitempty"`
+	Variant    chess.Variant    `json:"variant"`
+	Status     string           `json:"status"`
+	Game       string           `json:"game,omitempty"` // once accepted
+	Created    time.Time        `json:"created"`
+	challenger string           // session of the challenger
+	start      gameStart
+}
+
+// botEvent is one line of a bot's event stream.
+type botEvent struct {
+	Type      string     `json:"type"` // "challenge", "challengeCanceled" or "gameStart"
+	Challenge *challenge `json:"challenge,omitempty"`
+	Game      *botGameID `json:"game,omitempty"`
+}
+
+// botGameID names the game of a gameStart event.
+type botGameID struct {
+	ID string `json:"id"`
+}
+
+// onlineBot is a bot listening to its event stream.
+type onlineBot struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// botHub holds the challenges to bots and the event streams of the bots
+// online, in memory: a challenge lasts minutes at most, and is sent again
+// by its challenger if the server restarts.
+type botHub struct {
+	mu         sync.Mutex
+	challenges map[string]*challenge
+	streams    map[string]map[chan botEvent]bool // by bot ID
+	names      map[string]string                 // name of each bot online
+}
+
+// newBotHub returns a hub with no bots online.
+func newBotHub() *botHub {
+	return &botHub{
+		challenges: make(map[string]*challenge),
+		streams:    make(map[string]map[chan botEvent]bool),
+		names:      make(map[string]string),
+	}
+}
+
+// listen opens an event stream for the bot, returning it with the bot's
+// pending challenges, and a function closing it.
+func (h *botHub) listen(key APIKey) (<-chan botEvent, []*challenge, func()) {
+	ch := make(chan botEvent, 16)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.streams[key.ID] == nil {
+		h.streams[key.ID] = make(map[chan botEvent]bool)
+	}
+	h.streams[key.ID][ch] = true
+	h.names[key.ID] = key.Name
+	var pending []*challenge
+	for _, c := range h.challenges {
+		if c.Bot == key.ID && c.Status == challengePending {
+			copied := *c
+			pending = append(pending, &copied)
+		}
+	}
+	slices.SortFunc(pending, func(a, b *challenge) int { return a.Created.Compare(b.Created) })
+	return ch, pending, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		delete(h.streams[key.ID], ch)
+		if len(h.streams[key.ID]) == 0 {
+			delete(h.streams, key.ID)
+			delete(h.names, key.ID)
+		}
+	}
+}
+
+// send sends e to every event stream of the bot. A stream too far behind
+// misses it rather than holding the others up. It must be called with
+// h.mu held.
+func (h *botHub) send(bot string, e botEvent) {
+	for ch := range h.streams[bot] {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// online returns the bots listening to their event stream.
+func (h *botHub) online() []onlineBot {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	bots := []onlineBot{}
+	for id, name := range h.names {
+		bots = append(bots, onlineBot{ID: id, Name: name})
+	}
+	slices.SortFunc(bots, func(a, b onlineBot) int { return strings.Compare(a.Name, b.Name) })
+	return bots
+}
+
+// challenge sends c to its bot, which must be online.
+func (h *botHub) challenge(c *challenge) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.streams[c.Bot]) == 0 {
+		return errors.New("that bot is not online")
+	}
+	h.expire(time.Now())
+	h.challenges[c.ID] = c
+	copied := *c
+	h.send(c.Bot, botEvent{Type: "challenge", Challenge: &copied})
+	return nil
+}
+
+// expire drops the challenges older than challengeTTL. It must be called
+// with h.mu held.
+func (h *botHub) expire(now time.Time) {
+	for id, c := range h.challenges {
+		if now.Sub(c.Created) >= challengeTTL {
+			delete(h.challenges, id)
+		}
+	}
+}
+
+// get returns a copy of the challenge with the given ID, if the session
+// made it or is the bot challenged.
+func (h *botHub) get(id, session string) (challenge, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.expire(time.Now())
+	c, ok := h.challenges[id]
+	if !ok || (c.challenger != session && apiKeyPlayerPrefix+c.Bot != session) {
+		return challenge{}, false
+	}
+	return *c, true
+}
+
+// answer moves the pending challenge with the given ID, which the session
+// made or was sent, to status if check allows it, telling the bot of a
+// game started or a challenge canceled. It returns the challenge as
+// answered.
+func (h *botHub) answer(id, session, status string, check func(c *challenge) error) (challenge, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.expire(time.Now())
+	c, ok := h.challenges[id]
+	if !ok || (c.challenger != session && apiKeyPlayerPrefix+c.Bot != session) {
+		return challenge{}, errChallengeNotFound
+	}
+	if c.Status != challengePending {
+		return *c, errChallengeAnswered
+	}
+	if err := check(c); err != nil {
+		return *c, err
+	}
+	c.Status = status
+	switch status {
+	case challengeAccepted:
+		h.send(c.Bot, botEvent{Type: "gameStart", Game: &botGameID{ID: c.Game}})
+	case challengeCanceled:
+		copied := *c
+		h.send(c.Bot, botEvent{Type: "challengeCanceled", Challenge: &copied})
+	}
+	return *c, nil
+}
+
+var (
+	errChallengeNotFound = errors.New("unknown challenge")
+	errChallengeAnswered = errors.New("the challenge has already been answered")
+)
+
+// requireBot answers 401 Unauthorized unless the request carries an API key
+// scoped to play, which is what makes a bot, returning the key.
+func requireBot(w http.ResponseWriter, r *http.Request) (APIKey, bool) {
+	key, ok := r.Context().Value(apiKeyKey{}).(APIKey)
+	if !ok || key.Scope != scopePlay {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeAPIError(w, http.StatusUnauthorized, codeUnauthorized, "a bot API key scoped to play is required")
+		return APIKey{}, false
+	}
+	return key, true
+}
+
+// requireStreamMethod answers 405 Method Not Allowed unless the request is a
+// GET, as requireAPIMethod does, leaving the Accept header be as streams are
+// application/x-ndjson.
+func requireStreamMethod(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeAPIError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method not allowed")
+		return false
+	}
+	return true
+}
+
+// ndjsonStream starts a response of newline-delimited JSON, returning a
+// function writing v as its next line, flushed at once.
+func ndjsonStream(w http.ResponseWriter) func(v any) error {
+	keepOpen(w)
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	enc := json.NewEncoder(w)
+	flusher, _ := w.(http.Flusher)
+	return func(v any) error {
+		var err error
+		if v == nil {
+			_, err = w.Write([]byte("\n"))
+		} else {
+			err = enc.Encode(v)
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return err
+	}
+}
+
+// handleBots lists the bots online, which can be challenged.
+func (s *Server) handleBots(w http.ResponseWriter, r *http.Request) {
+	if !requireAPIMethod(w, r, http.MethodGet) {
+		return
+	}
+	writeJSON(w, s.bots.online())
+}
+
+// handleChallengeBot challenges the bot with the ID in the path to a game,
+// in which the challenger plays color= white, black or random, from fen=
+// or the start of variant=. The challenge is pending until the bot answers;
+// once accepted, it names the game.
+func (s *Server) handleChallengeBot(w http.ResponseWriter, r *http.Request) {
+	if !requireAPIMethod(w, r, http.MethodPost) {
+		return
+	}
+	fields, ok := apiFields(w, r)
+	if !ok {
+		return
+	}
+	start, err := parseGameStart(fields["fen"], fields["variant"])
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+	color, err := parseColorChoice(fields["color"])
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+	bot := r.PathValue("bot")
+	if sessionOf(r) == apiKeyPlayerPrefix+bot {
+		writeAPIError(w, http.StatusBadRequest, codeBadRequest, "a bot cannot challenge itself")
+		return
+	}
+	if !s.requireRate(w, r, rateCreates) {
+		return
+	}
+
+	c := &challenge{
+		ID:         newGameID(),
+		Bot:        bot,
+		Color:      color,
+		FEN:        fields["fen"],
+		Variant:    start.variantOf(),
+		Status:     challengePending,
+		Created:    time.Now().UTC(),
+		challenger: sessionOf(r),
+		start:      start,
+	}
+	if err := s.bots.challenge(c); err != nil {
+		writeAPIError(w, http.StatusConflict, codeConflict, err.Error())
+		return
+	}
+	w.Header().Set("Location", "/api/v1/challenges/"+c.ID)
+	writeAPIStatus(w, http.StatusCreated, c)
+}
+
+// handleChallenge returns the challenge with the ID in the path on GET, to
+// its challenger or bot, and cancels it for its challenger on DELETE.
+func (s *Server) handleChallenge(w http.ResponseWriter, r *http.Request) {
+	if !requireAPIMethod(w, r, http.MethodGet, http.MethodDelete) {
+		return
+	}
+	id := r.PathValue("id")
+	if r.Method == http.MethodGet {
+		c, ok := s.bots.get(id, sessionOf(r))
+		if !ok {
+			writeAPIError(w, http.StatusNotFound, codeNotFound, errChallengeNotFound.Error())
+			return
+		}
+		writeJSON(w, c)
+		return
+	}
+	s.answerChallenge(w, r, challengeCanceled, func(c *challenge) error {
+		if c.challenger != sessionOf(r) {
+			return errors.New("only the challenger can cancel a challenge")
+		}
+		return nil
+	})
+}
+
+// handleAcceptChallenge accepts a challenge to the bot, starting the game
+// with the challenger seated at the side they chose and the bot at the
+// other.
+func (s *Server) handleAcceptChallenge(w http.ResponseWriter, r *http.Request) {
+	if !requireAPIMethod(w, r, http.MethodPost) {
+		return
+	}
+	key, ok := requireBot(w, r)
+	if !ok {
+		return
+	}
+	s.answerChallenge(w, r, challengeAccepted, func(c *challenge) error {
+		if c.Bot != key.ID {
+			return errors.New("only the bot challenged can accept a challenge")
+		}
+		g := s.games.Create()
+		g.update(func() {
+			c.start.apply(g)
+			g.Players[c.Color] = c.challenger
+			g.Players[chess.Opponent(c.Color)] = sessionOf(r)
+			s.audit(r, g, "create", g.FEN())
+		})
+		s.games.Save(g)
+		c.Game = g.ID
+		return nil
+	})
+}
+
+// handleDeclineChallenge declines a challenge to the bot.
+func (s *Server) handleDeclineChallenge(w http.ResponseWriter, r *http.Request) {
+	if !requireAPIMethod(w, r, http.MethodPost) {
+		return
+	}
+	key, ok := requireBot(w, r)
+	if !ok {
+		return
+	}
+	s.answerChallenge(w, r, challengeDeclined, func(c *challenge) error {
+		if c.Bot != key.ID {
+			return errors.New("only the bot challenged can decline a challenge")
+		}
+		return nil
+	})
+}
+
+// answerChallenge moves the challenge with the ID in the path to status if
+// check allows the request to, answering with the challenge.
+func (s *Server) answerChallenge(w http.ResponseWriter, r *http.Request, status string, check func(c *challenge) error) {
+	c, err := s.bots.answer(r.PathValue("id"), sessionOf(r), status, check)
+	switch {
+	case errors.Is(err, errChallengeNotFound):
+		writeAPIError(w, http.StatusNotFound, codeNotFound, err.Error())
+	case errors.Is(err, errChallengeAnswered):
+		writeAPIError(w, http.StatusConflict, codeConflict, err.Error())
+	case err != nil:
+		writeAPIError(w, http.StatusForbidden, codeForbidden, err.Error())
+	default:
+		writeJSON(w, c)
+	}
+}
+
+// handleBotEvents streams the bot's events as newline-delimited JSON: its
+// pending challenges first, then each challenge, cancellation and game
+// start as it happens, until the bot disconnects. The bot is online, and
+// can be challenged, while it listens.
+func (s *Server) handleBotEvents(w http.ResponseWriter, r *http.Request) {
+	if !requireStreamMethod(w, r) {
+		return
+	}
+	key, ok := requireBot(w, r)
+	if !ok {
+		return
+	}
+	events, pending, stop := s.bots.listen(key)
+	defer stop()
+
+	send := ndjsonStream(w)
+	for _, c := range pending {
+		if send(botEvent{Type: "challenge", Challenge: c}) != nil {
+			return
+		}
+	}
+	keepAlive := time.NewTicker(botKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		var err error
+		select {
+		case e := <-events:
+			err = send(e)
+		case <-keepAlive.C:
+			err = send(nil)
+		case <-r.Context().Done():
+			return
+		case <-s.stop:
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// botGameEvent is one line of a bot's game stream: the game, with the
+// position it started from in the first line, and its moves in UCI.
+type botGameEvent struct {
+	Type       string `json:"type"` // "gameFull", then "gameState"
+	InitialFEN string `json:"initial_fen,omitempty"`
+	Moves      string `json:"moves"`
+	apiGame
+}
+
+// newBotGameEvent returns the state of g as the bot with the given session
+// sees it. It must be called from a command of g's goroutine.
+func newBotGameEvent(g *Game, session string, full bool) botGameEvent {
+	moves := make([]string, len(g.History))
+	for i, rec := range g.History {
+		moves[i] = chess.UCI(rec.Move)
+	}
+	e := botGameEvent{Type: "gameState", Moves: strings.Join(moves, " "), apiGame: newAPIGame(g, session)}
+	if full {
+		e.Type = "gameFull"
+		e.InitialFEN = g.StartFEN
+	}
+	return e
+}
+
+// requireBotSeat answers 403 Forbidden unless the bot plays in g, returning
+// its side. It must be called from a command of g's goroutine.
+func requireBotSeat(w http.ResponseWriter, g *Game, key APIKey) (chess.PieceColor, bool) {
+	side, seated := g.sideOf(apiKeyPlayerPrefix + key.ID)
+	if !seated {
+		writeAPIError(w, http.StatusForbidden, codeNotAPlayer, "this bot is not playing in this game")
+		return "", false
+	}
+	return side, true
+}
+
+// handleBotGameStream streams the game the bot plays as newline-delimited
+// JSON: the whole game first, then its state after each change, until it
+// ends or the bot disconnects.
+func (s *Server) handleBotGameStream(w http.ResponseWriter, r *http.Request, g *Game) {
+	if !requireStreamMethod(w, r) {
+		return
+	}
+	key, ok := requireBot(w, r)
+	if !ok {
+		return
+	}
+	var seated bool
+	g.do(func() { _, seated = requireBotSeat(w, g, key) })
+	if !seated {
+		return
+	}
+	updates, unsubscribe := g.subscribe()
+	defer unsubscribe()
+
+	send := ndjsonStream(w)
+	// sendGame sends the game, reporting whether to carry on streaming it
+	sendGame := func(full bool) bool {
+		var e botGameEvent
+		g.do(func() { e = newBotGameEvent(g, sessionOf(r), full) })
+		return send(e) == nil && e.Result == chess.Ongoing
+	}
+	if !sendGame(true) {
+		return
+	}
+	keepAlive := time.NewTicker(botKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-updates:
+			if !sendGame(false) {
+				return
+			}
+		case <-keepAlive.C:
+			if send(nil) != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// handleBotMove plays the bot's move, in UCI or SAN, given in the path.
+func (s *Server) handleBotMove(w http.ResponseWriter, r *http.Request, g *Game) {
+	if !requireAPIMethod(w, r, http.MethodPost) {
+		return
+	}
+	key, ok := requireBot(w, r)
+	if !ok {
+		return
+	}
+	var seated bool
+	g.do(func() { _, seated = requireBotSeat(w, g, key) })
+	if seated {
+		s.apiPlay(w, r, g, r.PathValue("move"))
+	}
+}
+
+// handleBotResign resigns the game for the bot, on its turn as in the
+// browser.
+func (s *Server) handleBotResign(w http.ResponseWriter, r *http.Request, g *Game) {
+	if !requireAPIMethod(w, r, http.MethodPost) {
+		return
+	}
+	key, ok := requireBot(w, r)
+	if !ok {
+		return
+	}
+	g.update(func() {
+		side, seated := requireBotSeat(w, g, key)
+		switch {
+		case !seated:
+			return
+		case g.Result != chess.Ongoing:
+			writeAPIError(w, http.StatusConflict, codeGameOver, chess.ErrGameOver.Error())
+			return
+		case side != g.CurrentPlayer:
+			writeAPIError(w, http.StatusConflict, codeNotYourTurn, "you can only resign on your turn")
+			return
+		}
+		chess.Resign(&g.GameState)
+		g.clearSelection()
+		s.audit(r, g, "resign", "")
+		writeJSON(w, newAPIGame(g, sessionOf(r)))
+	})
+}
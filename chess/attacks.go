@@ -0,0 +1,112 @@
+package chess
+
+import "math"
+
+// attacksSquare reports whether the piece on 'from' attacks 'to', regardless
+// of what occupies 'to' or whose turn it is.
+func attacksSquare(g *GameState, from, to Square) bool {
+	if from == to {
+		return false
+	}
+	piece := g.Board[from.Row][from.Col]
+	switch piece {
+	case WhitePawn:
+		return to.Row-from.Row == -1 && math.Abs(float64(to.Col-from.Col)) == 1
+	case BlackPawn:
+		return to.Row-from.Row == 1 && math.Abs(float64(to.Col-from.Col)) == 1
+	case WhiteRook, BlackRook:
+		return isValidRookMove(g, from, to)
+	case WhiteKnight, BlackKnight:
+		return isValidKnightMove(from, to)
+	case WhiteBishop, BlackBishop:
+		return isValidBishopMove(g, from, to)
+	case WhiteQueen, BlackQueen:
+		return isValidQueenMove(g, from, to)
+	case WhiteKing, BlackKing:
+		return isValidKingMove(from, to)
+	}
+	return false
+}
+
+// AttackersOf returns the squares of all pieces of the given color that attack sq.
+func AttackersOf(g *GameState, sq Square, by PieceColor) []Square {
+	var attackers []Square
+	for r := 0; r < 8; r++ {
+		for c := 0; c < 8; c++ {
+			p := g.Board[r][c]
+			if p == Empty || ColorOf(p) != by {
+				continue
+			}
+			from := Square{Row: r, Col: c}
+			if attacksSquare(g, from, sq) {
+				attackers = append(attackers, from)
+			}
+		}
+	}
+	return attackers
+}
+
+// EnPrise returns the squares of the given color's pieces that can be won by
+// the opponent. A piece counts as en prise when some attacker wins material
+// capturing it according to the static exchange evaluation, so the usual
+// simplifications of SEE apply: pins, checks and tactics beyond the exchange
+// on that one square are ignored. Kings are never listed.
+func EnPrise(g *GameState, color PieceColor) []Square {
+	squares := []Square{}
+	for r := 0; r < 8; r++ {
+		for c := 0; c < 8; c++ {
+			p := g.Board[r][c]
+			if p == Empty || ColorOf(p) != color || PieceLetter(p) == "K" {
+				continue
+			}
+			sq := Square{Row: r, Col: c}
+			for _, attacker := range AttackersOf(g, sq, Opponent(color)) {
+				if SEE(g, sq, attacker) > 0 {
+					squares = append(squares, sq)
+					break
+				}
+			}
+		}
+	}
+	return squares
+}
+
+// DefendedBy returns the squares of friendly pieces protected by the piece on
+// sq. Unlike legal moves these are squares the piece could recapture on,
+// not squares it may move to.
+func DefendedBy(g *GameState, sq Square) []Square {
+	squares := []Square{}
+	p := g.Board[sq.Row][sq.Col]
+	if p == Empty {
+		return squares
+	}
+	for r := 0; r < 8; r++ {
+		for c := 0; c < 8; c++ {
+			target := g.Board[r][c]
+			if target == Empty || ColorOf(target) != ColorOf(p) {
+				continue
+			}
+			to := Square{Row: r, Col: c}
+			if attacksSquare(g, sq, to) {
+				squares = append(squares, to)
+			}
+		}
+	}
+	return squares
+}
+
+// MaxCheckers is the most pieces that can give check at once in a position
+// reachable by legal play.
+const MaxCheckers = 2
+
+// CheckersOf returns the enemy pieces giving check to the given color's king.
+// It is called on every simulated move, so it does not itself complain about
+// impossible results; checkInvariants reports more than MaxCheckers in a real
+// game position as a sign of a move-generation bug.
+func CheckersOf(g *GameState, color PieceColor) []Square {
+	king, ok := FindKing(g, color)
+	if !ok {
+		return nil
+	}
+	return AttackersOf(g, king, Opponent(color))
+}
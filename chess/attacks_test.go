@@ -0,0 +1,91 @@
+package chess
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestEnPrise(t *testing.T) {
+	tests := []struct {
+		name string
+		fen  string
+		want []string
+	}{
+		{"hanging rook", "7k/8/2b5/8/4R3/8/8/4K3 w - - 0 1", []string{"e4"}},
+		{"rook traded off evenly", "7k/8/8/4r3/4R3/3P4/8/4K3 w - - 0 1", nil},
+		{"defended rook attacked by a pawn", "7k/8/8/3p4/4R3/3P4/8/4K3 w - - 0 1", []string{"e4"}},
+		{"nothing attacked", StartFEN, nil},
+		{"king in check is not listed", "4k3/8/8/8/8/8/8/r3K3 w - - 0 1", nil},
+	}
+	for _, tt := range tests {
+		g := mustParseFEN(t, tt.fen)
+		var got []string
+		for _, sq := range EnPrise(g, g.CurrentPlayer) {
+			got = append(got, SquareName(sq))
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: EnPrise = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDefendedByRookBehindPawn(t *testing.T) {
+	// The rook on a1 defends its pawn on a2, which it can neither move to
+	// nor capture, and attacks the knight on h1, which it can capture
+	g := mustParseFEN(t, "4k3/8/8/8/8/8/P3K3/R6n w - - 0 1")
+	rook := square(t, "a1")
+	names := func(squares []Square) []string {
+		var s []string
+		for _, sq := range squares {
+			s = append(s, SquareName(sq))
+		}
+		slices.Sort(s)
+		return s
+	}
+
+	if got, want := names(DefendedBy(g, rook)), []string{"a2"}; !slices.Equal(got, want) {
+		t.Errorf("defends %v, want %v", got, want)
+	}
+	if got, want := names(GenerateLegalMoves(g, rook)), []string{"b1", "c1", "d1", "e1", "f1", "g1", "h1"}; !slices.Equal(got, want) {
+		t.Errorf("moves to %v, want %v", got, want)
+	}
+	for _, name := range []string{"a2", "h1", "d1"} {
+		if !slices.Contains(AttackersOf(g, square(t, name), White), rook) {
+			t.Errorf("the rook does not attack %s", name)
+		}
+	}
+	if slices.Contains(AttackersOf(g, square(t, "a3"), White), rook) {
+		t.Error("the rook attacks a3 through its own pawn")
+	}
+	if got := DefendedBy(g, square(t, "h1")); len(got) != 0 {
+		t.Errorf("the lone knight defends %v", names(got))
+	}
+}
+
+func TestCheckersOfDoubleCheck(t *testing.T) {
+	// The knight leaving e4 checks the king on e8 and uncovers the rook
+	g := mustParseFEN(t, "4k3/8/8/8/4N3/8/4R3/K7 w - - 0 1")
+	playSAN(t, g, "Nf6+")
+	var got []string
+	for _, sq := range CheckersOf(g, Black) {
+		got = append(got, SquareName(sq))
+	}
+	slices.Sort(got)
+	if want := []string{"e2", "f6"}; !slices.Equal(got, want) {
+		t.Errorf("checkers %v, want %v", got, want)
+	}
+	if err := CheckInvariants(g); err != nil {
+		t.Errorf("the double check broke an invariant: %v", err)
+	}
+
+	// A third checker cannot arise from legal play
+	b5 := square(t, "b5")
+	g.Board[b5.Row][b5.Col] = WhiteBishop
+	if n := len(CheckersOf(g, Black)); n != 3 {
+		t.Fatalf("%d checkers with the bishop on b5, want 3", n)
+	}
+	if err := ValidatePosition(g); err == nil || !strings.Contains(err.Error(), "checked by 3 pieces") {
+		t.Errorf("ValidatePosition with three checkers = %v, want the checkers reported", err)
+	}
+}
@@ -0,0 +1,172 @@
+package chess
+
+import "fmt"
+
+// CastlingRights records which castling moves each side may still make.
+type CastlingRights struct {
+	WhiteKingSide  bool
+	WhiteQueenSide bool
+	BlackKingSide  bool
+	BlackQueenSide bool
+}
+
+// allCastlingRights is the state at the start of a game.
+var allCastlingRights = CastlingRights{true, true, true, true}
+
+// homeRow returns the back rank row index for a color.
+func homeRow(c PieceColor) int {
+	if c == White {
+		return 7
+	}
+	return 0
+}
+
+// hasRight reports whether color may still castle on the given side.
+func (cr CastlingRights) hasRight(c PieceColor, kingSide bool) bool {
+	switch {
+	case c == White && kingSide:
+		return cr.WhiteKingSide
+	case c == White:
+		return cr.WhiteQueenSide
+	case kingSide:
+		return cr.BlackKingSide
+	default:
+		return cr.BlackQueenSide
+	}
+}
+
+// String returns the rights in FEN form, e.g. "KQkq" or "-".
+func (cr CastlingRights) String() string {
+	s := ""
+	if cr.WhiteKingSide {
+		s += "K"
+	}
+	if cr.WhiteQueenSide {
+		s += "Q"
+	}
+	if cr.BlackKingSide {
+		s += "k"
+	}
+	if cr.BlackQueenSide {
+		s += "q"
+	}
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// ParseCastling reads castling rights in FEN form.
+func ParseCastling(s string) (CastlingRights, error) {
+	var cr CastlingRights
+	if s == "" || s == "-" {
+		return cr, nil
+	}
+	for _, ch := range s {
+		switch ch {
+		case 'K':
+			cr.WhiteKingSide = true
+		case 'Q':
+			cr.WhiteQueenSide = true
+		case 'k':
+			cr.BlackKingSide = true
+		case 'q':
+			cr.BlackQueenSide = true
+		default:
+			return cr, fmt.Errorf("invalid castling rights %q", s)
+		}
+	}
+	return cr, nil
+}
+
+// validateCastling checks that every castling right is backed by a king and
+// rook still standing on their original squares.
+func validateCastling(g *GameState) error {
+	for _, color := range []PieceColor{White, Black} {
+		row := homeRow(color)
+		for _, kingSide := range []bool{true, false} {
+			if !g.Castling.hasRight(color, kingSide) {
+				continue
+			}
+			rookCol := 0
+			if kingSide {
+				rookCol = 7
+			}
+			if g.Board[row][4] != PieceFromLetter("K", color) || g.Board[row][rookCol] != PieceFromLetter("R", color) {
+				return fmt.Errorf("castling rights %s need the %s king and rook on their starting squares", g.Castling, color)
+			}
+		}
+	}
+	return nil
+}
+
+// isCastlingMove reports whether moving the piece on 'from' to 'to' is a
+// king stepping two squares along its rank.
+func isCastlingMove(g *GameState, from, to Square) bool {
+	p := g.Board[from.Row][from.Col]
+	return p != Empty && PieceLetter(p) == "K" && from.Row == to.Row && from.Col == 4 && (to.Col == 6 || to.Col == 2)
+}
+
+// isValidCastling checks that the king may castle to 'to': the right is
+// still held, the rook is in place, the squares between them are empty, and
+// the king is neither in check nor passing through an attacked square. The
+// destination square is checked by the usual self-check test.
+func isValidCastling(g *GameState, from, to Square) bool {
+	color := g.CurrentPlayer
+	if !isCastlingMove(g, from, to) || from.Row != homeRow(color) {
+		return false
+	}
+	kingSide := to.Col == 6
+	if !g.Castling.hasRight(color, kingSide) {
+		return false
+	}
+
+	rookCol := 0
+	if kingSide {
+		rookCol = 7
+	}
+	if g.Board[from.Row][rookCol] != PieceFromLetter("R", color) {
+		return false
+	}
+	if !isPathClear(g, from, Square{Row: from.Row, Col: rookCol}) {
+		return false
+	}
+
+	passing := Square{Row: from.Row, Col: (from.Col + to.Col) / 2}
+	enemy := Opponent(color)
+	return len(AttackersOf(g, from, enemy)) == 0 && len(AttackersOf(g, passing, enemy)) == 0
+}
+
+// updateCastlingRights clears rights lost by a move: any king move gives up
+// both sides, and a move from or onto a rook's starting square gives up that
+// side, which covers both the rook moving and it being captured.
+func updateCastlingRights(g *GameState, from, to Square) {
+	if p := g.Board[from.Row][from.Col]; p != Empty && PieceLetter(p) == "K" {
+		if ColorOf(p) == White {
+			g.Castling.WhiteKingSide, g.Castling.WhiteQueenSide = false, false
+		} else {
+			g.Castling.BlackKingSide, g.Castling.BlackQueenSide = false, false
+		}
+	}
+	for _, sq := range []Square{from, to} {
+		switch sq {
+		case Square{Row: 7, Col: 7}:
+			g.Castling.WhiteKingSide = false
+		case Square{Row: 7, Col: 0}:
+			g.Castling.WhiteQueenSide = false
+		case Square{Row: 0, Col: 7}:
+			g.Castling.BlackKingSide = false
+		case Square{Row: 0, Col: 0}:
+			g.Castling.BlackQueenSide = false
+		}
+	}
+}
+
+// castlingRookSquares returns where the rook moves from and to when the king
+// castles from 'from' to 'to'.
+func castlingRookSquares(from, to Square) (Square, Square) {
+	if to.Col == 6 {
+		return Square{Row: from.Row, Col: 7}, Square{Row: from.Row, Col: 5}
+	}
+	return Square{Row: from.Row, Col: 0}, Square{Row: from.Row, Col: 3}
+}
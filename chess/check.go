@@ -0,0 +1,65 @@
+package chess
+
+// IsInCheck reports whether the given color's king is attacked.
+func IsInCheck(g *GameState, color PieceColor) bool {
+	return len(CheckersOf(g, color)) > 0
+}
+
+// CopyPosition returns a scratch game holding only the fields the move
+// rules read, for trying out moves without touching the real game.
+func CopyPosition(g *GameState) *GameState {
+	return &GameState{
+		Board:         g.Board,
+		CurrentPlayer: g.CurrentPlayer,
+		Castling:      g.Castling,
+		EnPassant:     g.EnPassant,
+		Result:        Ongoing,
+	}
+}
+
+// simulateMove returns a scratch copy of the position with the move played
+// through applyMove, so special moves are simulated exactly as they are
+// played.
+func simulateMove(g *GameState, from, to Square) *GameState {
+	scratch := CopyPosition(g)
+	ApplyMove(scratch, from, to, Empty)
+	return scratch
+}
+
+// leavesKingInCheck reports whether moving the piece on 'from' to 'to' would
+// leave the mover's own king attacked, whether by a direct attack, a pin or
+// a discovered check from a sliding piece.
+func leavesKingInCheck(g *GameState, from, to Square) bool {
+	mover := ColorOf(g.Board[from.Row][from.Col])
+	return IsInCheck(simulateMove(g, from, to), mover)
+}
+
+// HasLegalMove reports whether the player to move has any legal move,
+// including a drop in Crazyhouse. It stops at the first legal move found,
+// rather than generating them all as GenerateAllLegalMoves does.
+func HasLegalMove(g *GameState) bool {
+	for r := 0; r < 8; r++ {
+		for c := 0; c < 8; c++ {
+			from := Square{Row: r, Col: c}
+			p := g.Board[r][c]
+			if p == Empty || !isCorrectPlayer(p, g.CurrentPlayer) {
+				continue
+			}
+			for _, to := range candidateTargets(g, from) {
+				if IsValidMove(g, from, to) {
+					return true
+				}
+			}
+		}
+	}
+	for p := range g.Reserves[g.CurrentPlayer] {
+		for r := 0; r < 8; r++ {
+			for c := 0; c < 8; c++ {
+				if IsValidDrop(g, p, Square{Row: r, Col: c}) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
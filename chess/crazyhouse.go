@@ -0,0 +1,70 @@
+package chess
+
+// Variant identifies the rule set a game is played under.
+type Variant string
+
+const (
+	Standard   Variant = "standard"
+	Crazyhouse Variant = "crazyhouse"
+)
+
+// ParseVariant maps a query value to a Variant, defaulting to Standard.
+func ParseVariant(s string) Variant {
+	if Variant(s) == Crazyhouse {
+		return Crazyhouse
+	}
+	return Standard
+}
+
+// newReserves returns empty drop reserves for both colors.
+func newReserves() map[PieceColor]map[Piece]int {
+	return map[PieceColor]map[Piece]int{
+		White: {},
+		Black: {},
+	}
+}
+
+// addToReserve credits a captured piece to the capturer's reserve, flipping
+// its color so it can be dropped by the capturing side.
+func addToReserve(g *GameState, captured Piece, capturer PieceColor) {
+	if g.Variant != Crazyhouse || captured == Empty {
+		return
+	}
+	g.Reserves[capturer][PieceFromLetter(PieceLetter(captured), capturer)]++
+}
+
+// IsValidDrop checks whether the current player may drop p onto the square.
+func IsValidDrop(g *GameState, p Piece, to Square) bool {
+	if g.Variant != Crazyhouse || p == Empty || g.Reserves[g.CurrentPlayer][p] == 0 {
+		return false
+	}
+	if to.Row < 0 || to.Row > 7 || to.Col < 0 || to.Col > 7 || g.Board[to.Row][to.Col] != Empty {
+		return false
+	}
+	// Pawns may not be dropped on the first or last rank
+	if (p == WhitePawn || p == BlackPawn) && (to.Row == 0 || to.Row == 7) {
+		return false
+	}
+	// A drop may not leave the dropping side in check
+	scratch := &GameState{Board: g.Board}
+	scratch.Board[to.Row][to.Col] = p
+	return !IsInCheck(scratch, g.CurrentPlayer)
+}
+
+// Drop places a reserve piece on the board and passes the turn.
+func Drop(g *GameState, p Piece, to Square) bool {
+	if !IsValidDrop(g, p, to) {
+		return false
+	}
+	rec := newRecord(g, Move{To: to, Drop: p})
+	g.Reserves[g.CurrentPlayer][p]--
+	g.Board[to.Row][to.Col] = p
+	if PieceLetter(p) == "P" {
+		g.HalfmoveClock = 0
+	} else {
+		g.HalfmoveClock++
+	}
+	EndTurn(g)
+	record(g, rec)
+	return true
+}
@@ -0,0 +1,114 @@
+package chess
+
+import "testing"
+
+// square returns the square of the given name, e.g. "e4".
+func square(t *testing.T, name string) Square {
+	t.Helper()
+	sq, err := ParseSquareName(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sq
+}
+
+// mustParseFEN returns the game of the FEN, failing the test if it is
+// invalid.
+func mustParseFEN(t *testing.T, fen string) *GameState {
+	t.Helper()
+	g, err := ParseFEN(fen)
+	if err != nil {
+		t.Fatalf("%s: %v", fen, err)
+	}
+	return g
+}
+
+func TestCaptureGoesToReserve(t *testing.T) {
+	tests := []struct {
+		variant Variant
+		moves   []string
+		white   map[Piece]int
+		black   map[Piece]int
+	}{
+		{Standard, []string{"e4", "d5", "exd5"}, map[Piece]int{}, map[Piece]int{}},
+		{Crazyhouse, []string{"e4", "d5", "exd5"}, map[Piece]int{WhitePawn: 1}, map[Piece]int{}},
+		{Crazyhouse, []string{"e4", "d5", "exd5", "Qxd5"}, map[Piece]int{WhitePawn: 1}, map[Piece]int{BlackPawn: 1}},
+		{Crazyhouse, []string{"e4", "d5", "Bb5+", "c6", "Bxc6+", "Nxc6"}, map[Piece]int{WhitePawn: 1}, map[Piece]int{BlackBishop: 1}},
+	}
+	for _, tt := range tests {
+		g := NewGameState()
+		g.Variant = tt.variant
+		playSAN(t, g, tt.moves...)
+		for color, want := range map[PieceColor]map[Piece]int{White: tt.white, Black: tt.black} {
+			got := map[Piece]int{}
+			for p, n := range g.Reserves[color] {
+				if n > 0 {
+					got[p] = n
+				}
+			}
+			if len(got) != len(want) {
+				t.Errorf("%s %v: %s reserve %v, want %v", tt.variant, tt.moves, color, got, want)
+				continue
+			}
+			for p, n := range want {
+				if got[p] != n {
+					t.Errorf("%s %v: %s reserve %v, want %v", tt.variant, tt.moves, color, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestIsValidDrop(t *testing.T) {
+	const (
+		reserve = "rnbqkbnr/pppp1ppp/8/4p3/4P3/8/PPPP1PPP/RNBQKBNR[NPp] w KQkq - 0 3"
+		checked = "4r1k1/8/8/8/8/8/8/4K3[N] w - - 0 1"
+		bare    = "4k3/8/8/8/8/8/8/4K3[P] w - - 0 1"
+	)
+	tests := []struct {
+		name  string
+		fen   string
+		piece Piece
+		to    string
+		want  bool
+	}{
+		{"knight on an empty square", reserve, WhiteKnight, "d4", true},
+		{"onto an occupied square", reserve, WhiteKnight, "e5", false},
+		{"piece not in reserve", reserve, WhiteQueen, "d4", false},
+		{"the opponent's piece", reserve, BlackPawn, "d4", false},
+		{"pawn on a middle rank", reserve, WhitePawn, "a3", true},
+		{"pawn on the last rank", bare, WhitePawn, "a8", false},
+		{"pawn on the first rank", bare, WhitePawn, "a1", false},
+		{"blocking a check", checked, WhiteKnight, "e4", true},
+		{"leaving the king in check", checked, WhiteKnight, "a3", false},
+		{"in standard chess", StartFEN, WhiteKnight, "d4", false},
+	}
+	for _, tt := range tests {
+		g := mustParseFEN(t, tt.fen)
+		if got := IsValidDrop(g, tt.piece, square(t, tt.to)); got != tt.want {
+			t.Errorf("%s: IsValidDrop(%s@%s) = %v, want %v", tt.name, PieceLetter(tt.piece), tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestDrop(t *testing.T) {
+	g := mustParseFEN(t, "6k1/5ppp/8/8/8/8/8/K7[R] w - - 0 1")
+	if !Drop(g, WhiteRook, square(t, "d8")) {
+		t.Fatal("the rook drop was refused")
+	}
+	if g.Board[0][3] != WhiteRook {
+		t.Error("the rook is not on d8")
+	}
+	if n := g.Reserves[White][WhiteRook]; n != 0 {
+		t.Errorf("%d rooks left in reserve, want 0", n)
+	}
+	if len(g.History) != 1 || g.History[0].SAN != "R@d8#" {
+		t.Errorf("history %v, want the drop R@d8#", g.History)
+	}
+	if g.Result != WhiteWins || g.EndReason != "checkmate" {
+		t.Errorf("result %s (%s), want a win for white by checkmate", g.Result, g.EndReason)
+	}
+	if Drop(g, WhiteRook, square(t, "a8")) {
+		t.Error("a second rook was dropped from an empty reserve")
+	}
+}
@@ -0,0 +1,78 @@
+package chess
+
+import (
+	_ "embed"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ECO is an opening as classified by the Encyclopaedia of Chess Openings,
+// such as B90 "Sicilian, Najdorf".
+type ECO struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+}
+
+// String returns the code and name, e.g. "B90 Sicilian, Najdorf", or "" for
+// an unclassified game.
+func (e ECO) String() string {
+	if e.Code == "" {
+		return ""
+	}
+	return e.Code + " " + e.Name
+}
+
+//go:embed eco.tsv
+var ecoTable string
+
+var (
+	ecoOnce       sync.Once
+	ecoByPosition map[string]ECO
+)
+
+// ecoIndex returns the openings of the bundled table keyed by the position
+// at the end of each line, built the first time it is needed.
+func ecoIndex() map[string]ECO {
+	ecoOnce.Do(func() {
+		ecoByPosition = map[string]ECO{}
+		for n, line := range strings.Split(ecoTable, "\n") {
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			fields := strings.Split(line, "\t")
+			if len(fields) != 3 {
+				panic(fmt.Sprintf("eco.tsv line %d: want 3 fields, got %d", n+1, len(fields)))
+			}
+			g := NewGameState()
+			for _, san := range strings.Fields(fields[2]) {
+				m, err := ParseSAN(g, san)
+				if err != nil {
+					panic(fmt.Sprintf("eco.tsv line %d: %v", n+1, err))
+				}
+				// Not Play, which would classify the move as it records it
+				ApplyMove(g, m.From, m.To, m.Promotion)
+				EndTurn(g)
+			}
+			key := PositionKey(g)
+			if _, dup := ecoByPosition[key]; dup {
+				panic(fmt.Sprintf("eco.tsv line %d: position already classified", n+1))
+			}
+			ecoByPosition[key] = ECO{Code: fields[0], Name: fields[1]}
+		}
+	})
+	return ecoByPosition
+}
+
+// classify updates the game's opening after a move. The most recent
+// position found in the table wins, so a game keeps the deepest line it
+// reached even after leaving the book, and transpositions are recognised.
+// Only standard chess is classified.
+func classify(g *GameState) {
+	if g.Variant != Standard {
+		return
+	}
+	if eco, ok := ecoIndex()[PositionKey(g)]; ok {
+		g.Opening = eco
+	}
+}
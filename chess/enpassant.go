@@ -0,0 +1,44 @@
+package chess
+
+import "fmt"
+
+// isEnPassantTarget reports whether sq is the square a pawn just skipped
+// over with a two-step advance, and so may be captured onto en passant.
+func isEnPassantTarget(g *GameState, sq Square) bool {
+	return g.EnPassant != nil && *g.EnPassant == sq
+}
+
+// isEnPassantCapture reports whether moving the piece on 'from' to 'to' is a
+// pawn capturing en passant.
+func isEnPassantCapture(g *GameState, from, to Square) bool {
+	return PieceLetter(g.Board[from.Row][from.Col]) == "P" && from.Col != to.Col &&
+		g.Board[to.Row][to.Col] == Empty && isEnPassantTarget(g, to)
+}
+
+// validateEnPassant checks that an en passant target is consistent with the
+// side to move: it lies on the third or sixth rank behind an enemy pawn that
+// could just have made a two-step advance, with both squares it crossed empty.
+func validateEnPassant(g *GameState) error {
+	if g.EnPassant == nil {
+		return nil
+	}
+	ep := *g.EnPassant
+	enemy := Opponent(g.CurrentPlayer)
+	// The pawn that just moved stands one row beyond the target, in its own
+	// direction of travel
+	dir := 1
+	if enemy == Black {
+		dir = -1
+	}
+	wantRow := 5
+	if enemy == Black {
+		wantRow = 2
+	}
+	pawn := Square{Row: ep.Row - dir, Col: ep.Col}
+	start := Square{Row: ep.Row + dir, Col: ep.Col}
+	if ep.Row != wantRow || g.Board[pawn.Row][pawn.Col] != PieceFromLetter("P", enemy) ||
+		g.Board[ep.Row][ep.Col] != Empty || g.Board[start.Row][start.Col] != Empty {
+		return fmt.Errorf("en passant square %s does not follow a two-step pawn advance", SquareName(ep))
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package chess
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// EPDOp is one operation of an EPD record: an opcode such as "bm" and its
+// operands, e.g. the best moves in SAN.
+type EPDOp struct {
+	Opcode   string
+	Operands []string
+}
+
+// EPD is a position in Extended Position Description: the first four FEN
+// fields followed by operations carrying metadata about the position, as
+// used by engine and rules test suites such as Win at Chess.
+type EPD struct {
+	Position *GameState
+	Ops      []EPDOp
+}
+
+// ParseEPD reads one EPD record. The position is validated as by ParseFEN,
+// and the "hmvc" and "fmvn" operations, when present, set its halfmove clock
+// and fullmove number. Operands may be quoted to include spaces or
+// semicolons.
+func ParseEPD(line string) (*EPD, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 4 {
+		return nil, fmt.Errorf("EPD must start with 4 position fields, got %d", len(fields))
+	}
+	pos, err := ParseFEN(strings.Join(fields[:4], " "))
+	if err != nil {
+		return nil, err
+	}
+	// Skip the position fields in the original text, keeping the quoting of
+	// the operations intact
+	rest := line
+	for range 4 {
+		rest = strings.TrimLeft(rest, " \t")
+		if i := strings.IndexAny(rest, " \t"); i >= 0 {
+			rest = rest[i:]
+		} else {
+			rest = ""
+		}
+	}
+	ops, err := parseEPDOps(rest)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &EPD{Position: pos, Ops: ops}
+	if v, ok := e.Operand("hmvc"); ok {
+		if pos.HalfmoveClock, err = strconv.Atoi(v); err != nil || pos.HalfmoveClock < 0 {
+			return nil, fmt.Errorf("invalid hmvc %q", v)
+		}
+	}
+	if v, ok := e.Operand("fmvn"); ok {
+		if pos.FullmoveNumber, err = strconv.Atoi(v); err != nil || pos.FullmoveNumber < 1 {
+			return nil, fmt.Errorf("invalid fmvn %q", v)
+		}
+	}
+	pos.StartFEN = pos.FEN()
+	UpdateStatus(pos)
+	return e, nil
+}
+
+// parseEPDOps splits the operations of an EPD record, each an opcode and
+// operands ended by a semicolon.
+func parseEPDOps(s string) ([]EPDOp, error) {
+	var ops []EPDOp
+	var words []string
+	for i := 0; i < len(s); {
+		switch ch := s[i]; {
+		case ch == ' ' || ch == '\t':
+			i++
+		case ch == ';':
+			if len(words) == 0 {
+				return nil, errors.New("empty EPD operation")
+			}
+			ops = append(ops, EPDOp{Opcode: words[0], Operands: words[1:]})
+			words = nil
+			i++
+		case ch == '"':
+			end := strings.IndexByte(s[i+1:], '"')
+			if end < 0 {
+				return nil, errors.New("unterminated EPD string operand")
+			}
+			words = append(words, s[i+1:i+1+end])
+			i += end + 2
+		default:
+			end := i
+			for end < len(s) && strings.IndexByte(" \t;\"", s[end]) < 0 {
+				end++
+			}
+			words = append(words, s[i:end])
+			i = end
+		}
+	}
+	if len(words) > 0 {
+		return nil, fmt.Errorf("EPD operation %q is not ended by a semicolon", words[0])
+	}
+	return ops, nil
+}
+
+// Operand returns the first operand of the opcode's operation, such as the
+// position's "id".
+func (e *EPD) Operand(opcode string) (string, bool) {
+	for _, op := range e.Ops {
+		if op.Opcode == opcode && len(op.Operands) > 0 {
+			return op.Operands[0], true
+		}
+	}
+	return "", false
+}
+
+// BestMoves returns the moves of the "bm" operation, resolved against the
+// position.
+func (e *EPD) BestMoves() ([]Move, error) {
+	return e.moves("bm")
+}
+
+// AvoidMoves returns the moves of the "am" operation, resolved against the
+// position.
+func (e *EPD) AvoidMoves() ([]Move, error) {
+	return e.moves("am")
+}
+
+// moves parses the SAN operands of an opcode's operations.
+func (e *EPD) moves(opcode string) ([]Move, error) {
+	var moves []Move
+	for _, op := range e.Ops {
+		if op.Opcode != opcode {
+			continue
+		}
+		for _, san := range op.Operands {
+			m, err := ParseSAN(e.Position, san)
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: %w", opcode, san, err)
+			}
+			moves = append(moves, m)
+		}
+	}
+	return moves, nil
+}
+
+// String returns the record in EPD. Operands containing spaces, quotes or
+// semicolons, and those of the "id" opcode, are quoted.
+func (e *EPD) String() string {
+	var b strings.Builder
+	b.WriteString(strings.Join(strings.Fields(e.Position.FEN())[:4], " "))
+	for _, op := range e.Ops {
+		b.WriteString(" " + op.Opcode)
+		for _, operand := range op.Operands {
+			if op.Opcode == "id" || operand == "" || strings.ContainsAny(operand, " \t;\"") {
+				operand = `"` + strings.ReplaceAll(operand, `"`, "") + `"`
+			}
+			b.WriteString(" " + operand)
+		}
+		b.WriteByte(';')
+	}
+	return b.String()
+}
+
+// ReadEPD reads a test suite of EPD records, one per line. Blank lines and
+// lines starting with "#" are skipped.
+func ReadEPD(r io.Reader) ([]*EPD, error) {
+	var suite []*EPD
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		e, err := ParseEPD(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		suite = append(suite, e)
+	}
+	return suite, sc.Err()
+}
@@ -0,0 +1,162 @@
+package chess
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// fenLetter returns the FEN letter for a piece: upper case for white, lower
+// case for black.
+func fenLetter(p Piece) string {
+	if IsWhite(p) {
+		return PieceLetter(p)
+	}
+	return strings.ToLower(PieceLetter(p))
+}
+
+// FEN returns the position in Forsyth-Edwards Notation: piece placement,
+// side to move, castling rights, en passant square, halfmove clock and
+// fullmove number. In Crazyhouse the reserves follow the placement in
+// brackets, e.g. "[Qnp]", as most Crazyhouse tools expect.
+func (gs *GameState) FEN() string {
+	var b strings.Builder
+	for r, row := range gs.Board {
+		if r > 0 {
+			b.WriteByte('/')
+		}
+		empty := 0
+		for _, p := range row {
+			if p == Empty {
+				empty++
+				continue
+			}
+			if empty > 0 {
+				b.WriteString(strconv.Itoa(empty))
+				empty = 0
+			}
+			b.WriteString(fenLetter(p))
+		}
+		if empty > 0 {
+			b.WriteString(strconv.Itoa(empty))
+		}
+	}
+	if gs.Variant == Crazyhouse {
+		b.WriteByte('[')
+		for _, color := range []PieceColor{White, Black} {
+			for _, letter := range []string{"Q", "R", "B", "N", "P"} {
+				p := PieceFromLetter(letter, color)
+				b.WriteString(strings.Repeat(fenLetter(p), gs.Reserves[color][p]))
+			}
+		}
+		b.WriteByte(']')
+	}
+
+	side := "w"
+	if gs.CurrentPlayer == Black {
+		side = "b"
+	}
+	ep := "-"
+	if gs.EnPassant != nil {
+		ep = SquareName(*gs.EnPassant)
+	}
+	return strings.Join([]string{b.String(), side, gs.Castling.String(), ep,
+		strconv.Itoa(gs.HalfmoveClock), strconv.Itoa(gs.FullmoveNumber)}, " ")
+}
+
+// StartFEN is the standard starting position.
+const StartFEN = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+
+// ParseFEN builds a game from a FEN string. The clocks may be omitted and
+// default to 0 and 1; a bracketed reserve after the placement, as written by
+// FEN, starts a Crazyhouse game. The position must pass ValidatePosition, so
+// king counts, pawn ranks, castling rights and the en passant square are all
+// checked against the board.
+func ParseFEN(fen string) (*GameState, error) {
+	fields := strings.Fields(fen)
+	if len(fields) != 4 && len(fields) != 6 {
+		return nil, fmt.Errorf("FEN must have 4 or 6 fields, got %d", len(fields))
+	}
+	g := NewGameState()
+
+	placement := fields[0]
+	if i := strings.IndexByte(placement, '['); i >= 0 && strings.HasSuffix(placement, "]") {
+		g.Variant = Crazyhouse
+		for _, ch := range placement[i+1 : len(placement)-1] {
+			p, err := PieceFromFEN(string(ch))
+			if err != nil || PieceLetter(p) == "K" {
+				return nil, fmt.Errorf("invalid reserve piece %q", ch)
+			}
+			g.Reserves[ColorOf(p)][p]++
+		}
+		placement = placement[:i]
+	}
+	ranks := strings.Split(placement, "/")
+	if len(ranks) != 8 {
+		return nil, fmt.Errorf("board must have 8 ranks, got %d", len(ranks))
+	}
+	for r, rank := range ranks {
+		c := 0
+		for _, ch := range rank {
+			if ch >= '1' && ch <= '8' {
+				for n := int(ch - '0'); n > 0 && c < 8; n-- {
+					g.Board[r][c] = Empty
+					c++
+				}
+				continue
+			}
+			p, err := PieceFromFEN(string(ch))
+			if err != nil {
+				return nil, err
+			}
+			if c < 8 {
+				g.Board[r][c] = p
+			}
+			c++
+		}
+		if c != 8 {
+			return nil, fmt.Errorf("rank %d must have 8 squares, got %d", 8-r, c)
+		}
+	}
+
+	side, err := ParseSide(fields[1])
+	if err != nil {
+		return nil, err
+	}
+	g.CurrentPlayer = side
+
+	castling, err := ParseCastling(fields[2])
+	if err != nil {
+		return nil, err
+	}
+	g.Castling = castling
+
+	g.EnPassant = nil
+	if fields[3] != "-" {
+		ep, err := ParseSquareName(fields[3])
+		if err != nil {
+			return nil, err
+		}
+		g.EnPassant = &ep
+	}
+
+	if len(fields) == 6 {
+		halfmove, err := strconv.Atoi(fields[4])
+		if err != nil || halfmove < 0 {
+			return nil, fmt.Errorf("invalid halfmove clock %q", fields[4])
+		}
+		fullmove, err := strconv.Atoi(fields[5])
+		if err != nil || fullmove < 1 {
+			return nil, fmt.Errorf("invalid fullmove number %q", fields[5])
+		}
+		g.HalfmoveClock, g.FullmoveNumber = halfmove, fullmove
+	}
+
+	if err := ValidatePosition(g); err != nil {
+		return nil, err
+	}
+	g.StartFEN = g.FEN()
+	g.Positions = map[string]int{PositionKey(g): 1}
+	UpdateStatus(g)
+	return g, nil
+}
@@ -0,0 +1,80 @@
+package chess
+
+import "testing"
+
+// checkState fails the test if g breaks an invariant or does not survive a
+// FEN round trip unchanged.
+func checkState(t *testing.T, g *GameState) {
+	t.Helper()
+	if err := CheckInvariants(g); err != nil {
+		t.Fatalf("%s: %v", g.FEN(), err)
+	}
+	fen := g.FEN()
+	back, err := ParseFEN(fen)
+	if err != nil {
+		t.Fatalf("%s does not parse back: %v", fen, err)
+	}
+	if got := back.FEN(); got != fen {
+		t.Fatalf("FEN round trip: %s became %s", fen, got)
+	}
+	if PositionKey(back) != PositionKey(g) {
+		t.Fatalf("%s: position key %q after a round trip, was %q", fen, PositionKey(back), PositionKey(g))
+	}
+}
+
+func FuzzApplyMoves(f *testing.F) {
+	for _, tt := range perftCases {
+		f.Add(tt.fen, []byte{0, 1, 2, 3, 4, 5, 6, 7})
+	}
+	f.Add(StartFEN, []byte("the quick brown fox jumps over the lazy dog"))
+	f.Add("rnbqkbnr/pppp1ppp/8/4p3/4P3/8/PPPP1PPP/RNBQKBNR[NPp] w KQkq - 0 3", []byte{9, 200, 31, 77})
+
+	f.Fuzz(func(t *testing.T, fen string, choices []byte) {
+		g, err := ParseFEN(fen)
+		if err != nil {
+			return
+		}
+		checkState(t, g)
+		for _, c := range choices {
+			if g.Result != Ongoing {
+				break
+			}
+			moves := GenerateAllLegalMoves(g)
+			if len(moves) == 0 {
+				t.Fatalf("%s: no legal moves but the game is %s", g.FEN(), g.Result)
+			}
+			m := moves[int(c)%len(moves)]
+			if !IsValidMove(g, m.From, m.To) {
+				t.Fatalf("%s: generated move %s is not valid", g.FEN(), UCI(m))
+			}
+			Play(g, m)
+			checkState(t, g)
+		}
+	})
+}
+
+func FuzzParseFEN(f *testing.F) {
+	for _, tt := range perftCases {
+		f.Add(tt.fen)
+	}
+	for _, fen := range []string{
+		"4k3/8/8/3Pp3/8/8/8/4K3 w - e6 0 1",
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR[Nn] w KQkq - 0 1",
+		"8/8/8/8/8/8/8/8 w - - 0 1",
+		"4k3/8/8/8/8/8/8/4K3 w",
+		"",
+	} {
+		f.Add(fen)
+	}
+
+	f.Fuzz(func(t *testing.T, fen string) {
+		g, err := ParseFEN(fen)
+		if err != nil {
+			return
+		}
+		if err := ValidatePosition(g); err != nil {
+			t.Fatalf("%s parsed into an invalid position: %v", fen, err)
+		}
+		checkState(t, g)
+	})
+}
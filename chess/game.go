@@ -0,0 +1,252 @@
+package chess
+
+import "maps"
+
+// EndState describes whether and how a game has finished.
+type EndState string
+
+const (
+	Ongoing   EndState = "ongoing"
+	WhiteWins EndState = "white wins"
+	BlackWins EndState = "black wins"
+	Draw      EndState = "draw"
+	Aborted   EndState = "aborted" // ended before both sides moved, without result
+)
+
+// WinFor returns the EndState in which the given color has won.
+func WinFor(c PieceColor) EndState {
+	if c == White {
+		return WhiteWins
+	}
+	return BlackWins
+}
+
+// FiftyMoveLimit is the number of half-moves without a capture or pawn move
+// after which the game is drawn.
+const FiftyMoveLimit = 100
+
+// GameState holds the current state of the chess game.
+type GameState struct {
+	Board          [8][8]Piece
+	CurrentPlayer  PieceColor
+	Variant        Variant
+	Reserves       map[PieceColor]map[Piece]int // Crazyhouse drop reserves
+	InCheck        bool                         // whether the player to move is in check
+	Castling       CastlingRights
+	EnPassant      *Square        // square skipped by a two-step pawn advance, if any
+	Positions      map[string]int // occurrences of each position, for repetition
+	HalfmoveClock  int            // half-moves since the last capture or pawn move
+	FullmoveNumber int            // starts at 1 and increases after each black move
+	DrawOffer      PieceColor     // side with a pending draw offer, or ""
+	Result         EndState
+	EndReason      string       // how the game ended, e.g. "checkmate"
+	StartFEN       string       // position the game started from
+	History        []MoveRecord // moves played so far, in order
+	Comment        string       // comment before the first move
+	Opening        ECO          // deepest book opening reached, if any
+	Undone         []MoveRecord // moves taken back by Undo, the last redone first
+}
+
+// NewGameState returns a game set up at the standard starting position.
+func NewGameState() *GameState {
+	gs := &GameState{}
+	gs.ResetBoard()
+	return gs
+}
+
+// ResetBoard puts the game back to the starting position. Every per-game
+// field must be cleared here, otherwise state from a finished game leaks into
+// the next one played on the same GameState.
+func (gs *GameState) ResetBoard() {
+	gs.Board = [8][8]Piece{
+		{BlackRook, BlackKnight, BlackBishop, BlackQueen, BlackKing, BlackBishop, BlackKnight, BlackRook},
+		{BlackPawn, BlackPawn, BlackPawn, BlackPawn, BlackPawn, BlackPawn, BlackPawn, BlackPawn},
+		{Empty, Empty, Empty, Empty, Empty, Empty, Empty, Empty},
+		{Empty, Empty, Empty, Empty, Empty, Empty, Empty, Empty},
+		{Empty, Empty, Empty, Empty, Empty, Empty, Empty, Empty},
+		{Empty, Empty, Empty, Empty, Empty, Empty, Empty, Empty},
+		{WhitePawn, WhitePawn, WhitePawn, WhitePawn, WhitePawn, WhitePawn, WhitePawn, WhitePawn},
+		{WhiteRook, WhiteKnight, WhiteBishop, WhiteQueen, WhiteKing, WhiteBishop, WhiteKnight, WhiteRook},
+	}
+	gs.CurrentPlayer = White
+	gs.Variant = Standard
+	gs.Reserves = newReserves()
+	gs.InCheck = false
+	gs.Castling = allCastlingRights
+	gs.EnPassant = nil
+	gs.Result = Ongoing
+	gs.EndReason = ""
+	gs.HalfmoveClock = 0
+	gs.FullmoveNumber = 1
+	gs.DrawOffer = ""
+	gs.StartFEN = StartFEN
+	gs.History = nil
+	gs.Comment = ""
+	gs.Opening = ECO{}
+	gs.Undone = nil
+	gs.Positions = map[string]int{PositionKey(gs): 1}
+}
+
+// Clone returns a deep copy of the game that can be played on without
+// affecting the original.
+func (gs *GameState) Clone() *GameState {
+	c := *gs
+	c.Reserves = newReserves()
+	for color, pieces := range gs.Reserves {
+		maps.Copy(c.Reserves[color], pieces)
+	}
+	if gs.EnPassant != nil {
+		ep := *gs.EnPassant
+		c.EnPassant = &ep
+	}
+	c.Positions = maps.Clone(gs.Positions)
+	c.History = cloneLine(gs.History)
+	c.Undone = cloneLine(gs.Undone)
+	return &c
+}
+
+// ApplyMove plays a validated move on the board, including the rook's part
+// of castling, the pawn taken en passant and any promotion, and updates the
+// state the move affects.
+// promo is the piece a pawn reaching the last rank becomes, or Empty.
+func ApplyMove(g *GameState, from, to Square, promo Piece) {
+	piece := g.Board[from.Row][from.Col]
+	if isCastlingMove(g, from, to) {
+		rookFrom, rookTo := castlingRookSquares(from, to)
+		g.Board[rookTo.Row][rookTo.Col] = g.Board[rookFrom.Row][rookFrom.Col]
+		g.Board[rookFrom.Row][rookFrom.Col] = Empty
+	}
+	if PieceLetter(piece) == "P" || g.Board[to.Row][to.Col] != Empty {
+		g.HalfmoveClock = 0
+	} else {
+		g.HalfmoveClock++
+	}
+	if isEnPassantCapture(g, from, to) {
+		captured := Square{Row: from.Row, Col: to.Col}
+		addToReserve(g, g.Board[captured.Row][captured.Col], g.CurrentPlayer)
+		g.Board[captured.Row][captured.Col] = Empty
+	}
+	g.EnPassant = nil
+	if PieceLetter(piece) == "P" && (to.Row-from.Row == 2 || from.Row-to.Row == 2) {
+		g.EnPassant = &Square{Row: (from.Row + to.Row) / 2, Col: from.Col}
+	}
+	updateCastlingRights(g, from, to)
+	addToReserve(g, g.Board[to.Row][to.Col], g.CurrentPlayer)
+	if promo != Empty {
+		piece = promo
+	}
+	g.Board[to.Row][to.Col] = piece
+	g.Board[from.Row][from.Col] = Empty
+}
+
+// switchPlayer hands the turn to the other side.
+func switchPlayer(g *GameState) {
+	if g.CurrentPlayer == White {
+		g.CurrentPlayer = Black
+	} else {
+		g.CurrentPlayer = White
+	}
+}
+
+// EndTurn passes the move to the other side and updates the game status
+// for the player now to move.
+func EndTurn(g *GameState) {
+	expireDrawOffer(g)
+	if g.CurrentPlayer == Black {
+		g.FullmoveNumber++
+	}
+	switchPlayer(g)
+	if g.Positions == nil {
+		g.Positions = make(map[string]int)
+	}
+	g.Positions[PositionKey(g)]++
+	UpdateStatus(g)
+	warnOnBrokenInvariants(g)
+}
+
+// UpdateStatus refreshes the check flag for the player to move and ends the
+// game when that player has no legal move: checkmate if in check, otherwise
+// stalemate. A position reached for the third time, a hundred half-moves
+// without a capture or pawn move, too little material left to mate, or a
+// rook pawn with the wrong bishop against a king holding the corner is also
+// a draw.
+func UpdateStatus(g *GameState) {
+	g.InCheck = IsInCheck(g, g.CurrentPlayer)
+	if HasLegalMove(g) {
+		switch {
+		case g.Positions[PositionKey(g)] >= 3:
+			g.Result = Draw
+			g.EndReason = "threefold repetition"
+		case g.HalfmoveClock >= FiftyMoveLimit:
+			g.Result = Draw
+			g.EndReason = "fifty-move rule"
+		case g.HalfmoveClock == 0 && IsInsufficientMaterial(g):
+			// Material only changes on a capture, which resets the clock
+			g.Result = Draw
+			g.EndReason = "insufficient material"
+		case IsWrongBishopDraw(g):
+			g.Result = Draw
+			g.EndReason = "wrong bishop"
+		}
+		return
+	}
+	if g.InCheck {
+		g.Result = WinFor(Opponent(g.CurrentPlayer))
+		g.EndReason = "checkmate"
+	} else {
+		g.Result = Draw
+		g.EndReason = "stalemate"
+	}
+}
+
+// Resign ends the game with the player to move resigning.
+func Resign(g *GameState) {
+	if g.Result != Ongoing {
+		return
+	}
+	g.Result = WinFor(Opponent(g.CurrentPlayer))
+	g.EndReason = string(g.CurrentPlayer) + " resigned"
+}
+
+// Abandon ends a game the player to move has abandoned. It is lost for
+// them once both sides have moved, and aborted without result before.
+func Abandon(g *GameState) {
+	if g.Result != Ongoing {
+		return
+	}
+	if len(g.History) < 2 {
+		g.Result = Aborted
+	} else {
+		g.Result = WinFor(Opponent(g.CurrentPlayer))
+	}
+	g.EndReason = string(g.CurrentPlayer) + " abandoned the game"
+}
+
+// OfferDraw records a draw offer from the player to move.
+func OfferDraw(g *GameState) {
+	if g.Result == Ongoing && g.DrawOffer == "" {
+		g.DrawOffer = g.CurrentPlayer
+	}
+}
+
+// RespondDraw settles a pending draw offer, ending the game in a draw when
+// accepted. It reports whether there was an offer to respond to.
+func RespondDraw(g *GameState, accept bool) bool {
+	if g.DrawOffer == "" || g.Result != Ongoing {
+		return false
+	}
+	if accept {
+		g.Result = Draw
+		g.EndReason = "agreement"
+	}
+	g.DrawOffer = ""
+	return true
+}
+
+// expireDrawOffer drops a pending offer once its recipient moves instead of
+// answering it.
+func expireDrawOffer(g *GameState) {
+	if g.DrawOffer != "" && g.DrawOffer != g.CurrentPlayer {
+		g.DrawOffer = ""
+	}
+}
@@ -0,0 +1,143 @@
+package chess
+
+import (
+	"reflect"
+	"testing"
+)
+
+// playSAN plays the moves, given in SAN, on g.
+func playSAN(t *testing.T, g *GameState, moves ...string) {
+	t.Helper()
+	for _, s := range moves {
+		m, err := ParseSAN(g, s)
+		if err != nil {
+			t.Fatalf("%s: %v", s, err)
+		}
+		Play(g, m)
+	}
+}
+
+func TestResetBoardClearsGame(t *testing.T) {
+	tests := []struct {
+		name string
+		play func(t *testing.T, g *GameState)
+	}{
+		{"checkmate", func(t *testing.T, g *GameState) {
+			playSAN(t, g, "f3", "e5", "g4", "Qh4#")
+		}},
+		{"en passant, draw offer and undo", func(t *testing.T, g *GameState) {
+			g.Comment = "a comment"
+			playSAN(t, g, "e4", "Nf6", "e5", "d5")
+			OfferDraw(g)
+			playSAN(t, g, "exd6", "cxd6", "Ke2")
+			Undo(g)
+		}},
+		{"crazyhouse reserves", func(t *testing.T, g *GameState) {
+			g.Variant = Crazyhouse
+			playSAN(t, g, "e4", "d5", "exd5", "Qxd5")
+		}},
+		{"resigned", func(t *testing.T, g *GameState) {
+			playSAN(t, g, "d4", "d5", "c4")
+			Resign(g)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGameState()
+			tt.play(t, g)
+			if reflect.DeepEqual(g, NewGameState()) {
+				t.Fatal("the game played is still a fresh game")
+			}
+			g.ResetBoard()
+			if want := NewGameState(); !reflect.DeepEqual(g, want) {
+				t.Errorf("after ResetBoard:\n got %+v\nwant %+v", g, want)
+			}
+		})
+	}
+}
+
+func TestUndoThroughLoadedPGN(t *testing.T) {
+	moves := []string{"e4", "Nf6", "e5", "d5", "exd6", "exd6", "Nf3", "Be7", "Bc4", "O-O", "O-O", "Bg4"}
+	g := NewGameState()
+	fens := []string{g.FEN()}
+	for _, san := range moves {
+		playSAN(t, g, san)
+		fens = append(fens, g.FEN())
+	}
+
+	loaded, _, err := ParsePGN(`[Event "Casual game"]
+
+1. e4 Nf6 2. e5 d5 3. exd6 exd6 4. Nf3 Be7 5. Bc4 O-O 6. O-O Bg4 *
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.History) != len(moves) {
+		t.Fatalf("%d moves loaded, want %d", len(loaded.History), len(moves))
+	}
+	for ply := len(moves); ply > 0; ply-- {
+		if got := loaded.FEN(); got != fens[ply] {
+			t.Fatalf("after %d plies: %s, want %s", ply, got, fens[ply])
+		}
+		rec, err := Undo(loaded)
+		if err != nil {
+			t.Fatalf("after %d plies: %v", ply, err)
+		}
+		if rec.SAN != moves[ply-1] {
+			t.Errorf("undid %s, want %s", rec.SAN, moves[ply-1])
+		}
+		if err := CheckInvariants(loaded); err != nil {
+			t.Errorf("after undoing %s: %v", rec.SAN, err)
+		}
+	}
+	if got := loaded.FEN(); got != fens[0] {
+		t.Errorf("after undoing every move: %s, want the start", got)
+	}
+	if _, err := Undo(loaded); err == nil {
+		t.Error("undid a move before the first")
+	}
+}
+
+func TestUndoEndedGame(t *testing.T) {
+	tests := []struct {
+		name   string
+		play   func(t *testing.T, g *GameState)
+		reopen bool
+	}{
+		{"checkmate", func(t *testing.T, g *GameState) {
+			playSAN(t, g, "f3", "e5", "g4", "Qh4#")
+		}, true},
+		{"resigned", func(t *testing.T, g *GameState) {
+			playSAN(t, g, "e4", "e5")
+			Resign(g)
+		}, false},
+		{"drawn by agreement", func(t *testing.T, g *GameState) {
+			playSAN(t, g, "e4", "e5")
+			OfferDraw(g)
+			RespondDraw(g, true)
+		}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGameState()
+			tt.play(t, g)
+			result, reason, plies := g.Result, g.EndReason, len(g.History)
+			_, err := Undo(g)
+			if tt.reopen {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if g.Result != Ongoing || g.EndReason != "" || len(g.History) != plies-1 {
+					t.Errorf("after the takeback the game is %s (%s) with %d moves", g.Result, g.EndReason, len(g.History))
+				}
+				return
+			}
+			if err != ErrGameOver {
+				t.Fatalf("Undo = %v, want %v", err, ErrGameOver)
+			}
+			if g.Result != result || g.EndReason != reason || len(g.History) != plies {
+				t.Errorf("the refused takeback left the game %s (%s) with %d moves", g.Result, g.EndReason, len(g.History))
+			}
+		})
+	}
+}
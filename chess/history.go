@@ -0,0 +1,261 @@
+package chess
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// MoveRecord is a move as played in a game: the move, the piece that made
+// it and any piece it captured, flags for special moves, when it was played,
+// the move number and side it was played by, its notation, and any
+// annotation: numeric annotation glyphs such as 1 for "!" and a comment on
+// the move. Variations are alternative lines that could have been played
+// instead of this move, each starting from the same position, so the
+// history forms a tree whose mainline is the game itself.
+type MoveRecord struct {
+	Move       Move           `json:"move"`
+	Piece      Piece          `json:"piece"`
+	Captured   Piece          `json:"captured,omitempty"`
+	Flags      MoveFlag       `json:"flags,omitempty"`
+	Time       time.Time      `json:"time"`
+	Number     int            `json:"number"`
+	Color      PieceColor     `json:"color"`
+	SAN        string         `json:"san"`
+	NAGs       []int          `json:"nags,omitempty"`
+	Comment    string         `json:"comment,omitempty"`
+	Variations [][]MoveRecord `json:"variations,omitempty"`
+	undo       undoInfo
+}
+
+// MoveFlag is a set of special-move markers on a MoveRecord.
+type MoveFlag uint8
+
+const (
+	FlagCapture MoveFlag = 1 << iota
+	FlagEnPassant
+	FlagKingsideCastle
+	FlagQueensideCastle
+	FlagPromotion
+	FlagDoublePawnPush
+	FlagDrop
+	FlagCheck
+)
+
+// moveFlagNames names each flag in JSON, in bit order.
+var moveFlagNames = []string{"capture", "enPassant", "kingsideCastle", "queensideCastle", "promotion", "doublePawnPush", "drop", "check"}
+
+// Has reports whether every flag in flag is set.
+func (f MoveFlag) Has(flag MoveFlag) bool {
+	return f&flag == flag
+}
+
+// MarshalJSON writes the flags as a list of names, e.g. ["capture","check"].
+func (f MoveFlag) MarshalJSON() ([]byte, error) {
+	names := []string{}
+	for i, name := range moveFlagNames {
+		if f.Has(1 << i) {
+			names = append(names, name)
+		}
+	}
+	return json.Marshal(names)
+}
+
+// UnmarshalJSON reads flags written by MarshalJSON.
+func (f *MoveFlag) UnmarshalJSON(data []byte) error {
+	var names []string
+	if err := json.Unmarshal(data, &names); err != nil {
+		return err
+	}
+	*f = 0
+	for _, name := range names {
+		i := slices.Index(moveFlagNames, name)
+		if i < 0 {
+			return fmt.Errorf("unknown move flag %q", name)
+		}
+		*f |= 1 << i
+	}
+	return nil
+}
+
+// newRecord describes move m before it is played on g.
+func newRecord(g *GameState, m Move) MoveRecord {
+	rec := MoveRecord{Move: m, Time: time.Now(), Number: g.FullmoveNumber, Color: g.CurrentPlayer, SAN: SAN(g, m)}
+	saveUndo(g, &rec)
+	if m.Drop != Empty {
+		rec.Piece = m.Drop
+		rec.Flags |= FlagDrop
+		return rec
+	}
+	rec.Piece = g.Board[m.From.Row][m.From.Col]
+	rec.Captured = g.Board[m.To.Row][m.To.Col]
+	switch {
+	case isEnPassantCapture(g, m.From, m.To):
+		rec.Captured = g.Board[m.From.Row][m.To.Col]
+		rec.Flags |= FlagEnPassant
+	case isCastlingMove(g, m.From, m.To) && m.To.Col > m.From.Col:
+		rec.Flags |= FlagKingsideCastle
+	case isCastlingMove(g, m.From, m.To):
+		rec.Flags |= FlagQueensideCastle
+	case PieceLetter(rec.Piece) == "P" && (m.To.Row-m.From.Row == 2 || m.From.Row-m.To.Row == 2):
+		rec.Flags |= FlagDoublePawnPush
+	}
+	if rec.Captured != Empty {
+		rec.Flags |= FlagCapture
+	}
+	if m.Promotion != Empty {
+		rec.Flags |= FlagPromotion
+	}
+	return rec
+}
+
+// Play makes a move that has already been validated, records it in the
+// history and passes the turn.
+func Play(g *GameState, m Move) {
+	rec := newRecord(g, m)
+	ApplyMove(g, m.From, m.To, m.Promotion)
+	EndTurn(g)
+	record(g, rec)
+}
+
+// record appends a played move to the history once the turn has ended, so
+// the notation can show whether it gave check or mate, and classifies the
+// opening reached.
+func record(g *GameState, rec MoveRecord) {
+	rec.SAN += checkSuffix(g)
+	if g.InCheck {
+		rec.Flags |= FlagCheck
+	}
+	g.History = append(g.History, rec)
+	g.Undone = nil
+	classify(g)
+}
+
+// cloneLine returns a deep copy of a line of moves and its variations.
+func cloneLine(line []MoveRecord) []MoveRecord {
+	if line == nil {
+		return nil
+	}
+	c := make([]MoveRecord, len(line))
+	for i, rec := range line {
+		rec.NAGs = slices.Clone(rec.NAGs)
+		if rec.Variations != nil {
+			variations := make([][]MoveRecord, len(rec.Variations))
+			for j, v := range rec.Variations {
+				variations[j] = cloneLine(v)
+			}
+			rec.Variations = variations
+		}
+		c[i] = rec
+	}
+	return c
+}
+
+// PositionAt returns the game as it stood after the first ply moves of the
+// mainline, replayed from the starting position.
+func (gs *GameState) PositionAt(ply int) (*GameState, error) {
+	if ply < 0 || ply > len(gs.History) {
+		return nil, fmt.Errorf("ply %d is outside the game's %d moves", ply, len(gs.History))
+	}
+	start, err := ParseFEN(gs.StartFEN)
+	if err != nil {
+		return nil, err
+	}
+	if gs.Variant == Crazyhouse && start.Variant != Crazyhouse {
+		start.Variant = Crazyhouse
+		start.Positions = map[string]int{PositionKey(start): 1}
+	}
+	start.Comment = gs.Comment
+	return start, replay(start, gs.History[:ply])
+}
+
+// replay plays a line of recorded moves onto g, checking each is legal and
+// keeping its annotations and variations.
+func replay(g *GameState, line []MoveRecord) error {
+	for _, rec := range line {
+		m := rec.Move
+		if m.Drop != Empty {
+			if !Drop(g, m.Drop, m.To) {
+				return fmt.Errorf("illegal drop %s", rec.SAN)
+			}
+		} else {
+			if err := ValidateMove(g, m.From, m.To); err != nil {
+				return fmt.Errorf("illegal move %s: %w", rec.SAN, err)
+			}
+			Play(g, m)
+		}
+		last := &g.History[len(g.History)-1]
+		last.Time = rec.Time
+		last.NAGs = slices.Clone(rec.NAGs)
+		last.Comment = rec.Comment
+		for _, v := range rec.Variations {
+			last.Variations = append(last.Variations, cloneLine(v))
+		}
+	}
+	return nil
+}
+
+// AddVariation stores moves, given in SAN or UCI, as an alternative to the
+// mainline move at index ply. The moves are checked against the position
+// before that move.
+func (gs *GameState) AddVariation(ply int, moves []string) error {
+	if ply < 0 || ply >= len(gs.History) {
+		return fmt.Errorf("ply %d is outside the game's %d moves", ply, len(gs.History))
+	}
+	if len(moves) == 0 {
+		return errors.New("a variation needs at least one move")
+	}
+	pos, err := gs.PositionAt(ply)
+	if err != nil {
+		return err
+	}
+	pos.History = nil
+	for i, s := range moves {
+		if pos.Result != Ongoing {
+			return fmt.Errorf("move %d: the game has ended", i+1)
+		}
+		m, err := ParseMove(pos, s)
+		if err != nil {
+			return fmt.Errorf("move %d: %w", i+1, err)
+		}
+		if m.Drop != Empty {
+			Drop(pos, m.Drop, m.To)
+		} else {
+			Play(pos, m)
+		}
+	}
+	gs.History[ply].Variations = append(gs.History[ply].Variations, pos.History)
+	return nil
+}
+
+// PromoteVariation makes variation n of the mainline move at index ply the
+// new mainline. The replaced moves become a variation in its place, and the
+// game state is rebuilt to the end of the new mainline.
+func (gs *GameState) PromoteVariation(ply, n int) error {
+	if ply < 0 || ply >= len(gs.History) || n < 0 || n >= len(gs.History[ply].Variations) {
+		return fmt.Errorf("no variation %d at ply %d", n, ply)
+	}
+	old := gs.History[ply]
+	promoted := cloneLine(old.Variations[n])
+
+	// The old mainline takes the promoted line's place among the variations
+	demoted := cloneLine(gs.History[ply:])
+	demoted[0].Variations = nil
+	variations := [][]MoveRecord{demoted}
+	for i, v := range old.Variations {
+		if i != n {
+			variations = append(variations, cloneLine(v))
+		}
+	}
+	promoted[0].Variations = append(variations, promoted[0].Variations...)
+
+	history := append(cloneLine(gs.History[:ply]), promoted...)
+	rebuilt, err := (&GameState{StartFEN: gs.StartFEN, Variant: gs.Variant, Comment: gs.Comment, History: history}).PositionAt(len(history))
+	if err != nil {
+		return err
+	}
+	*gs = *rebuilt
+	return nil
+}
@@ -0,0 +1,29 @@
+package chess
+
+import (
+	"fmt"
+	"log"
+)
+
+// CheckInvariants verifies the game state is internally consistent: the
+// position itself is valid, which includes at most two checkers and castling
+// rights and an en passant square that agree with the board, and the
+// repetition table has counted the current position.
+// A failure after a legal move points at a bug in move application.
+func CheckInvariants(g *GameState) error {
+	if err := ValidatePosition(g); err != nil {
+		return err
+	}
+	if g.Positions[PositionKey(g)] == 0 {
+		return fmt.Errorf("current position is missing from the repetition table")
+	}
+	return nil
+}
+
+// warnOnBrokenInvariants logs any invariant violation without interrupting
+// the game.
+func warnOnBrokenInvariants(g *GameState) {
+	if err := CheckInvariants(g); err != nil {
+		log.Printf("warning: game state invariant broken: %v", err)
+	}
+}
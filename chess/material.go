@@ -0,0 +1,92 @@
+package chess
+
+// IsInsufficientMaterial reports whether neither side can possibly deliver
+// checkmate: king against king, a lone minor piece against a bare king, or
+// only bishops that all stand on squares of the same color. In Crazyhouse
+// captured pieces return to play, so material is never insufficient.
+func IsInsufficientMaterial(g *GameState) bool {
+	if g.Variant == Crazyhouse {
+		return false
+	}
+
+	minors := 0
+	knights := 0
+	bishopSquareColors := map[int]bool{}
+	for r := 0; r < 8; r++ {
+		for c := 0; c < 8; c++ {
+			switch PieceLetter(g.Board[r][c]) {
+			case "", "K":
+			case "N":
+				minors++
+				knights++
+			case "B":
+				minors++
+				bishopSquareColors[(r+c)%2] = true
+			default:
+				// Any pawn, rook or queen leaves mating chances
+				return false
+			}
+		}
+	}
+
+	switch {
+	case minors <= 1:
+		return true
+	case knights == 0 && len(bishopSquareColors) == 1:
+		return true
+	}
+	return false
+}
+
+// IsWrongBishopDraw reports whether the position is the classic draw of a
+// king, a bishop and a rook pawn against a bare king, where the bishop does
+// not control the pawn's promotion square and the defending king holds the
+// corner: standing on that square or next to it, it can never be driven
+// out, and the pawn cannot promote.
+func IsWrongBishopDraw(g *GameState) bool {
+	if g.Variant == Crazyhouse {
+		return false
+	}
+
+	var pawn, bishop Square
+	pawns, bishops := 0, 0
+	pieces := map[PieceColor]int{}
+	for r := 0; r < 8; r++ {
+		for c := 0; c < 8; c++ {
+			p := g.Board[r][c]
+			if p == Empty {
+				continue
+			}
+			pieces[ColorOf(p)]++
+			switch PieceLetter(p) {
+			case "K":
+			case "P":
+				pawns++
+				pawn = Square{Row: r, Col: c}
+			case "B":
+				bishops++
+				bishop = Square{Row: r, Col: c}
+			default:
+				return false
+			}
+		}
+	}
+	if pawns != 1 || bishops != 1 || (pawn.Col != 0 && pawn.Col != 7) {
+		return false
+	}
+	strong := ColorOf(g.Board[pawn.Row][pawn.Col])
+	if ColorOf(g.Board[bishop.Row][bishop.Col]) != strong || pieces[Opponent(strong)] != 1 {
+		return false
+	}
+
+	promotion := Square{Row: 0, Col: pawn.Col}
+	if strong == Black {
+		promotion.Row = 7
+	}
+	if (bishop.Row+bishop.Col)%2 == (promotion.Row+promotion.Col)%2 {
+		// The right bishop
+		return false
+	}
+	king, _ := FindKing(g, Opponent(strong))
+	return abs(king.Row-promotion.Row) <= 1 && abs(king.Col-promotion.Col) <= 1
+}
@@ -0,0 +1,42 @@
+package chess
+
+import "testing"
+
+func TestIsWrongBishopDraw(t *testing.T) {
+	tests := []struct {
+		name string
+		fen  string
+		want bool
+	}{
+		{"wrong bishop, king in the corner", "k7/8/8/P7/8/8/8/2B1K3 w - - 0 1", true},
+		{"wrong bishop, king next to the corner", "8/1k6/8/P7/8/8/8/2B1K3 w - - 0 1", true},
+		{"black's h-pawn", "1b2k3/8/8/8/7p/8/8/7K b - - 0 1", true},
+		{"right bishop", "k7/8/8/P7/8/8/8/4KB2 w - - 0 1", false},
+		{"king far from the corner", "8/8/8/P7/8/8/7k/2B1K3 w - - 0 1", false},
+		{"knight's pawn", "k7/8/8/1P6/8/8/8/2B1K3 w - - 0 1", false},
+		{"defender has a pawn", "k7/7p/8/P7/8/8/8/2B1K3 w - - 0 1", false},
+		{"two bishops", "k7/8/8/P7/8/8/8/2B1KB2 w - - 0 1", false},
+	}
+	for _, tt := range tests {
+		if got := IsWrongBishopDraw(mustParseFEN(t, tt.fen)); got != tt.want {
+			t.Errorf("%s: IsWrongBishopDraw = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWrongBishopEndsGame(t *testing.T) {
+	g := mustParseFEN(t, "2k5/8/8/P7/8/8/8/2B1K3 b - - 0 1")
+	if g.Result != Ongoing {
+		t.Fatalf("the game is %s (%s) with the king away from the corner", g.Result, g.EndReason)
+	}
+	playSAN(t, g, "Kb8")
+	if g.Result != Draw || g.EndReason != "wrong bishop" {
+		t.Errorf("result %s (%s), want a draw by the wrong bishop", g.Result, g.EndReason)
+	}
+
+	g = mustParseFEN(t, "2k5/8/8/P7/8/8/8/4KB2 b - - 0 1")
+	playSAN(t, g, "Kb8")
+	if g.Result != Ongoing {
+		t.Errorf("with the right bishop the game is %s (%s)", g.Result, g.EndReason)
+	}
+}
@@ -0,0 +1,34 @@
+package chess
+
+// MoveError is a machine-readable reason for rejecting a move. The value is
+// a stable code for clients, and Error gives a short explanation for players.
+type MoveError string
+
+const (
+	ErrGameOver       MoveError = "game_over"
+	ErrNoPiece        MoveError = "no_piece"
+	ErrWrongTurn      MoveError = "wrong_turn"
+	ErrOwnPiece       MoveError = "own_piece"
+	ErrIllegalPattern MoveError = "illegal_pattern"
+	ErrBlockedPath    MoveError = "blocked_path"
+	ErrKingInCheck    MoveError = "king_in_check"
+	ErrIllegalDrop    MoveError = "illegal_drop"
+)
+
+var moveErrorMessages = map[MoveError]string{
+	ErrGameOver:       "The game is over.",
+	ErrNoPiece:        "There is no piece on that square.",
+	ErrWrongTurn:      "That piece belongs to the player not on move.",
+	ErrOwnPiece:       "You cannot capture your own piece.",
+	ErrIllegalPattern: "That piece cannot move there.",
+	ErrBlockedPath:    "Another piece is in the way.",
+	ErrKingInCheck:    "That move would leave your king in check.",
+	ErrIllegalDrop:    "That piece cannot be dropped there.",
+}
+
+func (e MoveError) Error() string {
+	if msg, ok := moveErrorMessages[e]; ok {
+		return msg
+	}
+	return string(e)
+}
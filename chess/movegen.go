@@ -0,0 +1,109 @@
+package chess
+
+// Move is a single move from one square to another. Promotion is the piece a
+// pawn becomes on reaching the last rank, and Empty otherwise. A Crazyhouse
+// drop sets Drop to the reserve piece placed on To and leaves From unused.
+type Move struct {
+	From      Square `json:"from"`
+	To        Square `json:"to"`
+	Promotion Piece  `json:"promotion,omitempty"`
+	Drop      Piece  `json:"drop,omitempty"`
+}
+
+var (
+	knightOffsets = []Square{{-2, -1}, {-2, 1}, {-1, -2}, {-1, 2}, {1, -2}, {1, 2}, {2, -1}, {2, 1}}
+	kingOffsets   = []Square{{-1, -1}, {-1, 0}, {-1, 1}, {0, -1}, {0, 1}, {1, -1}, {1, 0}, {1, 1}}
+	rookDirs      = []Square{{-1, 0}, {1, 0}, {0, -1}, {0, 1}}
+	bishopDirs    = []Square{{-1, -1}, {-1, 1}, {1, -1}, {1, 1}}
+)
+
+// candidateTargets returns the squares the piece on 'from' could reach by its
+// movement pattern alone. The list is a superset of the legal moves and is
+// filtered by isValidMove.
+func candidateTargets(g *GameState, from Square) []Square {
+	var targets []Square
+	add := func(r, c int) {
+		if sq := (Square{Row: r, Col: c}); OnBoard(sq) {
+			targets = append(targets, sq)
+		}
+	}
+	slide := func(dirs []Square) {
+		for _, d := range dirs {
+			for r, c := from.Row+d.Row, from.Col+d.Col; OnBoard(Square{Row: r, Col: c}); r, c = r+d.Row, c+d.Col {
+				targets = append(targets, Square{Row: r, Col: c})
+				if g.Board[r][c] != Empty {
+					break
+				}
+			}
+		}
+	}
+
+	switch PieceLetter(g.Board[from.Row][from.Col]) {
+	case "P":
+		dir := -1
+		if ColorOf(g.Board[from.Row][from.Col]) == Black {
+			dir = 1
+		}
+		add(from.Row+dir, from.Col)
+		add(from.Row+2*dir, from.Col)
+		add(from.Row+dir, from.Col-1)
+		add(from.Row+dir, from.Col+1)
+	case "N":
+		for _, o := range knightOffsets {
+			add(from.Row+o.Row, from.Col+o.Col)
+		}
+	case "K":
+		for _, o := range kingOffsets {
+			add(from.Row+o.Row, from.Col+o.Col)
+		}
+		add(from.Row, from.Col-2)
+		add(from.Row, from.Col+2)
+	case "R":
+		slide(rookDirs)
+	case "B":
+		slide(bishopDirs)
+	case "Q":
+		slide(rookDirs)
+		slide(bishopDirs)
+	}
+	return targets
+}
+
+// GenerateLegalMoves returns the squares the piece on 'from' may legally move
+// to. It is empty when the square is empty or holds a piece of the player
+// not to move.
+func GenerateLegalMoves(g *GameState, from Square) []Square {
+	p := g.Board[from.Row][from.Col]
+	if p == Empty || !isCorrectPlayer(p, g.CurrentPlayer) {
+		return nil
+	}
+	var moves []Square
+	for _, to := range candidateTargets(g, from) {
+		if IsValidMove(g, from, to) {
+			moves = append(moves, to)
+		}
+	}
+	return moves
+}
+
+// GenerateAllLegalMoves returns every legal move for the player to move.
+// A promotion is listed once for each piece the pawn may become. Crazyhouse
+// drops are not included.
+func GenerateAllLegalMoves(g *GameState) []Move {
+	var moves []Move
+	for r := 0; r < 8; r++ {
+		for c := 0; c < 8; c++ {
+			from := Square{Row: r, Col: c}
+			for _, to := range GenerateLegalMoves(g, from) {
+				if !IsPromotionMove(g, from, to) {
+					moves = append(moves, Move{From: from, To: to})
+					continue
+				}
+				for _, letter := range PromotionChoices {
+					moves = append(moves, Move{From: from, To: to, Promotion: PieceFromLetter(letter, g.CurrentPlayer)})
+				}
+			}
+		}
+	}
+	return moves
+}
@@ -0,0 +1,121 @@
+package chess
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestGenerateLegalMoves(t *testing.T) {
+	tests := []struct {
+		name string
+		fen  string
+		from string
+		want []string
+	}{
+		{"knight at the start", StartFEN, "g1", []string{"f3", "h3"}},
+		{"pawn at the start", StartFEN, "e2", []string{"e3", "e4"}},
+		{"boxed in bishop", StartFEN, "c1", nil},
+		{"empty square", StartFEN, "e4", nil},
+		{"piece of the side not to move", StartFEN, "e7", nil},
+		{"pinned knight", "4k3/4r3/8/8/8/8/4N3/4K3 w - - 0 1", "e2", nil},
+		{"pinned rook along the pin", "4k3/4r3/8/8/8/8/4R3/4K3 w - - 0 1", "e2", []string{"e3", "e4", "e5", "e6", "e7"}},
+		{"king out of check", "4k3/8/8/8/8/8/8/r3K3 w - - 0 1", "e1", []string{"d2", "e2", "f2"}},
+		{"castling both ways", "r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", "e1", []string{"c1", "d1", "d2", "e2", "f1", "f2", "g1"}},
+		{"en passant", "4k3/8/8/3Pp3/8/8/8/4K3 w - e6 0 1", "d5", []string{"d6", "e6"}},
+	}
+	for _, tt := range tests {
+		g := mustParseFEN(t, tt.fen)
+		var got []string
+		for _, sq := range GenerateLegalMoves(g, square(t, tt.from)) {
+			got = append(got, SquareName(sq))
+		}
+		slices.Sort(got)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: moves from %s %v, want %v", tt.name, tt.from, got, tt.want)
+		}
+	}
+}
+
+func TestGenerateAllLegalMoves(t *testing.T) {
+	tests := []struct {
+		name string
+		fen  string
+		want []string // nil to only count them
+		n    int
+	}{
+		{"start", StartFEN, nil, 20},
+		{"checkmate", "rnb1kbnr/pppp1ppp/8/4p3/6Pq/5P2/PPPPP2P/RNBQKBNR w KQkq - 1 3", nil, 0},
+		{"stalemate", "7k/5Q2/6K1/8/8/8/8/8 b - - 0 1", nil, 0},
+		{"double check moves the king", "4k3/8/5N2/8/8/8/4R3/K7 b - - 0 1", []string{"e8d8", "e8f7", "e8f8"}, 3},
+		{"promotion to each piece", "7k/P7/8/8/8/8/8/7K w - - 0 1", []string{"a7a8b", "a7a8n", "a7a8q", "a7a8r", "h1g1", "h1g2", "h1h2"}, 7},
+	}
+	for _, tt := range tests {
+		g := mustParseFEN(t, tt.fen)
+		moves := GenerateAllLegalMoves(g)
+		if len(moves) != tt.n {
+			t.Errorf("%s: %d moves, want %d", tt.name, len(moves), tt.n)
+		}
+		if tt.want == nil {
+			continue
+		}
+		var got []string
+		for _, m := range moves {
+			got = append(got, UCI(m))
+		}
+		slices.Sort(got)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: moves %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// legalMoveCases are positions with many, few and no legal moves.
+var legalMoveCases = []struct {
+	name string
+	fen  string
+}{
+	{"start", StartFEN},
+	{"kiwipete", "r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1"},
+	{"double check", "4k3/8/5N2/8/8/8/4R3/K7 b - - 0 1"},
+	{"only the king moves", "4k3/8/8/8/8/8/8/r3K3 w - - 0 1"},
+	{"checkmate", "rnb1kbnr/pppp1ppp/8/4p3/6Pq/5P2/PPPPP2P/RNBQKBNR w KQkq - 1 3"},
+	{"stalemate", "7k/5Q2/6K1/8/8/8/8/8 b - - 0 1"},
+	{"stalemate with a blocked pawn", "7k/5Q2/6K1/8/8/p7/P7/8 b - - 0 1"},
+}
+
+func TestHasLegalMove(t *testing.T) {
+	for _, tt := range legalMoveCases {
+		g := mustParseFEN(t, tt.fen)
+		if got, want := HasLegalMove(g), len(GenerateAllLegalMoves(g)) > 0; got != want {
+			t.Errorf("%s: HasLegalMove = %v, want %v", tt.name, got, want)
+		}
+	}
+}
+
+func BenchmarkHasLegalMove(b *testing.B) {
+	for _, tt := range legalMoveCases {
+		g, err := ParseFEN(tt.fen)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(tt.name, func(b *testing.B) {
+			for range b.N {
+				HasLegalMove(g)
+			}
+		})
+	}
+}
+
+func BenchmarkGenerateAllLegalMoves(b *testing.B) {
+	for _, tt := range legalMoveCases {
+		g, err := ParseFEN(tt.fen)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(tt.name, func(b *testing.B) {
+			for range b.N {
+				_ = len(GenerateAllLegalMoves(g)) > 0
+			}
+		})
+	}
+}
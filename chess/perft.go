@@ -0,0 +1,22 @@
+package chess
+
+// Perft counts the leaf nodes of the legal move tree to the given depth.
+// Comparing the counts with published reference values is the standard way
+// to verify move generation, including castling, en passant and promotion.
+func Perft(g *GameState, depth int) int {
+	if depth == 0 {
+		return 1
+	}
+	moves := GenerateAllLegalMoves(g)
+	if depth == 1 {
+		return len(moves)
+	}
+	nodes := 0
+	for _, m := range moves {
+		next := CopyPosition(g)
+		ApplyMove(next, m.From, m.To, m.Promotion)
+		switchPlayer(next)
+		nodes += Perft(next, depth-1)
+	}
+	return nodes
+}
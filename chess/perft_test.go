@@ -0,0 +1,34 @@
+package chess
+
+import "testing"
+
+// perftCases are the published perft counts of the standard test positions,
+// by depth from 1.
+var perftCases = []struct {
+	name  string
+	fen   string
+	nodes []int
+}{
+	{"start", StartFEN, []int{20, 400, 8902, 197281}},
+	{"kiwipete", "r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1", []int{48, 2039, 97862}},
+	{"position 3", "8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1", []int{14, 191, 2812, 43238}},
+	{"position 4", "r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1", []int{6, 264, 9467}},
+	{"position 5", "rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8", []int{44, 1486, 62379}},
+}
+
+// perftQuickNodes is the most nodes counted with -short.
+const perftQuickNodes = 10000
+
+func TestPerft(t *testing.T) {
+	for _, tt := range perftCases {
+		g := mustParseFEN(t, tt.fen)
+		for i, want := range tt.nodes {
+			if testing.Short() && want > perftQuickNodes {
+				break
+			}
+			if got := Perft(g, i+1); got != want {
+				t.Errorf("%s: perft(%d) = %d, want %d", tt.name, i+1, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,371 @@
+package chess
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// pgnLineLength is the longest movetext line written, as the PGN standard
+// recommends.
+const pgnLineLength = 79
+
+// PGNTags are the tag pairs of the PGN seven tag roster that the game itself
+// does not know. Empty values are written as "?".
+type PGNTags struct {
+	Event string
+	Site  string
+	Date  string // YYYY.MM.DD
+	Round string
+	White string
+	Black string
+}
+
+// PGNResult returns the PGN game termination marker for a result.
+func PGNResult(r EndState) string {
+	switch r {
+	case WhiteWins:
+		return "1-0"
+	case BlackWins:
+		return "0-1"
+	case Draw:
+		return "1/2-1/2"
+	}
+	return "*"
+}
+
+// PGN returns the game in Portable Game Notation: the seven tag roster, the
+// ECO and Opening tags once the opening is classified, the SetUp and FEN
+// tags when the game did not start from the standard position, a Variant tag
+// for Crazyhouse, and the moves in SAN with their annotation glyphs, comments
+// and variations. An unfinished game ends with "*".
+func (gs *GameState) PGN(tags PGNTags) string {
+	var b strings.Builder
+	writeTag := func(name, value string) {
+		if value == "" {
+			value = "?"
+		}
+		value = strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value)
+		fmt.Fprintf(&b, "[%s \"%s\"]\n", name, value)
+	}
+	result := PGNResult(gs.Result)
+	writeTag("Event", tags.Event)
+	writeTag("Site", tags.Site)
+	writeTag("Date", tags.Date)
+	writeTag("Round", tags.Round)
+	writeTag("White", tags.White)
+	writeTag("Black", tags.Black)
+	writeTag("Result", result)
+	if gs.Opening.Code != "" {
+		writeTag("ECO", gs.Opening.Code)
+		writeTag("Opening", gs.Opening.Name)
+	}
+	if gs.Variant == Crazyhouse {
+		writeTag("Variant", "Crazyhouse")
+	}
+	if gs.StartFEN != "" && gs.StartFEN != StartFEN {
+		writeTag("SetUp", "1")
+		writeTag("FEN", gs.StartFEN)
+	}
+	b.WriteByte('\n')
+
+	tokens := lineTokens(commentTokens(gs.Comment), gs.History)
+	tokens = append(tokens, result)
+
+	line := 0
+	for i, tok := range tokens {
+		if i > 0 {
+			if line+1+len(tok) > pgnLineLength {
+				b.WriteByte('\n')
+				line = 0
+			} else {
+				b.WriteByte(' ')
+				line++
+			}
+		}
+		b.WriteString(tok)
+		line += len(tok)
+	}
+	b.WriteByte('\n')
+	return b.String()
+}
+
+// lineTokens appends the movetext for a line of moves, with each move's
+// variations in parentheses after it. A move number is written before every
+// White move, and before a Black move that starts the line or follows a
+// comment or variation.
+func lineTokens(tokens []string, line []MoveRecord) []string {
+	needNumber := true
+	for _, rec := range line {
+		switch {
+		case rec.Color == White:
+			tokens = append(tokens, strconv.Itoa(rec.Number)+".")
+		case needNumber:
+			tokens = append(tokens, strconv.Itoa(rec.Number)+"...")
+		}
+		tokens = append(tokens, rec.SAN)
+		for _, nag := range rec.NAGs {
+			tokens = append(tokens, "$"+strconv.Itoa(nag))
+		}
+		tokens = append(tokens, commentTokens(rec.Comment)...)
+		needNumber = rec.Comment != ""
+		for _, variation := range rec.Variations {
+			if len(variation) == 0 {
+				continue
+			}
+			vt := lineTokens(nil, variation)
+			vt[0] = "(" + vt[0]
+			vt[len(vt)-1] += ")"
+			tokens = append(tokens, vt...)
+			needNumber = true
+		}
+	}
+	return tokens
+}
+
+// commentTokens splits a comment into words wrapped in braces, so long
+// comments can be broken across movetext lines. Closing braces cannot appear
+// inside a PGN comment and are dropped.
+func commentTokens(comment string) []string {
+	words := strings.Fields(strings.ReplaceAll(comment, "}", ""))
+	if len(words) == 0 {
+		return nil
+	}
+	words[0] = "{" + words[0]
+	words[len(words)-1] += "}"
+	return words
+}
+
+// suffixNAGs maps the traditional move suffix annotations to their numeric
+// annotation glyphs.
+var suffixNAGs = map[string]int{"!": 1, "?": 2, "!!": 3, "??": 4, "!?": 5, "?!": 6}
+
+// ParsePGN reads a single game in Portable Game Notation and plays its moves
+// through the rules engine, so every move is checked for legality. The game
+// starts from the FEN tag when present, and a Variant tag of "Crazyhouse"
+// enables drops. Comments and numeric annotation glyphs, including suffixes
+// such as "!?", are kept with the move they follow, and variations are read
+// into the move history as alternatives to the move they follow.
+// A result marker for a game the moves did not finish, such as a
+// resignation, is taken as the game's result.
+func ParsePGN(text string) (*GameState, PGNTags, error) {
+	tags := map[string]string{}
+	var tokens []string
+	for i := 0; i < len(text); {
+		switch ch := text[i]; {
+		case ch == '[':
+			end := strings.IndexByte(text[i:], ']')
+			if end < 0 {
+				return nil, PGNTags{}, errors.New("unterminated tag pair")
+			}
+			name, value, err := parseTag(text[i+1 : i+end])
+			if err != nil {
+				return nil, PGNTags{}, err
+			}
+			tags[name] = value
+			i += end + 1
+		case ch == '{':
+			end := strings.IndexByte(text[i:], '}')
+			if end < 0 {
+				return nil, PGNTags{}, errors.New("unterminated comment")
+			}
+			tokens = append(tokens, text[i:i+end+1])
+			i += end + 1
+		case ch == ';':
+			end := strings.IndexByte(text[i:], '\n')
+			if end < 0 {
+				end = len(text) - i
+			}
+			tokens = append(tokens, "{"+text[i+1:i+end]+"}")
+			i += end
+		case ch == '(' || ch == ')':
+			tokens = append(tokens, string(ch))
+			i++
+		case strings.IndexByte(" \t\r\n", ch) >= 0:
+			i++
+		default:
+			end := i
+			for end < len(text) && strings.IndexByte(" \t\r\n[]{}();", text[end]) < 0 {
+				end++
+			}
+			tokens = append(tokens, text[i:end])
+			i = end
+		}
+	}
+
+	g := NewGameState()
+	if fen, ok := tags["FEN"]; ok {
+		start, err := ParseFEN(fen)
+		if err != nil {
+			return nil, PGNTags{}, err
+		}
+		g = start
+	}
+	if strings.EqualFold(tags["Variant"], "Crazyhouse") && g.Variant != Crazyhouse {
+		g.Variant = Crazyhouse
+		g.StartFEN = g.FEN()
+	}
+
+	if _, err := readLine(g, tokens, 0, false); err != nil {
+		return nil, PGNTags{}, err
+	}
+
+	return g, PGNTags{
+		Event: tags["Event"],
+		Site:  tags["Site"],
+		Date:  tags["Date"],
+		Round: tags["Round"],
+		White: tags["White"],
+		Black: tags["Black"],
+	}, nil
+}
+
+// readLine plays the movetext tokens from index i onto g, attaching
+// comments, glyphs and variations to the moves they follow, and returns the
+// index after the line. A nested line ends at its closing parenthesis; the
+// main line ends at the result marker or the last token.
+func readLine(g *GameState, tokens []string, i int, nested bool) (int, error) {
+	var before *GameState // position before the last move of this line
+	for i < len(tokens) {
+		tok := tokens[i]
+		i++
+		switch {
+		case strings.HasPrefix(tok, "{"):
+			addComment(g, strings.TrimSpace(tok[1:len(tok)-1]))
+			continue
+		case strings.HasPrefix(tok, "$"):
+			nag, err := strconv.Atoi(tok[1:])
+			if err != nil {
+				return i, fmt.Errorf("invalid annotation glyph %q", tok)
+			}
+			if len(g.History) > 0 {
+				last := &g.History[len(g.History)-1]
+				last.NAGs = append(last.NAGs, nag)
+			}
+			continue
+		case tok == "(":
+			if before == nil {
+				return i, errors.New("variation before any move")
+			}
+			// A variation replaces the move it follows
+			variation := before.Clone()
+			variation.History, variation.Comment = nil, ""
+			next, err := readLine(variation, tokens, i, true)
+			if err != nil {
+				return next, err
+			}
+			i = next
+			last := &g.History[len(g.History)-1]
+			last.Variations = append(last.Variations, variation.History)
+			continue
+		case tok == ")":
+			if !nested {
+				return i, errors.New("unmatched closing parenthesis")
+			}
+			return i, nil
+		}
+		if r, ok := pgnResults[tok]; ok {
+			if nested {
+				continue
+			}
+			if g.Result == Ongoing {
+				g.Result = r
+			}
+			return i, nil
+		}
+
+		// Move numbers, possibly run together with the move as in "1.e4"
+		if digits := strings.TrimLeft(tok, "0123456789"); digits != tok && strings.HasPrefix(digits, ".") {
+			tok = strings.TrimLeft(digits, ".")
+		}
+		if tok == "" {
+			continue
+		}
+		if g.Result != Ongoing {
+			return i, fmt.Errorf("move %q after the game has ended", tok)
+		}
+		san := strings.TrimRight(tok, "!?")
+		m, err := ParseSAN(g, san)
+		if err != nil {
+			return i, fmt.Errorf("move %d: %w", len(g.History)+1, err)
+		}
+		before = g.Clone()
+		if m.Drop != Empty {
+			Drop(g, m.Drop, m.To)
+		} else {
+			Play(g, m)
+		}
+		if nag, ok := suffixNAGs[tok[len(san):]]; ok {
+			last := &g.History[len(g.History)-1]
+			last.NAGs = append(last.NAGs, nag)
+		}
+	}
+	if nested {
+		return i, errors.New("unterminated variation")
+	}
+	return i, nil
+}
+
+// SplitPGN splits a file of several games in PGN into the text of each
+// game. A game starts at the first tag pair after the previous game's
+// movetext, so a file without tags is a single game.
+func SplitPGN(text string) []string {
+	var games []string
+	start, inMoves := 0, false
+	for i := 0; i < len(text); {
+		end := strings.IndexByte(text[i:], '\n')
+		if end < 0 {
+			end = len(text) - i
+		} else {
+			end++
+		}
+		line := strings.TrimSpace(text[i : i+end])
+		switch {
+		case strings.HasPrefix(line, "["):
+			if inMoves {
+				games = append(games, text[start:i])
+				start, inMoves = i, false
+			}
+		case line != "" && !strings.HasPrefix(line, "%"):
+			inMoves = true
+		}
+		i += end
+	}
+	if strings.TrimSpace(text[start:]) != "" {
+		games = append(games, text[start:])
+	}
+	return games
+}
+
+// addComment attaches a comment to the last move played, or to the game
+// itself before the first move.
+func addComment(g *GameState, comment string) {
+	target := &g.Comment
+	if len(g.History) > 0 {
+		target = &g.History[len(g.History)-1].Comment
+	}
+	if *target != "" {
+		*target += " "
+	}
+	*target += comment
+}
+
+// pgnResults maps the game termination markers to results.
+var pgnResults = map[string]EndState{
+	"1-0":     WhiteWins,
+	"0-1":     BlackWins,
+	"1/2-1/2": Draw,
+	"*":       Ongoing,
+}
+
+// parseTag reads the inside of a tag pair such as `Event "Casual game"`.
+func parseTag(s string) (string, string, error) {
+	name, quoted, ok := strings.Cut(strings.TrimSpace(s), " ")
+	quoted = strings.TrimSpace(quoted)
+	if !ok || len(quoted) < 2 || quoted[0] != '"' || quoted[len(quoted)-1] != '"' {
+		return "", "", fmt.Errorf("invalid tag pair [%s]", s)
+	}
+	value := strings.NewReplacer(`\\`, `\`, `\"`, `"`).Replace(quoted[1 : len(quoted)-1])
+	return name, value, nil
+}
@@ -0,0 +1,120 @@
+// Package chess implements the rules of chess: the board, move validation
+// and application, and detection of how a game ends. It has no dependency on
+// the web server, so it can be reused by other programs.
+package chess
+
+import "fmt"
+
+// Piece represents a chess piece
+type Piece string
+
+const (
+	Empty       Piece = ""
+	WhitePawn   Piece = "♙"
+	WhiteRook   Piece = "♖"
+	WhiteKnight Piece = "♘"
+	WhiteBishop Piece = "♗"
+	WhiteQueen  Piece = "♕"
+	WhiteKing   Piece = "♔"
+	BlackPawn   Piece = "♟"
+	BlackRook   Piece = "♜"
+	BlackKnight Piece = "♞"
+	BlackBishop Piece = "♝"
+	BlackQueen  Piece = "♛"
+	BlackKing   Piece = "♚"
+)
+
+// Square represents a square on the board
+type Square struct {
+	Row int `json:"row"`
+	Col int `json:"col"`
+}
+
+// PieceColor represents the color of a piece
+type PieceColor string
+
+const (
+	White PieceColor = "white"
+	Black PieceColor = "black"
+)
+
+// PieceFromLetter returns the piece of the given color for a letter such as "N".
+func PieceFromLetter(letter string, color PieceColor) Piece {
+	white := map[string]Piece{"P": WhitePawn, "N": WhiteKnight, "B": WhiteBishop, "R": WhiteRook, "Q": WhiteQueen, "K": WhiteKing}
+	black := map[string]Piece{"P": BlackPawn, "N": BlackKnight, "B": BlackBishop, "R": BlackRook, "Q": BlackQueen, "K": BlackKing}
+	if color == White {
+		return white[letter]
+	}
+	return black[letter]
+}
+
+// PieceLetter returns the upper-case letter for a piece regardless of color.
+func PieceLetter(p Piece) string {
+	switch p {
+	case WhitePawn, BlackPawn:
+		return "P"
+	case WhiteKnight, BlackKnight:
+		return "N"
+	case WhiteBishop, BlackBishop:
+		return "B"
+	case WhiteRook, BlackRook:
+		return "R"
+	case WhiteQueen, BlackQueen:
+		return "Q"
+	case WhiteKing, BlackKing:
+		return "K"
+	}
+	return ""
+}
+
+// IsWhite determines the color of a piece.
+func IsWhite(p Piece) bool {
+	switch p {
+	case WhitePawn, WhiteRook, WhiteKnight, WhiteBishop, WhiteQueen, WhiteKing:
+		return true
+	default:
+		return false
+	}
+}
+
+// ColorOf returns the color of a non-empty piece.
+func ColorOf(p Piece) PieceColor {
+	if IsWhite(p) {
+		return White
+	}
+	return Black
+}
+
+// Opponent returns the other color.
+func Opponent(c PieceColor) PieceColor {
+	if c == White {
+		return Black
+	}
+	return White
+}
+
+// pieceValues holds the conventional material value of each piece type.
+var pieceValues = map[string]int{"P": 1, "N": 3, "B": 3, "R": 5, "Q": 9, "K": 0}
+
+// PieceValue returns the material value of a piece.
+func PieceValue(p Piece) int {
+	return pieceValues[PieceLetter(p)]
+}
+
+// OnBoard reports whether a square lies on the board.
+func OnBoard(sq Square) bool {
+	return sq.Row >= 0 && sq.Row < 8 && sq.Col >= 0 && sq.Col < 8
+}
+
+// SquareName returns the algebraic name of a square, e.g. "e4".
+func SquareName(sq Square) string {
+	return fmt.Sprintf("%c%d", 'a'+sq.Col, 8-sq.Row)
+}
+
+// ParseSquareName reads an algebraic square name such as "e3".
+func ParseSquareName(s string) (Square, error) {
+	if len(s) != 2 || s[0] < 'a' || s[0] > 'h' || s[1] < '1' || s[1] > '8' {
+		return Square{}, fmt.Errorf("invalid square %q", s)
+	}
+	return Square{Row: int('8' - s[1]), Col: int(s[0] - 'a')}, nil
+}
@@ -0,0 +1,106 @@
+package chess
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ParseSide accepts "w"/"white" or "b"/"black".
+func ParseSide(s string) (PieceColor, error) {
+	switch strings.ToLower(s) {
+	case "w", "white":
+		return White, nil
+	case "b", "black":
+		return Black, nil
+	}
+	return "", fmt.Errorf("invalid side to move %q", s)
+}
+
+// PieceFromFEN maps a FEN letter to a piece; upper case is white.
+func PieceFromFEN(letter string) (Piece, error) {
+	if letter == "" || letter == "." {
+		return Empty, nil
+	}
+	color := Black
+	if strings.ToUpper(letter) == letter {
+		color = White
+	}
+	p := PieceFromLetter(strings.ToUpper(letter), color)
+	if p == Empty {
+		return Empty, fmt.Errorf("invalid piece code %q", letter)
+	}
+	return p, nil
+}
+
+// FindKing returns the square of the given color's king.
+func FindKing(g *GameState, color PieceColor) (Square, bool) {
+	for r := 0; r < 8; r++ {
+		for c := 0; c < 8; c++ {
+			p := g.Board[r][c]
+			if p != Empty && PieceLetter(p) == "K" && ColorOf(p) == color {
+				return Square{Row: r, Col: c}, true
+			}
+		}
+	}
+	return Square{}, false
+}
+
+// ValidatePosition checks that the board and side to move describe a
+// position that could be played from: one king each, at most eight pawns and
+// sixteen pieces per side, no pawns on the back ranks, the side that just
+// moved not left in check, no more than two pieces giving check, and
+// castling rights and an en passant square that agree with the board.
+func ValidatePosition(g *GameState) error {
+	kings := map[PieceColor]int{}
+	pawns := map[PieceColor]int{}
+	pieces := map[PieceColor]int{}
+	for r := 0; r < 8; r++ {
+		for c := 0; c < 8; c++ {
+			p := g.Board[r][c]
+			if p == Empty {
+				continue
+			}
+			color := ColorOf(p)
+			pieces[color]++
+			switch PieceLetter(p) {
+			case "K":
+				kings[color]++
+			case "P":
+				pawns[color]++
+				if r == 0 || r == 7 {
+					return errors.New("pawns cannot stand on the first or last rank")
+				}
+			}
+		}
+	}
+
+	for _, color := range []PieceColor{White, Black} {
+		if kings[color] != 1 {
+			return fmt.Errorf("%s must have exactly one king, found %d", color, kings[color])
+		}
+		// Drops in Crazyhouse can bring back more material than a side starts with
+		if g.Variant == Crazyhouse {
+			continue
+		}
+		if pawns[color] > 8 {
+			return fmt.Errorf("%s has %d pawns, at most 8 are allowed", color, pawns[color])
+		}
+		if pieces[color] > 16 {
+			return fmt.Errorf("%s has %d pieces, at most 16 are allowed", color, pieces[color])
+		}
+	}
+
+	waiting := Opponent(g.CurrentPlayer)
+	king, _ := FindKing(g, waiting)
+	if len(AttackersOf(g, king, g.CurrentPlayer)) > 0 {
+		return fmt.Errorf("%s is in check but it is %s to move", waiting, g.CurrentPlayer)
+	}
+	if n := len(CheckersOf(g, g.CurrentPlayer)); n > MaxCheckers {
+		return fmt.Errorf("%s is checked by %d pieces, at most %d is possible", g.CurrentPlayer, n, MaxCheckers)
+	}
+	if err := validateCastling(g); err != nil {
+		return err
+	}
+	return validateEnPassant(g)
+}
@@ -0,0 +1,22 @@
+package chess
+
+// PromotionChoices are the piece letters a pawn may promote to.
+var PromotionChoices = []string{"Q", "R", "B", "N"}
+
+// IsPromotionMove reports whether moving the piece on 'from' to 'to' takes a
+// pawn to its last rank.
+func IsPromotionMove(g *GameState, from, to Square) bool {
+	p := g.Board[from.Row][from.Col]
+	return PieceLetter(p) == "P" && to.Row == homeRow(Opponent(ColorOf(p)))
+}
+
+// PromotionPiece returns the piece of the given color for a promotion
+// choice, or Empty when the letter is not a valid choice.
+func PromotionPiece(letter string, color PieceColor) Piece {
+	for _, choice := range PromotionChoices {
+		if choice == letter {
+			return PieceFromLetter(letter, color)
+		}
+	}
+	return Empty
+}
@@ -0,0 +1,44 @@
+package chess
+
+import "strings"
+
+// PositionKey identifies a position for repetition purposes: the piece
+// placement, side to move, castling rights and en passant square. The en
+// passant square only counts when a pawn can actually capture onto it, as
+// the rules consider positions identical otherwise.
+func PositionKey(g *GameState) string {
+	var b strings.Builder
+	for _, row := range g.Board {
+		for _, p := range row {
+			if p == Empty {
+				b.WriteByte('.')
+			} else {
+				b.WriteString(string(p))
+			}
+		}
+	}
+	b.WriteString(" " + string(g.CurrentPlayer) + " " + g.Castling.String() + " ")
+	if g.EnPassant != nil && canCaptureEnPassant(g) {
+		b.WriteString(SquareName(*g.EnPassant))
+	} else {
+		b.WriteString("-")
+	}
+	return b.String()
+}
+
+// canCaptureEnPassant reports whether the player to move has a legal en
+// passant capture.
+func canCaptureEnPassant(g *GameState) bool {
+	ep := *g.EnPassant
+	dir := 1
+	if g.CurrentPlayer == White {
+		dir = -1
+	}
+	for _, dc := range []int{-1, 1} {
+		from := Square{Row: ep.Row - dir, Col: ep.Col + dc}
+		if OnBoard(from) && g.Board[from.Row][from.Col] == PieceFromLetter("P", g.CurrentPlayer) && IsValidMove(g, from, ep) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,183 @@
+package chess
+
+import "math"
+
+// IsValidMove checks if a move is valid for the given piece type.
+func IsValidMove(g *GameState, from, to Square) bool {
+	return ValidateMove(g, from, to) == nil
+}
+
+// ValidateMove checks a move against the rules and returns a MoveError
+// explaining why it is illegal, or nil if it may be played.
+func ValidateMove(g *GameState, from, to Square) error {
+	piece := g.Board[from.Row][from.Col]
+	targetPiece := g.Board[to.Row][to.Col]
+
+	if piece == Empty {
+		return ErrNoPiece
+	}
+	if !isCorrectPlayer(piece, g.CurrentPlayer) {
+		return ErrWrongTurn
+	}
+
+	// Cannot capture your own piece
+	if targetPiece != Empty && isCorrectPlayer(targetPiece, g.CurrentPlayer) {
+		return ErrOwnPiece
+	}
+
+	valid := false
+	switch piece {
+	case WhitePawn, BlackPawn:
+		valid = isValidPawnMove(g, from, to)
+	case WhiteKnight, BlackKnight:
+		valid = isValidKnightMove(from, to)
+	case WhiteKing, BlackKing:
+		valid = isValidKingMove(from, to) || isValidCastling(g, from, to)
+	default:
+		// Sliding pieces: tell a blocked line apart from a wrong direction
+		if !isSlidingPattern(piece, from, to) {
+			return ErrIllegalPattern
+		}
+		if !isPathClear(g, from, to) {
+			return ErrBlockedPath
+		}
+		valid = true
+	}
+	if !valid {
+		return ErrIllegalPattern
+	}
+
+	// A move may not leave or place the mover's own king in check
+	if leavesKingInCheck(g, from, to) {
+		return ErrKingInCheck
+	}
+	return nil
+}
+
+// isSlidingPattern reports whether a rook, bishop or queen could travel from
+// 'from' to 'to' along one of its lines on an empty board.
+func isSlidingPattern(p Piece, from, to Square) bool {
+	straight := from.Row == to.Row || from.Col == to.Col
+	diagonal := math.Abs(float64(to.Row-from.Row)) == math.Abs(float64(to.Col-from.Col))
+	switch PieceLetter(p) {
+	case "R":
+		return straight
+	case "B":
+		return diagonal
+	case "Q":
+		return straight || diagonal
+	}
+	return false
+}
+
+// isValidPawnMove checks pawn-specific move logic.
+func isValidPawnMove(g *GameState, from, to Square) bool {
+	targetPiece := g.Board[to.Row][to.Col]
+	rowDiff := to.Row - from.Row
+	colDiff := to.Col - from.Col
+
+	if g.CurrentPlayer == White {
+		// Move one step forward
+		if colDiff == 0 && targetPiece == Empty && rowDiff == -1 {
+			return true
+		}
+		// Move two steps forward from start
+		if colDiff == 0 && targetPiece == Empty && from.Row == 6 && rowDiff == -2 && g.Board[from.Row-1][from.Col] == Empty {
+			return true
+		}
+		// Capture, including en passant
+		if math.Abs(float64(colDiff)) == 1 && rowDiff == -1 && (targetPiece != Empty || isEnPassantTarget(g, to)) {
+			return true
+		}
+	} else { // Black Player
+		// Move one step forward
+		if colDiff == 0 && targetPiece == Empty && rowDiff == 1 {
+			return true
+		}
+		// Move two steps forward from start
+		if colDiff == 0 && targetPiece == Empty && from.Row == 1 && rowDiff == 2 && g.Board[from.Row+1][from.Col] == Empty {
+			return true
+		}
+		// Capture, including en passant
+		if math.Abs(float64(colDiff)) == 1 && rowDiff == 1 && (targetPiece != Empty || isEnPassantTarget(g, to)) {
+			return true
+		}
+	}
+	return false
+}
+
+// isValidRookMove checks if the move is a valid straight line and the path is clear.
+func isValidRookMove(g *GameState, from, to Square) bool {
+	if from.Row != to.Row && from.Col != to.Col {
+		return false // Not a straight line
+	}
+	return isPathClear(g, from, to)
+}
+
+// isValidKnightMove checks for the L-shaped knight move.
+func isValidKnightMove(from, to Square) bool {
+	absRowDiff := math.Abs(float64(to.Row - from.Row))
+	absColDiff := math.Abs(float64(to.Col - from.Col))
+	return (absRowDiff == 2 && absColDiff == 1) || (absRowDiff == 1 && absColDiff == 2)
+}
+
+// isValidBishopMove checks if the move is a valid diagonal and the path is clear.
+func isValidBishopMove(g *GameState, from, to Square) bool {
+	if math.Abs(float64(to.Row-from.Row)) != math.Abs(float64(to.Col-from.Col)) {
+		return false // Not a diagonal
+	}
+	return isPathClear(g, from, to)
+}
+
+// isValidQueenMove combines rook and bishop logic.
+func isValidQueenMove(g *GameState, from, to Square) bool {
+	isStraight := from.Row == to.Row || from.Col == to.Col
+	isDiagonal := math.Abs(float64(to.Row-from.Row)) == math.Abs(float64(to.Col-from.Col))
+	if !isStraight && !isDiagonal {
+		return false
+	}
+	return isPathClear(g, from, to)
+}
+
+// isValidKingMove checks for a one-square move in any direction.
+func isValidKingMove(from, to Square) bool {
+	absRowDiff := math.Abs(float64(to.Row - from.Row))
+	absColDiff := math.Abs(float64(to.Col - from.Col))
+	return absRowDiff <= 1 && absColDiff <= 1
+}
+
+// isPathClear checks if there are any pieces between 'from' and 'to'.
+func isPathClear(g *GameState, from, to Square) bool {
+	rowStep := 0
+	if to.Row > from.Row {
+		rowStep = 1
+	} else if to.Row < from.Row {
+		rowStep = -1
+	}
+
+	colStep := 0
+	if to.Col > from.Col {
+		colStep = 1
+	} else if to.Col < from.Col {
+		colStep = -1
+	}
+
+	currRow, currCol := from.Row+rowStep, from.Col+colStep
+	for currRow != to.Row || currCol != to.Col {
+		if g.Board[currRow][currCol] != Empty {
+			return false // Path is blocked
+		}
+		currRow += rowStep
+		currCol += colStep
+	}
+	return true // Path is clear
+}
+
+// isCorrectPlayer checks if a piece belongs to the current player.
+func isCorrectPlayer(p Piece, player PieceColor) bool {
+	isWhite := IsWhite(p)
+	if player == White {
+		return isWhite
+	}
+	return !isWhite
+}
@@ -0,0 +1,102 @@
+package chess
+
+import "strings"
+
+// SAN returns the move in Standard Algebraic Notation for the position
+// before it is played, e.g. "Nbd7", "exd5", "e8=Q" or "O-O". The check or
+// mate suffix depends on the position after the move and is added by
+// checkSuffix once the move has been played.
+func SAN(g *GameState, m Move) string {
+	if m.Drop != Empty {
+		return PieceLetter(m.Drop) + "@" + SquareName(m.To)
+	}
+	piece := g.Board[m.From.Row][m.From.Col]
+	if isCastlingMove(g, m.From, m.To) {
+		if m.To.Col == 6 {
+			return "O-O"
+		}
+		return "O-O-O"
+	}
+
+	capture := g.Board[m.To.Row][m.To.Col] != Empty || isEnPassantCapture(g, m.From, m.To)
+	var b strings.Builder
+	if letter := PieceLetter(piece); letter == "P" {
+		if capture {
+			b.WriteByte(SquareName(m.From)[0])
+		}
+	} else {
+		b.WriteString(letter)
+		b.WriteString(disambiguation(g, m.From, m.To))
+	}
+	if capture {
+		b.WriteByte('x')
+	}
+	b.WriteString(SquareName(m.To))
+	if m.Promotion != Empty {
+		b.WriteString("=" + PieceLetter(m.Promotion))
+	}
+	return b.String()
+}
+
+// disambiguation returns the file, rank or whole square of 'from' needed to
+// tell the move apart from other pieces of the same kind that could also
+// legally move to 'to'. The file is preferred, then the rank.
+func disambiguation(g *GameState, from, to Square) string {
+	piece := g.Board[from.Row][from.Col]
+	ambiguous, sameFile, sameRank := false, false, false
+	for r := 0; r < 8; r++ {
+		for c := 0; c < 8; c++ {
+			other := Square{Row: r, Col: c}
+			if other == from || g.Board[r][c] != piece || !IsValidMove(g, other, to) {
+				continue
+			}
+			ambiguous = true
+			sameFile = sameFile || c == from.Col
+			sameRank = sameRank || r == from.Row
+		}
+	}
+	name := SquareName(from)
+	switch {
+	case !ambiguous:
+		return ""
+	case !sameFile:
+		return name[:1]
+	case !sameRank:
+		return name[1:]
+	}
+	return name
+}
+
+// SANWithSuffix returns the move in SAN like SAN, with the check or mate
+// suffix worked out on a scratch copy of the position after it, so the
+// moves of a position can be listed as they will be recorded.
+func SANWithSuffix(g *GameState, m Move) string {
+	next := CopyPosition(g)
+	next.Variant, next.Reserves = g.Variant, g.Reserves
+	if m.Drop != Empty {
+		next.Board[m.To.Row][m.To.Col] = m.Drop
+	} else {
+		ApplyMove(next, m.From, m.To, m.Promotion)
+	}
+	switchPlayer(next)
+	san := SAN(g, m)
+	switch {
+	case !IsInCheck(next, next.CurrentPlayer):
+		return san
+	case HasLegalMove(next):
+		return san + "+"
+	}
+	return san + "#"
+}
+
+// checkSuffix returns "#" when the player to move has been checkmated, "+"
+// when they are in check, and "" otherwise.
+func checkSuffix(g *GameState) string {
+	switch {
+	case g.InCheck && g.EndReason == "checkmate":
+		return "#"
+	case g.InCheck:
+		return "+"
+	}
+	return ""
+}
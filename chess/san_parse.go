@@ -0,0 +1,108 @@
+package chess
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ParseSAN resolves a move written in Standard Algebraic Notation, such as
+// "Nf3", "exd5", "e8=Q", "O-O" or the Crazyhouse drop "N@f3", against the
+// legal moves of the player to move. Check and annotation suffixes are
+// ignored, castling may be written with zeros, the "=" before a promotion
+// piece may be left out, and extra disambiguation is accepted.
+func ParseSAN(g *GameState, s string) (Move, error) {
+	san := strings.TrimRight(s, "+#!?")
+	if san == "" {
+		return Move{}, errors.New("empty move")
+	}
+
+	switch strings.ReplaceAll(san, "0", "O") {
+	case "O-O", "O-O-O":
+		row := homeRow(g.CurrentPlayer)
+		m := Move{From: Square{Row: row, Col: 4}, To: Square{Row: row, Col: 6}}
+		if len(san) == 5 {
+			m.To.Col = 2
+		}
+		if !isCastlingMove(g, m.From, m.To) || !IsValidMove(g, m.From, m.To) {
+			return Move{}, fmt.Errorf("illegal move %q", s)
+		}
+		return m, nil
+	}
+
+	if letter, target, ok := strings.Cut(san, "@"); ok {
+		if letter == "" {
+			letter = "P"
+		}
+		to, err := ParseSquareName(target)
+		p := PieceFromLetter(letter, g.CurrentPlayer)
+		if err != nil || p == Empty || !IsValidDrop(g, p, to) {
+			return Move{}, fmt.Errorf("illegal drop %q", s)
+		}
+		return Move{To: to, Drop: p}, nil
+	}
+
+	letter := "P"
+	if strings.ContainsRune("NBRQK", rune(san[0])) {
+		letter, san = san[:1], san[1:]
+	}
+	promo := Empty
+	if letter == "P" && len(san) > 2 && strings.ContainsRune("NBRQ", rune(san[len(san)-1])) {
+		promo = PromotionPiece(san[len(san)-1:], g.CurrentPlayer)
+		san = strings.TrimSuffix(san[:len(san)-1], "=")
+	}
+	san = strings.Replace(san, "x", "", 1)
+	if len(san) < 2 {
+		return Move{}, fmt.Errorf("invalid move %q", s)
+	}
+	to, err := ParseSquareName(san[len(san)-2:])
+	if err != nil {
+		return Move{}, fmt.Errorf("invalid move %q", s)
+	}
+	hint := san[:len(san)-2]
+
+	piece := PieceFromLetter(letter, g.CurrentPlayer)
+	var found []Square
+	for r := 0; r < 8; r++ {
+		for c := 0; c < 8; c++ {
+			from := Square{Row: r, Col: c}
+			if g.Board[r][c] != piece || !matchesHint(from, hint) || !IsValidMove(g, from, to) {
+				continue
+			}
+			found = append(found, from)
+		}
+	}
+	switch {
+	case len(found) == 0:
+		return Move{}, fmt.Errorf("illegal move %q", s)
+	case len(found) > 1:
+		return Move{}, fmt.Errorf("ambiguous move %q", s)
+	}
+
+	m := Move{From: found[0], To: to, Promotion: promo}
+	if IsPromotionMove(g, m.From, m.To) != (promo != Empty) {
+		return Move{}, fmt.Errorf("move %q must name a promotion piece exactly when a pawn reaches the last rank", s)
+	}
+	return m, nil
+}
+
+// matchesHint reports whether a square agrees with a SAN disambiguation
+// hint, which may give the file, the rank, both or neither.
+func matchesHint(sq Square, hint string) bool {
+	name := SquareName(sq)
+	for _, ch := range hint {
+		switch {
+		case ch >= 'a' && ch <= 'h':
+			if byte(ch) != name[0] {
+				return false
+			}
+		case ch >= '1' && ch <= '8':
+			if byte(ch) != name[1] {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
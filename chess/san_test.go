@@ -0,0 +1,43 @@
+package chess
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSANWithSuffix(t *testing.T) {
+	tests := []struct {
+		name string
+		fen  string
+		want []string
+	}{
+		{"mate and castling", "6k1/5ppp/8/8/8/8/8/R3K3 w Q - 0 1", []string{
+			"Kd1", "Kd2", "Ke2", "Kf1", "Kf2", "O-O-O",
+			"Ra2", "Ra3", "Ra4", "Ra5", "Ra6", "Ra7", "Ra8#", "Rb1", "Rc1", "Rd1",
+		}},
+		{"checks and disambiguation", "4k3/8/8/8/8/8/3K4/R6R w - - 0 1", []string{
+			"Kc1", "Kc2", "Kc3", "Kd1", "Kd3", "Ke1", "Ke2", "Ke3",
+			"Ra2", "Ra3", "Ra4", "Ra5", "Ra6", "Ra7", "Ra8+", "Rab1", "Rac1", "Rad1", "Rae1+", "Raf1", "Rag1",
+			"Rh2", "Rh3", "Rh4", "Rh5", "Rh6", "Rh7", "Rh8+", "Rhb1", "Rhc1", "Rhd1", "Rhe1+", "Rhf1", "Rhg1",
+		}},
+	}
+	for _, tt := range tests {
+		g := mustParseFEN(t, tt.fen)
+		var got []string
+		for _, m := range GenerateAllLegalMoves(g) {
+			got = append(got, SANWithSuffix(g, m))
+		}
+		slices.Sort(got)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s:\n got %v\nwant %v", tt.name, got, tt.want)
+		}
+	}
+
+	g := mustParseFEN(t, "6k1/5ppp/8/8/8/8/8/K7[R] w - - 0 1")
+	if got := SANWithSuffix(g, Move{To: square(t, "d8"), Drop: WhiteRook}); got != "R@d8#" {
+		t.Errorf("drop SAN %q, want R@d8#", got)
+	}
+	if got := SANWithSuffix(g, Move{To: square(t, "d1"), Drop: WhiteRook}); got != "R@d1" {
+		t.Errorf("drop SAN %q, want R@d1", got)
+	}
+}
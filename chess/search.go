@@ -0,0 +1,279 @@
+package chess
+
+import (
+	"math/rand/v2"
+	"slices"
+	"time"
+)
+
+// MateScore is the score of checkmating at once. A mate further off scores
+// less by its distance in plies, so the quickest mate is preferred and the
+// slowest defence chosen when being mated.
+const MateScore = 100000
+
+// infinity bounds every score a search returns.
+const infinity = MateScore + 1
+
+// timeCheckNodes is how many positions are searched between looks at the
+// clock.
+const timeCheckNodes = 1024
+
+// centipawns holds the value of each piece type to the search, finer than
+// PieceValue so that, all else equal, a bishop is kept over a knight.
+var centipawns = map[string]int{"P": 100, "N": 320, "B": 330, "R": 500, "Q": 900, "K": 0}
+
+// SearchLimits bounds a search, and sets how well the move it finds is
+// played.
+type SearchLimits struct {
+	Depth int           // plies to look ahead at most; at least 1 is
+	Time  time.Duration // time to search for at most, or 0 for no limit
+
+	// Noise is how far, in centipawns, the score of each move may be made
+	// out better or worse than it is, at random, so a weaker player may
+	// miss the best move. With 0 the best move is always played.
+	Noise int
+}
+
+// SearchResult is the move a search found, with its score from the side of
+// the player to move and how many plies deep it was searched.
+type SearchResult struct {
+	Move  Move
+	Score int
+	Depth int
+}
+
+// Evaluate scores the position for the player to move, in centipawns:
+// the material on the board, with a bonus for minor pieces and the queen
+// nearer the centre and for pawns further up the board.
+func Evaluate(g *GameState) int {
+	score := 0
+	for r := 0; r < 8; r++ {
+		for c := 0; c < 8; c++ {
+			p := g.Board[r][c]
+			if p == Empty {
+				continue
+			}
+			// 0 on the edge of the board up to 6 on the central squares
+			centrality := 14 - abs(2*r-7) - abs(2*c-7)
+			v := centipawns[PieceLetter(p)]
+			switch PieceLetter(p) {
+			case "P":
+				advance := 6 - r
+				if !IsWhite(p) {
+					advance = r - 1
+				}
+				v += 8*advance + centrality
+			case "N":
+				v += 5 * centrality
+			case "B":
+				v += 3 * centrality
+			case "Q":
+				v += centrality
+			}
+			if ColorOf(p) != g.CurrentPlayer {
+				v = -v
+			}
+			score += v
+		}
+	}
+	return score
+}
+
+// Search looks for the best move for the player to move with an alpha-beta
+// search, deepened one ply at a time until limits.Depth is reached or
+// limits.Time is spent. The deepest search completed decides the move; the
+// first, one ply deep, always completes. It returns false if the player has
+// no move. Past the last ply, captures are searched on until the position
+// is quiet. The search sees checkmate and stalemate, but not draws by
+// repetition or the fifty-move rule, nor Crazyhouse drops.
+func Search(g *GameState, limits SearchLimits) (SearchResult, bool) {
+	pos := CopyPosition(g)
+	moves := GenerateAllLegalMoves(pos)
+	if len(moves) == 0 {
+		return SearchResult{}, false
+	}
+	orderMoves(pos, moves)
+
+	var s searcher
+	var result SearchResult
+	for depth := 1; depth <= max(limits.Depth, 1); depth++ {
+		if depth == 2 && limits.Time > 0 {
+			s.deadline = time.Now().Add(limits.Time)
+		}
+		m, score, ok := s.root(pos, moves, depth, limits.Noise)
+		if !ok {
+			break
+		}
+		result = SearchResult{Move: m, Score: score, Depth: depth}
+		if abs(score) >= MateScore-depth {
+			// A deeper search finds no quicker mate
+			break
+		}
+		// Searched first next time, the move prunes the most
+		i := slices.Index(moves, m)
+		copy(moves[1:i+1], moves[:i])
+		moves[0] = m
+	}
+	return result, true
+}
+
+// searcher holds the state of a search across its positions.
+type searcher struct {
+	deadline time.Time // when to give up, or zero for never
+	nodes    int       // positions searched
+	stopped  bool      // whether the deadline passed
+}
+
+// root searches each of the moves of g depth plies deep, returning the one
+// whose score is best once Noise is added, with its score as searched. It
+// returns false if the deadline passed before all were.
+func (s *searcher) root(g *GameState, moves []Move, depth, noise int) (Move, int, bool) {
+	var best Move
+	bestScore, bestNoisy, chosen := -infinity, 0, 0
+	for i, m := range moves {
+		// The moves that may yet come out best once noise is added are
+		// searched for their exact score, the others only far enough to
+		// know they cannot
+		score := -s.alphaBeta(after(g, m), depth-1, 1, -infinity, -(bestScore - 2*noise))
+		if s.stopped {
+			return Move{}, 0, false
+		}
+		bestScore = max(bestScore, score)
+		noisy := score
+		if noise > 0 {
+			noisy += rand.IntN(2*noise+1) - noise
+		}
+		if i == 0 || noisy > bestNoisy {
+			best, bestNoisy, chosen = m, noisy, score
+		}
+	}
+	return best, chosen, true
+}
+
+// alphaBeta returns the score of g for the player to move, searched depth
+// plies deeper, ply plies below the root. Scores at or below alpha, which
+// the player can already better elsewhere, are returned as alpha, and
+// those at or above beta, which the opponent can keep them from, as beta.
+func (s *searcher) alphaBeta(g *GameState, depth, ply, alpha, beta int) int {
+	if s.outOfTime() {
+		return 0
+	}
+	if depth == 0 {
+		return s.quiesce(g, ply, alpha, beta)
+	}
+	moves := GenerateAllLegalMoves(g)
+	if len(moves) == 0 {
+		if IsInCheck(g, g.CurrentPlayer) {
+			return -MateScore + ply
+		}
+		return 0
+	}
+	orderMoves(g, moves)
+	for _, m := range moves {
+		score := -s.alphaBeta(after(g, m), depth-1, ply+1, -beta, -alpha)
+		if s.stopped {
+			return 0
+		}
+		if score >= beta {
+			return beta
+		}
+		alpha = max(alpha, score)
+	}
+	return alpha
+}
+
+// quiesce returns the score of g for the player to move, ply plies below
+// the root, like alphaBeta once the depth is spent, but searching on the
+// captures until the position is quiet, so the last capture searched is not
+// scored before the recapture. The player may stand on the evaluation
+// instead of capturing, except in check, where every move is searched.
+// Captures that lose material by static exchange are not searched.
+func (s *searcher) quiesce(g *GameState, ply, alpha, beta int) int {
+	if s.outOfTime() {
+		return 0
+	}
+	moves := GenerateAllLegalMoves(g)
+	inCheck := IsInCheck(g, g.CurrentPlayer)
+	if len(moves) == 0 {
+		if inCheck {
+			return -MateScore + ply
+		}
+		return 0
+	}
+	if !inCheck {
+		standPat := Evaluate(g)
+		if standPat >= beta {
+			return beta
+		}
+		alpha = max(alpha, standPat)
+		moves = slices.DeleteFunc(moves, func(m Move) bool {
+			return !isCapture(g, m) || SEE(g, m.To, m.From) < 0
+		})
+	}
+	orderMoves(g, moves)
+	for _, m := range moves {
+		score := -s.quiesce(after(g, m), ply+1, -beta, -alpha)
+		if s.stopped {
+			return 0
+		}
+		if score >= beta {
+			return beta
+		}
+		alpha = max(alpha, score)
+	}
+	return alpha
+}
+
+// isCapture reports whether m takes a piece, en passant included.
+func isCapture(g *GameState, m Move) bool {
+	if g.Board[m.To.Row][m.To.Col] != Empty {
+		return true
+	}
+	p := g.Board[m.From.Row][m.From.Col]
+	return PieceLetter(p) == "P" && m.From.Col != m.To.Col
+}
+
+// outOfTime counts a position searched, and reports whether the deadline
+// has passed, looking at the clock every timeCheckNodes positions.
+func (s *searcher) outOfTime() bool {
+	s.nodes++
+	if !s.deadline.IsZero() && s.nodes%timeCheckNodes == 0 && time.Now().After(s.deadline) {
+		s.stopped = true
+	}
+	return s.stopped
+}
+
+// orderMoves sorts the moves of g likeliest to be best first, for
+// alpha-beta to prune the most: captures winning material by static
+// exchange, the most first and then those of the most valuable pieces by
+// the least valuable, then promotions and quiet moves, and last the
+// captures losing material.
+func orderMoves(g *GameState, moves []Move) {
+	order := func(m Move) int {
+		n := 0
+		if victim := g.Board[m.To.Row][m.To.Col]; victim != Empty {
+			// SEE counts whole pawns, so it outweighs the tie-break
+			n = 10000*SEE(g, m.To, m.From) + 10*centipawns[PieceLetter(victim)] - centipawns[PieceLetter(g.Board[m.From.Row][m.From.Col])]
+		}
+		if m.Promotion != Empty {
+			n += centipawns[PieceLetter(m.Promotion)]
+		}
+		return n
+	}
+	slices.SortStableFunc(moves, func(a, b Move) int { return order(b) - order(a) })
+}
+
+// after returns a scratch copy of g with m played, as Perft plays moves.
+func after(g *GameState, m Move) *GameState {
+	next := CopyPosition(g)
+	ApplyMove(next, m.From, m.To, m.Promotion)
+	switchPlayer(next)
+	return next
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,30 @@
+package chess
+
+import "testing"
+
+func TestOrderMovesBySEE(t *testing.T) {
+	// The queen may take the loose knight on h5, or the pawn on d5, which
+	// the e6 pawn defends
+	g := mustParseFEN(t, "4k3/8/4p3/3p3n/8/8/8/3QK3 w - - 0 1")
+	moves := GenerateAllLegalMoves(g)
+	orderMoves(g, moves)
+	if first := UCI(moves[0]); first != "d1h5" {
+		t.Errorf("first move %s, want the winning capture d1h5", first)
+	}
+	if last := UCI(moves[len(moves)-1]); last != "d1d5" {
+		t.Errorf("last move %s, want the losing capture d1d5", last)
+	}
+}
+
+func TestSearchSeesRecaptures(t *testing.T) {
+	// One ply deep, taking the defended pawn only looks good without
+	// searching on to the recapture
+	g := mustParseFEN(t, "4k3/8/4p3/3p4/8/8/8/3QK3 w - - 0 1")
+	if res, _ := Search(g, SearchLimits{Depth: 1}); UCI(res.Move) == "d1d5" {
+		t.Errorf("searched d1d5 scoring %d, losing the queen", res.Score)
+	}
+	g = mustParseFEN(t, "4k3/8/4p3/3p3n/8/8/8/3QK3 w - - 0 1")
+	if res, _ := Search(g, SearchLimits{Depth: 1}); UCI(res.Move) != "d1h5" {
+		t.Errorf("searched %s scoring %d, want the free knight d1h5", UCI(res.Move), res.Score)
+	}
+}
@@ -0,0 +1,60 @@
+package chess
+
+// seeKingValue stands in for the king during exchange evaluation so that a
+// king never "recaptures" onto a square the opponent still attacks.
+const seeKingValue = 100
+
+// seeValue returns the value of a piece for exchange evaluation.
+func seeValue(p Piece) int {
+	if PieceLetter(p) == "K" {
+		return seeKingValue
+	}
+	return PieceValue(p)
+}
+
+// leastValuableAttacker returns the cheapest piece of the given color
+// attacking sq, and false when there is none.
+func leastValuableAttacker(g *GameState, sq Square, by PieceColor) (Square, bool) {
+	var best Square
+	found := false
+	for _, from := range AttackersOf(g, sq, by) {
+		if !found || seeValue(g.Board[from.Row][from.Col]) < seeValue(g.Board[best.Row][best.Col]) {
+			best, found = from, true
+		}
+	}
+	return best, found
+}
+
+// SEE performs a static exchange evaluation of the capture attacker x to. It
+// plays out the sequence of recaptures on 'to', each side always using its
+// least valuable attacker and free to stop when continuing would lose
+// material, and returns the net material gain for the side making the first
+// capture. Sliding pieces lined up behind a capturer join in as the board
+// clears. Pins and checks are not considered.
+func SEE(g *GameState, to Square, attacker Square) int {
+	scratch := &GameState{Board: g.Board}
+	gain := []int{seeValue(scratch.Board[to.Row][to.Col])}
+
+	from := attacker
+	side := ColorOf(scratch.Board[from.Row][from.Col])
+	for {
+		// Make the capture and let the other side answer
+		onSquare := scratch.Board[from.Row][from.Col]
+		scratch.Board[to.Row][to.Col] = onSquare
+		scratch.Board[from.Row][from.Col] = Empty
+		side = Opponent(side)
+
+		next, ok := leastValuableAttacker(scratch, to, side)
+		if !ok {
+			break
+		}
+		gain = append(gain, seeValue(onSquare)-gain[len(gain)-1])
+		from = next
+	}
+
+	// Negamax the gains back so that each side may decline to recapture
+	for d := len(gain) - 1; d > 0; d-- {
+		gain[d-1] = -max(-gain[d-1], gain[d])
+	}
+	return gain[0]
+}
@@ -0,0 +1,25 @@
+package chess
+
+import "testing"
+
+func TestSEE(t *testing.T) {
+	tests := []struct {
+		name     string
+		fen      string
+		attacker string
+		to       string
+		want     int
+	}{
+		{"pawn takes an undefended knight", "4k3/8/8/3n4/4P3/8/8/4K3 w - - 0 1", "e4", "d5", 3},
+		{"queen takes a pawn defended by a pawn", "4k3/8/2p5/3p4/8/8/3Q4/4K3 w - - 0 1", "d2", "d5", -8},
+		{"rook takes a rook defended by a pawn", "4k3/8/8/2p5/3r4/8/8/3RK3 w - - 0 1", "d1", "d4", 0},
+		{"doubled rooks win a defended knight", "3rk3/8/8/3n4/8/8/3R4/3RK3 w - - 0 1", "d2", "d5", 3},
+		{"knight takes a pawn guarded twice, supported once", "4k3/4r3/3p4/4p3/8/3N4/8/4RK2 w - - 0 1", "d3", "e5", -2},
+	}
+	for _, tt := range tests {
+		g := mustParseFEN(t, tt.fen)
+		if got := SEE(g, square(t, tt.to), square(t, tt.attacker)); got != tt.want {
+			t.Errorf("%s: SEE = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
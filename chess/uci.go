@@ -0,0 +1,72 @@
+package chess
+
+import (
+	"fmt"
+	"strings"
+)
+
+// UCI returns the move in UCI coordinate notation, e.g. "g1f3", "e7e8q" or
+// the Crazyhouse drop "N@f3".
+func UCI(m Move) string {
+	if m.Drop != Empty {
+		return PieceLetter(m.Drop) + "@" + SquareName(m.To)
+	}
+	s := SquareName(m.From) + SquareName(m.To)
+	if m.Promotion != Empty {
+		s += strings.ToLower(PieceLetter(m.Promotion))
+	}
+	return s
+}
+
+// ParseUCI reads a move in UCI coordinate notation and checks it is legal
+// for the player to move. Castling is written as the king's move, e.g.
+// "e1g1".
+func ParseUCI(g *GameState, s string) (Move, error) {
+	if strings.Contains(s, "@") {
+		// Drops are written the same way in both notations
+		return ParseSAN(g, s)
+	}
+	if len(s) != 4 && len(s) != 5 {
+		return Move{}, fmt.Errorf("invalid move %q", s)
+	}
+	from, err1 := ParseSquareName(s[:2])
+	to, err2 := ParseSquareName(s[2:4])
+	if err1 != nil || err2 != nil {
+		return Move{}, fmt.Errorf("invalid move %q", s)
+	}
+	m := Move{From: from, To: to}
+	if len(s) == 5 {
+		m.Promotion = PromotionPiece(strings.ToUpper(s[4:]), g.CurrentPlayer)
+		if m.Promotion == Empty {
+			return Move{}, fmt.Errorf("invalid promotion piece in %q", s)
+		}
+	}
+	if err := ValidateMove(g, from, to); err != nil {
+		return Move{}, err
+	}
+	if IsPromotionMove(g, from, to) != (m.Promotion != Empty) {
+		return Move{}, fmt.Errorf("move %q must name a promotion piece exactly when a pawn reaches the last rank", s)
+	}
+	return m, nil
+}
+
+// ParseMove reads a move in either UCI coordinate notation or SAN, checking
+// it is legal for the player to move.
+func ParseMove(g *GameState, s string) (Move, error) {
+	s = strings.TrimSpace(s)
+	if isUCI(s) {
+		return ParseUCI(g, s)
+	}
+	return ParseSAN(g, s)
+}
+
+// isUCI reports whether s has the shape of a UCI move: two square names and
+// an optional lower-case promotion letter.
+func isUCI(s string) bool {
+	if len(s) != 4 && len(s) != 5 {
+		return false
+	}
+	_, err1 := ParseSquareName(s[:2])
+	_, err2 := ParseSquareName(s[2:4])
+	return err1 == nil && err2 == nil && (len(s) == 4 || strings.ContainsRune("qrbn", rune(s[4])))
+}
@@ -0,0 +1,124 @@
+package chess
+
+import "errors"
+
+// undoInfo is the state a move overwrites that cannot be worked out from
+// its MoveRecord, saved so the move can be unmade.
+type undoInfo struct {
+	saved         bool
+	castling      CastlingRights
+	enPassant     *Square
+	halfmoveClock int
+	drawOffer     PieceColor
+	opening       ECO
+}
+
+// saveUndo records the state g is in before rec is played.
+func saveUndo(g *GameState, rec *MoveRecord) {
+	rec.undo = undoInfo{
+		saved:         true,
+		castling:      g.Castling,
+		enPassant:     g.EnPassant,
+		halfmoveClock: g.HalfmoveClock,
+		drawOffer:     g.DrawOffer,
+		opening:       g.Opening,
+	}
+}
+
+// boardEndReasons are the ways a move on the board ends the game, which
+// taking the move back undoes.
+var boardEndReasons = map[string]bool{
+	"checkmate":             true,
+	"stalemate":             true,
+	"threefold repetition":  true,
+	"fifty-move rule":       true,
+	"insufficient material": true,
+	"wrong bishop":          true,
+}
+
+// Undo unmakes the last move of the game, putting back any captured piece
+// and restoring castling rights, the en passant square, the clocks and any
+// Crazyhouse reserve, and reopens the game if that move ended it. The move
+// is kept for Redo until another move is played. It returns the undone
+// move, or an error when there is no move to undo, or ErrGameOver when the
+// game ended off the board, by resignation or agreement, which taking back
+// a move does not undo.
+func Undo(g *GameState) (MoveRecord, error) {
+	if len(g.History) == 0 {
+		return MoveRecord{}, errors.New("no move to undo")
+	}
+	if g.Result != Ongoing && !boardEndReasons[g.EndReason] {
+		return MoveRecord{}, ErrGameOver
+	}
+	rec := g.History[len(g.History)-1]
+	if !rec.undo.saved {
+		// A history without undo state, as read back from JSON, is replayed
+		pos, err := g.PositionAt(len(g.History) - 1)
+		if err != nil {
+			return MoveRecord{}, err
+		}
+		undone := g.Undone
+		*g = *pos
+		g.Undone = append(undone, rec)
+		return rec, nil
+	}
+
+	if n := g.Positions[PositionKey(g)]; n <= 1 {
+		delete(g.Positions, PositionKey(g))
+	} else {
+		g.Positions[PositionKey(g)] = n - 1
+	}
+	g.CurrentPlayer = rec.Color
+	if rec.Color == Black {
+		g.FullmoveNumber--
+	}
+
+	m := rec.Move
+	if rec.Flags.Has(FlagDrop) {
+		g.Board[m.To.Row][m.To.Col] = Empty
+		g.Reserves[rec.Color][rec.Piece]++
+	} else {
+		g.Board[m.From.Row][m.From.Col] = rec.Piece
+		g.Board[m.To.Row][m.To.Col] = rec.Captured
+		if rec.Flags.Has(FlagEnPassant) {
+			g.Board[m.To.Row][m.To.Col] = Empty
+			g.Board[m.From.Row][m.To.Col] = rec.Captured
+		}
+		if rec.Flags.Has(FlagKingsideCastle) || rec.Flags.Has(FlagQueensideCastle) {
+			rookFrom, rookTo := castlingRookSquares(m.From, m.To)
+			g.Board[rookFrom.Row][rookFrom.Col] = g.Board[rookTo.Row][rookTo.Col]
+			g.Board[rookTo.Row][rookTo.Col] = Empty
+		}
+		if g.Variant == Crazyhouse && rec.Captured != Empty {
+			g.Reserves[rec.Color][PieceFromLetter(PieceLetter(rec.Captured), rec.Color)]--
+		}
+	}
+
+	g.Castling = rec.undo.castling
+	g.EnPassant = rec.undo.enPassant
+	g.HalfmoveClock = rec.undo.halfmoveClock
+	g.DrawOffer = rec.undo.drawOffer
+	g.Opening = rec.undo.opening
+	g.Result = Ongoing
+	g.EndReason = ""
+	g.InCheck = IsInCheck(g, g.CurrentPlayer)
+	g.History = g.History[:len(g.History)-1]
+	g.Undone = append(g.Undone, rec)
+	return rec, nil
+}
+
+// Redo plays the move most recently undone again, keeping its annotations
+// and variations. It fails when nothing is left to redo.
+func Redo(g *GameState) error {
+	if len(g.Undone) == 0 {
+		return errors.New("nothing to redo")
+	}
+	rec := g.Undone[len(g.Undone)-1]
+	undone := g.Undone[:len(g.Undone)-1]
+	if err := replay(g, []MoveRecord{rec}); err != nil {
+		return err
+	}
+	// Playing the move cleared the redo stack, which still holds the rest
+	g.Undone = undone
+	return nil
+}
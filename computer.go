@@ -0,0 +1,179 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/rigurd/chess"
+)
+
+// computerPlayer is the session of the side the server plays itself in a
+// game against the computer. Visitors' sessions are hex, so none can be
+// mistaken for it.
+const computerPlayer = "computer"
+
+// computerLevel is a difficulty the computer plays at: how deep and long it
+// searches, and how much noise blurs its judgement of the moves.
+type computerLevel struct {
+	name   string
+	limits chess.SearchLimits
+	engine bool // played by the engine for limits.Time, if one is configured
+}
+
+// computerLevels are the difficulties a game against the computer may be
+// started at, weakest first.
+var computerLevels = []computerLevel{
+	{"easy", chess.SearchLimits{Depth: 2, Noise: 150}, false},
+	{"medium", chess.SearchLimits{Depth: 4, Time: time.Second, Noise: 30}, false},
+	{"hard", chess.SearchLimits{Depth: 10, Time: 3 * time.Second}, true},
+}
+
+// defaultComputerLevel is the difficulty of a game started without one.
+const defaultComputerLevel = "medium"
+
+// lookupComputerLevel returns the difficulty of the given name, if any.
+func lookupComputerLevel(name string) (computerLevel, bool) {
+	i := slices.IndexFunc(computerLevels, func(l computerLevel) bool { return l.name == name })
+	if i < 0 {
+		return computerLevel{}, false
+	}
+	return computerLevels[i], true
+}
+
+// computerLevelOf returns the named difficulty, or the default one if
+// there is none by that name.
+func computerLevelOf(name string) computerLevel {
+	l, ok := lookupComputerLevel(name)
+	if !ok {
+		l, _ = lookupComputerLevel(defaultComputerLevel)
+	}
+	return l
+}
+
+// computerOpponent is how a new game against the computer is set up.
+type computerOpponent struct {
+	side  chess.PieceColor // the player's
+	level string
+}
+
+// errComputerVariant refuses a game against the computer it cannot play.
+var errComputerVariant = errors.New("the computer only plays standard chess")
+
+// parseOpponent reads who a new game of variant is played against:
+// opponent= "computer", or "" for another person, returning nil for a
+// person. Against the computer the player takes the side color= names, as
+// parseColorChoice reads it, at the difficulty level= names.
+func parseOpponent(opponent, color, level string, variant chess.Variant) (*computerOpponent, error) {
+	switch opponent {
+	case "":
+		return nil, nil
+	case "computer":
+	default:
+		return nil, errors.New(`opponent must be "computer" or absent`)
+	}
+	if variant != chess.Standard {
+		return nil, errComputerVariant
+	}
+	side, err := parseColorChoice(color)
+	if err != nil {
+		return nil, err
+	}
+	if level == "" {
+		level = defaultComputerLevel
+	}
+	if _, ok := lookupComputerLevel(level); !ok {
+		return nil, errors.New(`level must be "easy", "medium" or "hard"`)
+	}
+	return &computerOpponent{side: side, level: level}, nil
+}
+
+// seat seats session in the new game g as set up by o, and the computer at
+// the other side. It must be called from a command of g's goroutine.
+func (o *computerOpponent) seat(g *Game, session string) {
+	g.Players[o.side] = session
+	g.Players[chess.Opponent(o.side)] = computerPlayer
+	g.Settings.ComputerLevel = o.level
+}
+
+// computerSide returns the side the computer plays in g, if any. It must be
+// called from a command of g's goroutine.
+func (g *Game) computerSide() (chess.PieceColor, bool) {
+	return g.sideOf(computerPlayer)
+}
+
+// computerToMove reports whether g is going on with the computer to move.
+// It must be called from a command of g's goroutine.
+func (g *Game) computerToMove() bool {
+	return g.Result == chess.Ongoing && g.Players[g.CurrentPlayer] == computerPlayer
+}
+
+// startComputer has the computer think of its move in g if it is its turn
+// and it is not thinking already, on a goroutine of its own so the game's
+// other commands are not held up meanwhile. It must be called from a
+// command of g's goroutine.
+func (m *GameManager) startComputer(g *Game) {
+	if g.thinking || !g.computerToMove() || m.closing.Load() {
+		return
+	}
+	g.thinking = true
+	go m.playComputer(g, computerTurn{
+		pos:   chess.CopyPosition(&g.GameState),
+		fen:   g.FEN(),
+		line:  uciPositionOf(g),
+		level: computerLevelOf(g.Settings.ComputerLevel),
+	})
+}
+
+// computerTurn is a position of a game the computer is to move in, taken
+// from the game to think over away from its goroutine.
+type computerTurn struct {
+	pos   *chess.GameState
+	fen   string
+	line  uciPosition // the game up to pos, for the engine
+	level computerLevel
+}
+
+// playComputer plays the move the computer finds best in turn's position
+// of g, then saves g. If the game moved on meanwhile, as when a player
+// takes a move back, the computer thinks again instead.
+func (m *GameManager) playComputer(g *Game, turn computerTurn) {
+	move, ok := m.computerMove(g.ID, turn)
+	g.update(func() {
+		g.thinking = false
+		if ok && g.FEN() == turn.fen && g.computerToMove() {
+			if err := g.play(move); err != nil {
+				log.Printf("computer move in game %s: %v", g.ID, err)
+				return
+			}
+			m.audit(AuditEvent{
+				Time:    time.Now(),
+				Game:    g.ID,
+				Action:  "move",
+				Detail:  g.History[len(g.History)-1].SAN,
+				Session: computerPlayer,
+			})
+		}
+		m.startComputer(g)
+	})
+	m.Save(g)
+}
+
+// computerMove returns the move the computer plays in turn's position of
+// the game: the engine's at the levels it plays, unless it fails, and the
+// built-in search's otherwise. It returns false if there is no move.
+func (m *GameManager) computerMove(game string, turn computerTurn) (chess.Move, bool) {
+	if turn.level.engine && m.engine != nil {
+		uci, err := m.engine.play(game, turn.line, turn.level.limits.Time)
+		if err == nil {
+			var move chess.Move
+			if move, err = chess.ParseUCI(turn.pos, uci); err == nil {
+				return move, true
+			}
+		}
+		log.Printf("engine move in game %s: %v; searching for one instead", game, err)
+	}
+	found, ok := chess.Search(turn.pos, turn.level.limits)
+	return found.Move, ok
+}
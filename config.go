@@ -0,0 +1,337 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds the settings a server is started with.
+type Config struct {
+	Addr     string        // address to listen on, e.g. ":8080"
+	Store    string        // where games are kept: "memory" (the default), "sqlite", "postgres" or "redis"
+	StoreDSN string        // SQLite database file, PostgreSQL connection string or Redis URL
+	IdleTTL  time.Duration // how long a game may go without a move before it is ended, or 0 for ever
+	WALPath  string        // file moves are logged to before they are played, replayed after a crash, or "" for none
+	GRPCAddr string        // address to serve the gRPC API on, or "" for none
+
+	// DeletedRetention is how long deleted games are kept for restoring
+	// before they are purged, or 0 to keep them for ever.
+	DeletedRetention time.Duration
+
+	// AdminToken is the bearer token admin endpoints require, or "" for
+	// them to refuse everyone.
+	AdminToken string
+
+	// ShutdownTimeout is how long requests in flight are given to finish
+	// when the server is shut down.
+	ShutdownTimeout time.Duration
+
+	// CORSOrigins are the origins, e.g. "https://example.com", whose pages
+	// may call the APIs, or "*" for any. CORSMethods are the methods they
+	// may use, and with CORSCredentials set they send the session cookie,
+	// playing as the visitor.
+	CORSOrigins     []string
+	CORSMethods     []string
+	CORSCredentials bool
+
+	// WebhooksPrivate lets webhooks post to loopback and private
+	// addresses, which are refused so players cannot reach services behind
+	// the server.
+	WebhooksPrivate bool
+	// AccessLog logs every request answered, but for successful health
+	// probes.
+	AccessLog bool
+
+	// MoveLimit and CreateLimit limit how fast a session may move and
+	// start games, and MoveLimitIP and CreateLimitIP how fast the sessions
+	// of one IP address may together. Over them, requests are answered
+	// 429 Too Many Requests.
+	MoveLimit     rateLimit
+	MoveLimitIP   rateLimit
+	CreateLimit   rateLimit
+	CreateLimitIP rateLimit
+
+	// CSRFKey is the secret the CSRF tokens of the pages' forms are made
+	// with, or "" for a random one, which changes on each start.
+	CSRFKey string
+
+	// TLSCert and TLSKey are the files of the certificate the server is
+	// served over HTTPS with. Alternatively, AutocertHosts are the host
+	// names a certificate is got for from Let's Encrypt, kept in the
+	// AutocertCache directory. RedirectAddr is an address, e.g. ":80", to
+	// serve plain HTTP on, redirecting to HTTPS and answering the
+	// challenges of Let's Encrypt, or "" for none.
+	TLSCert       string
+	TLSKey        string
+	AutocertHosts []string
+	AutocertCache string
+	RedirectAddr  string
+
+	// The timeouts of http.Server, each 0 for none. WebSockets and streams
+	// are let outlive the read and write timeouts.
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+
+	// EnginePath is a UCI chess engine, e.g. Stockfish, to play the
+	// computer's hardest level and analyse positions with, or "" to search
+	// with the built-in search. EngineOptions are UCI options to set it up
+	// with, each "Name=Value", and with EnginePonder set it thinks on its
+	// opponent's time.
+	EnginePath    string
+	EngineOptions []string
+	EnginePonder  bool
+}
+
+// envPrefix starts the names of the environment variables settings are
+// read from: RIGURD_MOVE_LIMIT sets move-limit.
+const envPrefix = "RIGURD_"
+
+// stores are the values of the store setting.
+var stores = []string{"memory", "sqlite", "postgres", "redis"}
+
+// defaultConfig returns the settings of a server none are given for.
+func defaultConfig() Config {
+	return Config{
+		Addr:              ":8080",
+		Store:             "sqlite",
+		StoreDSN:          "rigurd.db",
+		WALPath:           "rigurd.wal",
+		IdleTTL:           24 * time.Hour,
+		DeletedRetention:  30 * 24 * time.Hour,
+		ShutdownTimeout:   15 * time.Second,
+		CORSMethods:       []string{"GET", "POST"},
+		AccessLog:         true,
+		MoveLimit:         rateLimit{N: 30, Per: 10 * time.Second},
+		MoveLimitIP:       rateLimit{N: 120, Per: 10 * time.Second},
+		CreateLimit:       rateLimit{N: 10, Per: time.Minute},
+		CreateLimitIP:     rateLimit{N: 30, Per: time.Minute},
+		AutocertCache:     "autocert",
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      time.Minute,
+		IdleTimeout:       2 * time.Minute,
+	}
+}
+
+// listValue is a flag.Value setting a list from its items separated by
+// commas.
+type listValue struct{ list *[]string }
+
+func (v listValue) String() string {
+	if v.list == nil {
+		return ""
+	}
+	return strings.Join(*v.list, ",")
+}
+
+func (v listValue) Set(s string) error {
+	*v.list = splitList(s)
+	return nil
+}
+
+// bindSettings defines a flag on fs for each setting of cfg, defaulting to
+// its value there. The flags' names are the settings' names in the
+// configuration file and, upper-cased, in the environment.
+func bindSettings(fs *flag.FlagSet, cfg *Config) {
+	fs.StringVar(&cfg.Addr, "addr", cfg.Addr, `address to serve the pages and APIs on, e.g. ":443" or "127.0.0.1:8080"`)
+	fs.StringVar(&cfg.Store, "store", cfg.Store, `where games are kept: "memory", "sqlite", "postgres" or "redis"`)
+	fs.StringVar(&cfg.StoreDSN, "dsn", cfg.StoreDSN, "SQLite database file, PostgreSQL connection string or Redis URL")
+	fs.StringVar(&cfg.WALPath, "wal", cfg.WALPath, `log of moves played, replayed after a crash, or "" for none`)
+	fs.DurationVar(&cfg.IdleTTL, "idle-ttl", cfg.IdleTTL, "end games nobody has moved in for this long, or 0 to keep them")
+	fs.DurationVar(&cfg.DeletedRetention, "deleted-retention", cfg.DeletedRetention, "how long deleted games can be restored before they are purged, or 0 for ever")
+	fs.StringVar(&cfg.AdminToken, "admin-token", cfg.AdminToken, "bearer token for the admin endpoints")
+	fs.StringVar(&cfg.GRPCAddr, "grpc", cfg.GRPCAddr, `address to serve the gRPC API on, e.g. ":9090", or "" for none; callers need an API key`)
+	fs.DurationVar(&cfg.ShutdownTimeout, "shutdown-timeout", cfg.ShutdownTimeout, "how long to let requests finish when shutting down")
+	fs.Var(listValue{&cfg.CORSOrigins}, "cors-origins", `comma-separated origins whose pages may call the APIs, e.g. "https://example.com", or "*" for any`)
+	fs.Var(listValue{&cfg.CORSMethods}, "cors-methods", "comma-separated methods pages on the CORS origins may use")
+	fs.BoolVar(&cfg.CORSCredentials, "cors-credentials", cfg.CORSCredentials, "let pages on the CORS origins play with the visitor's session; needs HTTPS")
+	fs.BoolVar(&cfg.WebhooksPrivate, "webhooks-private", cfg.WebhooksPrivate, "let webhooks post to loopback and private addresses")
+	fs.BoolVar(&cfg.AccessLog, "access-log", cfg.AccessLog, "log every request with its status, size and time taken")
+	fs.Var(&cfg.MoveLimit, "move-limit", `moves, clicks on the board included, a session may make, as N/period, or 0 for no limit`)
+	fs.Var(&cfg.MoveLimitIP, "move-limit-ip", "moves the sessions of one IP address may make together, as N/period, or 0 for no limit")
+	fs.Var(&cfg.CreateLimit, "create-limit", "games a session may start, as N/period, or 0 for no limit")
+	fs.Var(&cfg.CreateLimitIP, "create-limit-ip", "games the sessions of one IP address may start together, as N/period, or 0 for no limit")
+	fs.StringVar(&cfg.CSRFKey, "csrf-key", cfg.CSRFKey, "secret the pages' CSRF tokens are made with, or random on each start")
+	fs.StringVar(&cfg.TLSCert, "tls-cert", cfg.TLSCert, "certificate file to serve HTTPS with, with -tls-key")
+	fs.StringVar(&cfg.TLSKey, "tls-key", cfg.TLSKey, "key file of the -tls-cert certificate")
+	fs.Var(listValue{&cfg.AutocertHosts}, "autocert", "comma-separated host names to get certificates for from Let's Encrypt, serving HTTPS with them")
+	fs.StringVar(&cfg.AutocertCache, "autocert-cache", cfg.AutocertCache, "directory the -autocert certificates are kept in")
+	fs.StringVar(&cfg.RedirectAddr, "redirect-addr", cfg.RedirectAddr, `address to serve plain HTTP on, redirecting to HTTPS, e.g. ":80", which -autocert may need; "" for none`)
+	fs.DurationVar(&cfg.ReadHeaderTimeout, "read-header-timeout", cfg.ReadHeaderTimeout, "how long a client may take to send the headers of a request")
+	fs.DurationVar(&cfg.ReadTimeout, "read-timeout", cfg.ReadTimeout, "how long a client may take to send a whole request")
+	fs.DurationVar(&cfg.WriteTimeout, "write-timeout", cfg.WriteTimeout, "how long a response may take to be sent, but for WebSockets and streams")
+	fs.DurationVar(&cfg.IdleTimeout, "idle-timeout", cfg.IdleTimeout, "how long an idle keep-alive connection is kept open")
+	fs.StringVar(&cfg.EnginePath, "engine", cfg.EnginePath, `UCI engine, e.g. "stockfish", to play the hardest level and analyse with, or "" for the built-in search`)
+	fs.Var(listValue{&cfg.EngineOptions}, "engine-options", `comma-separated UCI options to set the engine up with, e.g. "Threads=2,Hash=64"`)
+	fs.BoolVar(&cfg.EnginePonder, "engine-ponder", cfg.EnginePonder, "let the engine think on its opponent's time")
+}
+
+// loadConfig returns the settings given by, each overriding the one
+// before, their defaults, the YAML file named by -config or $RIGURD_CONFIG,
+// the RIGURD_ environment variables and the command line args, once
+// checked.
+func loadConfig(name string, args []string) (Config, error) {
+	cfg := defaultConfig()
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	path := fs.String("config", os.Getenv(envPrefix+"CONFIG"), "YAML file of settings, keyed by flag name, which the environment and flags override")
+	bindSettings(fs, &cfg)
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+	if fs.NArg() > 0 {
+		return Config{}, fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+
+	// Start over from the defaults, the flags given being applied last
+	given := make(map[string]string)
+	fs.Visit(func(f *flag.Flag) { given[f.Name] = f.Value.String() })
+	cfg = defaultConfig()
+	if *path != "" {
+		if err := applyConfigFile(fs, *path); err != nil {
+			return Config{}, err
+		}
+	}
+	if err := applyEnv(fs); err != nil {
+		return Config{}, err
+	}
+	for name, value := range given {
+		fs.Set(name, value)
+	}
+	return cfg, cfg.validate()
+}
+
+// applyConfigFile sets the flags of fs named in the YAML file at path.
+func applyConfigFile(fs *flag.FlagSet, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var settings map[string]any
+	if err := yaml.Unmarshal(data, &settings); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		if name == "config" || fs.Lookup(name) == nil {
+			return fmt.Errorf("%s: unknown setting %q", path, name)
+		}
+		value, err := settingText(settings[name])
+		if err == nil {
+			err = fs.Set(name, value)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %s: %w", path, name, err)
+		}
+	}
+	return nil
+}
+
+// settingText returns a value of the configuration file as the flag would
+// be given it, lists with their items separated by commas.
+func settingText(v any) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case []any:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = fmt.Sprint(item)
+		}
+		return strings.Join(items, ","), nil
+	case map[string]any:
+		return "", errors.New("must be a value or a list")
+	}
+	return fmt.Sprint(v), nil
+}
+
+// applyEnv sets the flags of fs that have an environment variable set, named
+// as the flag upper-cased with underscores, e.g. RIGURD_IDLE_TTL.
+func applyEnv(fs *flag.FlagSet) error {
+	var errs []error
+	fs.VisitAll(func(f *flag.Flag) {
+		if f.Name == "config" {
+			return
+		}
+		env := envPrefix + strings.ToUpper(strings.ReplaceAll(f.Name, "-", "_"))
+		if value, ok := os.LookupEnv(env); ok {
+			if err := f.Value.Set(value); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", env, err))
+			}
+		}
+	})
+	return errors.Join(errs...)
+}
+
+// validate reports every setting the server cannot be started with, and
+// why, naming each as its flag.
+func (cfg Config) validate() error {
+	var errs []error
+	invalid := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	for _, a := range []struct{ name, addr string }{
+		{"addr", cfg.Addr}, {"grpc", cfg.GRPCAddr}, {"redirect-addr", cfg.RedirectAddr},
+	} {
+		if a.addr == "" {
+			continue
+		}
+		if _, port, err := net.SplitHostPort(a.addr); err != nil {
+			invalid("%s %q: want host:port, e.g. \":8080\"", a.name, a.addr)
+		} else if _, err := net.LookupPort("tcp", port); err != nil {
+			invalid("%s %q: unknown port %q", a.name, a.addr, port)
+		}
+	}
+
+	switch {
+	case cfg.Store != "" && !slices.Contains(stores, cfg.Store):
+		invalid("store %q: want one of %s", cfg.Store, strings.Join(stores, ", "))
+	case cfg.Store != "" && cfg.Store != "memory" && cfg.StoreDSN == "":
+		invalid("dsn: the %s store needs one", cfg.Store)
+	}
+
+	for _, d := range []struct {
+		name string
+		d    time.Duration
+	}{
+		{"idle-ttl", cfg.IdleTTL},
+		{"deleted-retention", cfg.DeletedRetention},
+		{"shutdown-timeout", cfg.ShutdownTimeout},
+		{"read-header-timeout", cfg.ReadHeaderTimeout},
+		{"read-timeout", cfg.ReadTimeout},
+		{"write-timeout", cfg.WriteTimeout},
+		{"idle-timeout", cfg.IdleTimeout},
+	} {
+		if d.d < 0 {
+			invalid("%s %s: must not be negative", d.name, d.d)
+		}
+	}
+
+	if cfg.AutocertCache == "" && len(cfg.AutocertHosts) > 0 {
+		invalid("autocert-cache: autocert needs a directory to keep the certificates in")
+	}
+	if err := checkEngine(cfg); err != nil {
+		errs = append(errs, err)
+	}
+	if err := checkCORS(cfg); err != nil {
+		errs = append(errs, err)
+	}
+	if err := checkTLS(cfg); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
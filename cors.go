@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// corsMaxAge is how long, in seconds, browsers may cache a preflight.
+const corsMaxAge = "600"
+
+// corsAllowedHeaders are the request headers cross-origin clients may send.
+const corsAllowedHeaders = "Accept, Content-Type, Authorization"
+
+// errCORSCredentials is returned by NewServer when credentials are allowed
+// for every origin, which browsers refuse and would expose every player's
+// session to any site.
+var errCORSCredentials = errors.New("CORS credentials cannot be allowed for every origin")
+
+// corsPath reports whether the page at path is part of an API browsers on
+// other origins may call: the JSON API, GraphQL and the OpenAPI spec.
+func corsPath(path string) bool {
+	return strings.HasPrefix(path, "/api/") || path == "/graphql" || path == "/openapi.json"
+}
+
+// corsOrigin returns the value of Access-Control-Allow-Origin for a request
+// from origin, or "" if the origin is not allowed.
+func (s *Server) corsOrigin(origin string) string {
+	for _, allowed := range s.config.CORSOrigins {
+		switch {
+		case allowed == "*":
+			return "*"
+		case strings.EqualFold(allowed, origin):
+			return origin
+		}
+	}
+	return ""
+}
+
+// cors adds the CORS headers to the response to a request from an allowed
+// origin to an API, and answers its preflight requests. It reports whether
+// the request was answered.
+func (s *Server) cors(w http.ResponseWriter, r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || !corsPath(r.URL.Path) {
+		return false
+	}
+	h := w.Header()
+	h.Add("Vary", "Origin")
+	preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+	if preflight {
+		h.Add("Vary", "Access-Control-Request-Method")
+		h.Add("Vary", "Access-Control-Request-Headers")
+	}
+
+	allowed := s.corsOrigin(origin)
+	if allowed == "" {
+		if preflight {
+			// Without the headers the browser refuses the request
+			w.WriteHeader(http.StatusNoContent)
+		}
+		return preflight
+	}
+	h.Set("Access-Control-Allow-Origin", allowed)
+	if s.config.CORSCredentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+	if !preflight {
+		h.Set("Access-Control-Expose-Headers", "Location, Retry-After")
+		return false
+	}
+	h.Set("Access-Control-Allow-Methods", strings.Join(s.config.CORSMethods, ", "))
+	h.Set("Access-Control-Allow-Headers", corsAllowedHeaders)
+	h.Set("Access-Control-Max-Age", corsMaxAge)
+	w.WriteHeader(http.StatusNoContent)
+	return true
+}
+
+// checkCORS reports a CORS configuration browsers would refuse.
+func checkCORS(cfg Config) error {
+	if cfg.CORSCredentials && slices.Contains(cfg.CORSOrigins, "*") {
+		return errCORSCredentials
+	}
+	return nil
+}
+
+// splitList splits a comma-separated flag value into its trimmed, non-empty
+// items.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/a-h/templ"
+	"github.com/rigurd/chess"
+)
+
+// reserveOrder is the order in which reserve pieces are listed.
+var reserveOrder = []string{"P", "N", "B", "R", "Q"}
+
+// handleDrop drops a reserve piece onto a square. When no square is given it
+// toggles the piece as the pending drop so the next board click places it.
+func (s *Server) handleDrop(w http.ResponseWriter, r *http.Request, g *Game) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.requireRate(w, r, rateMoves) {
+		return
+	}
+
+	g.update(func() {
+		if !requireSide(w, r, g, g.CurrentPlayer) {
+			return
+		}
+
+		if g.Result != chess.Ongoing {
+			http.Error(w, "Game is over", http.StatusConflict)
+			return
+		}
+
+		if touchMoveLocked(g) {
+			http.Error(w, "Touch-move: the selected piece must be moved", http.StatusConflict)
+			return
+		}
+
+		p := chess.PieceFromLetter(r.FormValue("piece"), g.CurrentPlayer)
+		if r.FormValue("row") == "" || r.FormValue("col") == "" {
+			if g.SelectedDrop == p || g.Reserves[g.CurrentPlayer][p] == 0 {
+				g.SelectedDrop = chess.Empty
+			} else {
+				g.SelectedDrop = p
+				g.SelectedSquare = nil
+			}
+			templ.Handler(chessboardWithLabels(g, threatSquares(g, showThreats(r)))).ServeHTTP(w, r)
+			return
+		}
+
+		row, err1 := strconv.Atoi(r.FormValue("row"))
+		col, err2 := strconv.Atoi(r.FormValue("col"))
+		to := chess.Square{Row: row, Col: col}
+		if err1 != nil || err2 != nil || !chess.IsValidDrop(&g.GameState, p, to) {
+			http.Error(w, "Illegal drop", http.StatusBadRequest)
+			return
+		}
+		if err := g.play(chess.Move{To: to, Drop: p}); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		s.auditMoves(r, g, len(g.History)-1)
+		g.SelectedDrop = chess.Empty
+		templ.Handler(chessboardWithLabels(g, threatSquares(g, showThreats(r)))).ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/rigurd/chess"
+)
+
+func TestHandleDrop(t *testing.T) {
+	s, ts := newTestServer(t)
+	g := newTestGame(t, s, "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR[Nn] w KQkq - 0 1")
+	white, black := newTestClient(t, s, ts), newTestClient(t, s, ts)
+	path := gamePath(g, "/drop")
+	drop := func(piece, row, col string) url.Values {
+		return url.Values{"piece": {piece}, "row": {row}, "col": {col}}
+	}
+
+	tests := []struct {
+		name   string
+		client *testClient
+		method string
+		form   url.Values
+		want   int
+	}{
+		{"not a POST", white, http.MethodGet, nil, http.StatusMethodNotAllowed},
+		{"onto an occupied square", white, http.MethodPost, drop("N", "6", "4"), http.StatusBadRequest},
+		{"a piece not in reserve", white, http.MethodPost, drop("Q", "4", "4"), http.StatusBadRequest},
+		{"a knight onto e4", white, http.MethodPost, drop("N", "4", "4"), http.StatusOK},
+		{"out of turn", white, http.MethodPost, drop("N", "4", "3"), http.StatusForbidden},
+		{"the reply, a knight onto d5", black, http.MethodPost, drop("N", "3", "3"), http.StatusOK},
+	}
+	for _, tt := range tests {
+		if status, body := tt.client.do(tt.method, path, tt.form, nil); status != tt.want {
+			t.Fatalf("%s: status %d, want %d: %s", tt.name, status, tt.want, body)
+		}
+	}
+
+	var fen string
+	g.do(func() { fen = g.FEN() })
+	if want := "rnbqkbnr/pppppppp/8/3n4/4N3/8/PPPPPPPP/RNBQKBNR[] w KQkq - 2 2"; fen != want {
+		t.Errorf("FEN after the drops %q, want %q", fen, want)
+	}
+	g.do(func() {
+		if g.Players[chess.White] != white.session() || g.Players[chess.Black] != black.session() {
+			t.Error("the players dropping did not take their sides")
+		}
+	})
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"net/url"
+)
+
+const (
+	// csrfField is the form field, or for multipart forms the query
+	// parameter, a page's forms send their CSRF token in.
+	csrfField = "csrf_token"
+	// csrfHeader is the header htmx sends the CSRF token in.
+	csrfHeader = "X-CSRF-Token"
+)
+
+// csrfTokenKey is the context key of the request's CSRF token.
+type csrfTokenKey struct{}
+
+// newCSRFKey returns the key CSRF tokens are made with: key if it is set,
+// so tokens outlive restarts and are shared between servers, otherwise a
+// random one.
+func newCSRFKey(key string) []byte {
+	if key != "" {
+		return []byte(key)
+	}
+	b := make([]byte, 32)
+	rand.Read(b)
+	return b
+}
+
+// csrfTokenFor returns the CSRF token of session, which only the server can
+// make, so a page of another site cannot know it.
+func (s *Server) csrfTokenFor(session string) string {
+	mac := hmac.New(sha256.New, s.csrfKey)
+	mac.Write([]byte("csrf:" + session))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// withCSRFToken attaches the CSRF token of the request's session to it, for
+// the pages to embed in their forms.
+func (s *Server) withCSRFToken(r *http.Request) *http.Request {
+	token := s.csrfTokenFor(sessionOf(r))
+	return r.WithContext(context.WithValue(r.Context(), csrfTokenKey{}, token))
+}
+
+// csrfToken returns the CSRF token of the request being rendered, for the
+// forms of a page.
+func csrfToken(ctx context.Context) string {
+	token, _ := ctx.Value(csrfTokenKey{}).(string)
+	return token
+}
+
+// csrfHeaders returns the hx-headers value making htmx send the CSRF token
+// with every request of a page.
+func csrfHeaders(ctx context.Context) string {
+	b, _ := json.Marshal(map[string]string{csrfHeader: csrfToken(ctx)})
+	return string(b)
+}
+
+// csrfAction returns the action of a multipart form posting to path, with
+// the CSRF token in its query as the body is not read before the check.
+func csrfAction(ctx context.Context, path string) string {
+	return path + "?" + url.Values{csrfField: {csrfToken(ctx)}}.Encode()
+}
+
+// safeMethod reports whether method only reads, and so needs no CSRF check.
+func safeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// sameOrigin reports whether the request came from a page of this server,
+// or from a client that is not a browser and sends no Origin.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return r.Header.Get("Sec-Fetch-Site") != "cross-site"
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host == r.Host
+}
+
+// checkCSRF answers 403 Forbidden to a request changing something with the
+// visitor's session cookie that another site may have made the browser
+// send. The APIs refuse requests from origins CORS does not allow, as
+// scripts calling them have no token; the pages want the session's token,
+// in the X-CSRF-Token header htmx sends, or in the form, but for JSON
+// bodies, which other sites cannot send them.
+func (s *Server) checkCSRF(w http.ResponseWriter, r *http.Request) bool {
+	if safeMethod(r.Method) || s.isAdmin(r) {
+		return true
+	}
+	if corsPath(r.URL.Path) {
+		if sameOrigin(r) || s.corsOrigin(r.Header.Get("Origin")) != "" {
+			return true
+		}
+		writeAPIError(w, http.StatusForbidden, codeForbidden, "cross-origin request refused")
+		return false
+	}
+
+	want := csrfToken(r.Context())
+	token := r.Header.Get(csrfHeader)
+	if token == "" {
+		switch mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType {
+		case "application/json":
+			if sameOrigin(r) {
+				return true
+			}
+		case "multipart/form-data":
+			token = r.URL.Query().Get(csrfField)
+		default:
+			token = r.PostFormValue(csrfField)
+		}
+	}
+	if !hmac.Equal([]byte(token), []byte(want)) {
+		http.Error(w, "Invalid or missing CSRF token; reload the page", http.StatusForbidden)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"time"
+)
+
+// purgeInterval is how often games deleted for longer than the retention
+// period are purged.
+const purgeInterval = time.Hour
+
+// handleDeleteGame hides the game from listings, on behalf of one of its
+// players or an admin. The game can still be opened by its link, and is
+// kept until purged so it can be restored meanwhile.
+func (s *Server) handleDeleteGame(w http.ResponseWriter, r *http.Request, g *Game) {
+	s.setDeleted(w, r, g, true)
+}
+
+// handleRestoreGame lists a deleted game again, on behalf of one of its
+// players or an admin.
+func (s *Server) handleRestoreGame(w http.ResponseWriter, r *http.Request, g *Game) {
+	s.setDeleted(w, r, g, false)
+}
+
+// setDeleted deletes or restores g for handleDeleteGame and
+// handleRestoreGame.
+func (s *Server) setDeleted(w http.ResponseWriter, r *http.Request, g *Game, deleted bool) {
+	if r.Method != http.MethodPost {
+		writeAPIError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	action := "restore"
+	if deleted {
+		action = "delete"
+	}
+	g.do(func() {
+		if !s.isAdmin(r) && !requireAPIPlayer(w, r, g) {
+			return
+		}
+		var err error
+		if deleted {
+			err = s.games.store.Delete(g.ID, time.Now())
+		} else {
+			err = s.games.store.Restore(g.ID)
+		}
+		if err != nil {
+			writeAPIError(w, http.StatusInternalServerError, codeInternal, "could not "+action+" the game: "+err.Error())
+			return
+		}
+		s.audit(r, g, action, "")
+		writeJSON(w, map[string]string{"status": action + "d"})
+	})
+}
+
+// purgeDeleted destroys the games deleted for longer than retention every
+// purgeInterval, forever.
+func (m *GameManager) purgeDeleted(retention time.Duration) {
+	ticker := time.NewTicker(purgeInterval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		ids, err := m.store.Purge(now.Add(-retention))
+		if err != nil {
+			log.Printf("purging deleted games: %v", err)
+			continue
+		}
+		for _, id := range ids {
+			m.forget(id)
+		}
+		if len(ids) > 0 {
+			log.Printf("purged %d games deleted over %v ago", len(ids), retention)
+		}
+	}
+}
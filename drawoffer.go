@@ -0,0 +1,54 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/a-h/templ"
+	"github.com/rigurd/chess"
+)
+
+// handleOfferDraw records a draw offer from the player to move.
+func (s *Server) handleOfferDraw(w http.ResponseWriter, r *http.Request, g *Game) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	g.update(func() {
+		if !requireSide(w, r, g, g.CurrentPlayer) {
+			return
+		}
+		chess.OfferDraw(&g.GameState)
+		s.audit(r, g, "offer-draw", "")
+		templ.Handler(chessboardWithLabels(g, threatSquares(g, showThreats(r)))).ServeHTTP(w, r)
+	})
+}
+
+// handleRespondDraw accepts (accept=1) or declines a pending draw offer on
+// behalf of the player who did not make it.
+func (s *Server) handleRespondDraw(w http.ResponseWriter, r *http.Request, g *Game) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	g.update(func() {
+		if g.DrawOffer != "" && !requireSide(w, r, g, chess.Opponent(g.DrawOffer)) {
+			return
+		}
+		accept := r.FormValue("accept") == "1"
+		if !chess.RespondDraw(&g.GameState, accept) {
+			http.Error(w, "No draw offer pending", http.StatusConflict)
+			return
+		}
+		if accept {
+			s.audit(r, g, "accept-draw", "")
+		} else {
+			s.audit(r, g, "decline-draw", "")
+		}
+		if g.Result != chess.Ongoing {
+			g.clearSelection()
+		}
+		templ.Handler(chessboardWithLabels(g, threatSquares(g, showThreats(r)))).ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,551 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rigurd/chess"
+)
+
+const (
+	// engineHandshakeTimeout is how long an engine is given to start, and
+	// to answer isready.
+	engineHandshakeTimeout = 10 * time.Second
+	// engineGrace is how long past its time an engine is given to answer
+	// go before it is taken to hang, and restarted.
+	engineGrace = 5 * time.Second
+	// enginePonderLimit is how long the engine ponders the reply it expects
+	// to a move before giving up on the player making it.
+	enginePonderLimit = time.Minute
+	// engineMaxBackoff bounds the wait between starts of an engine that
+	// keeps failing.
+	engineMaxBackoff = time.Minute
+)
+
+var (
+	errEngineExited  = errors.New("the engine exited")
+	errEngineTimeout = errors.New("the engine did not answer in time")
+	errEngineClosed  = errors.New("the engine is shut down")
+	errEngineNoMove  = errors.New("the engine found no move")
+)
+
+// checkEngine reports an engine configuration that cannot work: an engine
+// not found, or options not given as Name=Value.
+func checkEngine(cfg Config) error {
+	if cfg.EnginePath == "" {
+		if len(cfg.EngineOptions) > 0 || cfg.EnginePonder {
+			return errors.New("engine-options, engine-ponder: no engine is configured")
+		}
+		return nil
+	}
+	var errs []error
+	if _, err := exec.LookPath(cfg.EnginePath); err != nil {
+		errs = append(errs, fmt.Errorf("engine %q: %w", cfg.EnginePath, err))
+	}
+	for _, option := range cfg.EngineOptions {
+		if name, _, ok := strings.Cut(option, "="); !ok || strings.TrimSpace(name) == "" {
+			errs = append(errs, fmt.Errorf("engine-options %q: want Name=Value", option))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// uciPosition is a game as an engine is given it: the position it started
+// from and its moves since, in UCI, so the engine knows of repetitions.
+type uciPosition struct {
+	startFEN string
+	moves    []string
+}
+
+// uciPositionOf returns g as an engine is given it. It must be called from
+// a command of g's goroutine.
+func uciPositionOf(g *Game) uciPosition {
+	pos := uciPosition{startFEN: g.StartFEN, moves: make([]string, len(g.History))}
+	if pos.startFEN == "" {
+		pos.startFEN = chess.StartFEN
+	}
+	for i, rec := range g.History {
+		pos.moves[i] = chess.UCI(rec.Move)
+	}
+	return pos
+}
+
+// command returns the UCI position command setting the engine up at pos.
+func (pos uciPosition) command() string {
+	cmd := "position fen " + pos.startFEN
+	if len(pos.moves) > 0 {
+		cmd += " moves " + strings.Join(pos.moves, " ")
+	}
+	return cmd
+}
+
+// then returns pos with moves played.
+func (pos uciPosition) then(moves ...string) uciPosition {
+	return uciPosition{startFEN: pos.startFEN, moves: append(pos.moves[:len(pos.moves):len(pos.moves)], moves...)}
+}
+
+// uciResult is an engine's answer to go: its best move and the reply it
+// expects, with the depth, score and principal variation it last gave.
+type uciResult struct {
+	bestMove string
+	ponder   string
+	depth    int
+	cp       *int // score in centipawns for the side to move, or
+	mate     *int // moves to mate, negative when being mated
+	pv       []string
+}
+
+// parseInfo takes the depth, score and principal variation from the fields
+// of an info line, if it gives a score; lines about the move being searched
+// do not.
+func (res *uciResult) parseInfo(fields []string) {
+	var info uciResult
+	scored := false
+	for i := 0; i < len(fields); i++ {
+		switch fields[i] {
+		case "depth":
+			if i+1 < len(fields) {
+				info.depth, _ = strconv.Atoi(fields[i+1])
+				i++
+			}
+		case "score":
+			if i+2 < len(fields) {
+				n, err := strconv.Atoi(fields[i+2])
+				switch {
+				case err != nil:
+				case fields[i+1] == "cp":
+					info.cp, scored = &n, true
+				case fields[i+1] == "mate":
+					info.mate, scored = &n, true
+				}
+				i += 2
+			}
+		case "pv":
+			info.pv = fields[i+1:]
+			i = len(fields)
+		}
+	}
+	if scored {
+		res.depth, res.cp, res.mate, res.pv = info.depth, info.cp, info.mate, info.pv
+	}
+}
+
+// uciProcess is a chess engine run as a child process, spoken to in the
+// Universal Chess Interface over its standard input and output.
+type uciProcess struct {
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	lines   chan string     // written by the engine, closed once it exits
+	name    string          // as the engine gives it
+	options map[string]bool // the engine's options, by lower-case name
+}
+
+// startUCI starts the engine at path and introduces itself, setting the
+// options given as "Name=Value", and Ponder if ponder is set.
+func startUCI(path string, options []string, ponder bool) (*uciProcess, error) {
+	cmd := exec.Command(path)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	p := &uciProcess{cmd: cmd, stdin: stdin, lines: make(chan string, 64), name: path, options: make(map[string]bool)}
+	go func() {
+		sc := bufio.NewScanner(stdout)
+		for sc.Scan() {
+			p.lines <- strings.TrimSpace(sc.Text())
+		}
+		close(p.lines)
+		cmd.Wait()
+	}()
+
+	if err := p.handshake(options, ponder); err != nil {
+		p.close()
+		return nil, err
+	}
+	return p, nil
+}
+
+// handshake asks the engine to speak UCI, sets its options up and waits
+// for it to be ready.
+func (p *uciProcess) handshake(options []string, ponder bool) error {
+	deadline := time.Now().Add(engineHandshakeTimeout)
+	if err := p.send("uci"); err != nil {
+		return err
+	}
+	for {
+		line, err := p.readLine(deadline)
+		if err != nil {
+			return fmt.Errorf("starting the engine: %w", err)
+		}
+		if name, ok := strings.CutPrefix(line, "id name "); ok {
+			p.name = name
+		} else if option, ok := strings.CutPrefix(line, "option name "); ok {
+			name, _, _ := strings.Cut(option, " type ")
+			p.options[strings.ToLower(name)] = true
+		} else if line == "uciok" {
+			break
+		}
+	}
+	if ponder {
+		options = append([]string{"Ponder=true"}, options...)
+	}
+	for _, option := range options {
+		name, value, _ := strings.Cut(option, "=")
+		if !p.options[strings.ToLower(name)] {
+			return fmt.Errorf("%s has no option %q", p.name, name)
+		}
+		if err := p.send("setoption name " + name + " value " + value); err != nil {
+			return err
+		}
+	}
+	return p.isReady(deadline)
+}
+
+// send writes a command to the engine.
+func (p *uciProcess) send(cmd string) error {
+	_, err := io.WriteString(p.stdin, cmd+"\n")
+	return err
+}
+
+// readLine returns the next line the engine writes, if it does before the
+// deadline.
+func (p *uciProcess) readLine(deadline time.Time) (string, error) {
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+	select {
+	case line, ok := <-p.lines:
+		if !ok {
+			return "", errEngineExited
+		}
+		return line, nil
+	case <-timer.C:
+		return "", errEngineTimeout
+	}
+}
+
+// isReady waits for the engine to be done with the commands sent so far.
+func (p *uciProcess) isReady(deadline time.Time) error {
+	if err := p.send("isready"); err != nil {
+		return err
+	}
+	for {
+		line, err := p.readLine(deadline)
+		if err != nil {
+			return err
+		}
+		if line == "readyok" {
+			return nil
+		}
+	}
+}
+
+// bestMove reads the engine's info until its bestmove, which must come
+// before the deadline.
+func (p *uciProcess) bestMove(deadline time.Time) (uciResult, error) {
+	var res uciResult
+	for {
+		line, err := p.readLine(deadline)
+		if err != nil {
+			return uciResult{}, err
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "info":
+			res.parseInfo(fields[1:])
+		case "bestmove":
+			if len(fields) < 2 || fields[1] == "(none)" {
+				return uciResult{}, errEngineNoMove
+			}
+			res.bestMove = fields[1]
+			if len(fields) >= 4 && fields[2] == "ponder" {
+				res.ponder = fields[3]
+			}
+			return res, nil
+		}
+	}
+}
+
+// close asks the engine to quit, killing it if it does not at once.
+func (p *uciProcess) close() {
+	p.send("quit")
+	p.stdin.Close()
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case _, ok := <-p.lines:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			p.cmd.Process.Kill()
+			for range p.lines {
+			}
+			return
+		}
+	}
+}
+
+// enginePonder is the search an engine is pondering: the position after
+// the move it played and the reply it expects, in the game it played it.
+type enginePonder struct {
+	game  string
+	pos   string // the position command of the position pondered
+	wait  time.Duration
+	timer *time.Timer // stops the pondering after enginePonderLimit
+}
+
+// engine runs a UCI engine, such as Stockfish, for the computer to play
+// with and to analyse positions. It searches for one game at a time, the
+// others waiting their turn, and restarts the engine when it exits or
+// stops answering, waiting longer between starts while it keeps failing.
+type engine struct {
+	path    string
+	options []string // "Name=Value"
+	ponder  bool     // whether to think on the player's time
+
+	mu        sync.Mutex    // held for each search
+	proc      *uciProcess   // nil until started, and once failed
+	game      string        // the game last searched
+	pondering *enginePonder // nil unless pondering
+	failures  int           // in a row
+	retry     time.Time     // when the engine may be started again
+	closed    bool
+}
+
+// newEngine starts the engine cfg configures, if any, so a misconfigured
+// one is reported at once.
+func newEngine(cfg Config) (*engine, error) {
+	if cfg.EnginePath == "" {
+		return nil, nil
+	}
+	e := &engine{path: cfg.EnginePath, options: cfg.EngineOptions, ponder: cfg.EnginePonder}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, err := e.running(); err != nil {
+		return nil, err
+	}
+	log.Printf("using engine %s", e.proc.name)
+	return e, nil
+}
+
+// engineBackoff returns how long to wait before starting an engine that
+// failed failures times in a row: not at all after its first failure, then
+// from a second on, doubling up to engineMaxBackoff.
+func engineBackoff(failures int) time.Duration {
+	if failures <= 1 {
+		return 0
+	}
+	return min(time.Second<<min(failures-2, 10), engineMaxBackoff)
+}
+
+// running returns the engine's process, starting it if it is not running.
+// It must be called with e.mu held.
+func (e *engine) running() (*uciProcess, error) {
+	switch {
+	case e.closed:
+		return nil, errEngineClosed
+	case e.proc != nil:
+		return e.proc, nil
+	case time.Now().Before(e.retry):
+		return nil, fmt.Errorf("the engine failed %d times in a row; starting it again in %s", e.failures, time.Until(e.retry).Round(time.Second))
+	}
+	proc, err := startUCI(e.path, e.options, e.ponder)
+	if err != nil {
+		e.failed(err)
+		return nil, err
+	}
+	e.proc = proc
+	return proc, nil
+}
+
+// failed drops the engine's process after err, to be started again when
+// next needed once the backoff passed. It must be called with e.mu held.
+func (e *engine) failed(err error) {
+	log.Printf("engine %s failed: %v", e.path, err)
+	if e.proc != nil {
+		e.proc.close()
+		e.proc = nil
+	}
+	e.stopTimer()
+	e.pondering = nil
+	e.game = ""
+	e.failures++
+	e.retry = time.Now().Add(engineBackoff(e.failures))
+}
+
+// ready returns the engine's process ready to search the game, done with
+// any pondering, and checks it answers, starting it again at once if not.
+// It must be called with e.mu held.
+func (e *engine) ready(game string) (*uciProcess, error) {
+	for attempt := 0; ; attempt++ {
+		proc, err := e.running()
+		if err != nil {
+			return nil, err
+		}
+		if err = e.stopPondering(proc); err == nil && game != e.game {
+			err = proc.send("ucinewgame")
+		}
+		if err == nil {
+			err = proc.isReady(time.Now().Add(engineHandshakeTimeout))
+		}
+		if err == nil {
+			e.game = game
+			return proc, nil
+		}
+		e.failed(err)
+		if attempt > 0 {
+			return nil, err
+		}
+	}
+}
+
+// search has the engine search pos of the game with the go arguments,
+// e.g. "movetime 1000", and returns its answer, which must come within
+// wait. It must be called with e.mu held.
+func (e *engine) search(game string, pos uciPosition, goArgs string, wait time.Duration) (uciResult, error) {
+	proc, err := e.ready(game)
+	if err != nil {
+		return uciResult{}, err
+	}
+	if err = proc.send(pos.command()); err == nil {
+		err = proc.send("go " + goArgs)
+	}
+	var res uciResult
+	if err == nil {
+		res, err = proc.bestMove(time.Now().Add(wait + engineGrace))
+	}
+	if err != nil && !errors.Is(err, errEngineNoMove) {
+		e.failed(err)
+		return uciResult{}, err
+	}
+	e.failures = 0
+	return res, err
+}
+
+// play returns the engine's move in pos of the game, searched for the
+// given time, in UCI. With pondering on, the engine goes on to think of
+// its answer to the reply it expects, and if that is the move next asked
+// of it, it has the move ready sooner.
+func (e *engine) play(game string, pos uciPosition, movetime time.Duration) (string, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	goArgs := "movetime " + strconv.FormatInt(movetime.Milliseconds(), 10)
+
+	var res uciResult
+	var err error
+	if p := e.pondering; p != nil && p.game == game && p.pos == pos.command() && e.proc != nil {
+		// The reply expected was played: the search goes on as for real
+		e.stopTimer()
+		e.pondering = nil
+		if err = e.proc.send("ponderhit"); err == nil {
+			res, err = e.proc.bestMove(time.Now().Add(p.wait + engineGrace))
+		}
+		if err != nil {
+			e.failed(err)
+			return "", err
+		}
+	} else if res, err = e.search(game, pos, goArgs, movetime); err != nil {
+		return "", err
+	}
+
+	if e.ponder && res.ponder != "" {
+		pondered := pos.then(res.bestMove, res.ponder)
+		if err := e.ponderOn(game, pondered, goArgs, movetime); err != nil {
+			e.failed(err)
+		}
+	}
+	return res.bestMove, nil
+}
+
+// ponderOn has the engine ponder pos of the game, to search it with the go
+// arguments once its opponent plays into it. It must be called with e.mu
+// held.
+func (e *engine) ponderOn(game string, pos uciPosition, goArgs string, wait time.Duration) error {
+	if err := e.proc.send(pos.command()); err != nil {
+		return err
+	}
+	if err := e.proc.send("go ponder " + goArgs); err != nil {
+		return err
+	}
+	p := &enginePonder{game: game, pos: pos.command(), wait: wait}
+	p.timer = time.AfterFunc(enginePonderLimit, func() {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		if e.pondering == p && e.proc != nil {
+			if err := e.stopPondering(e.proc); err != nil {
+				e.failed(err)
+			}
+		}
+	})
+	e.pondering = p
+	return nil
+}
+
+// stopPondering stops the engine pondering, if it is, waiting for the
+// move it then gives, which is not played. It must be called with e.mu
+// held.
+func (e *engine) stopPondering(proc *uciProcess) error {
+	if e.pondering == nil {
+		return nil
+	}
+	e.stopTimer()
+	e.pondering = nil
+	if err := proc.send("stop"); err != nil {
+		return err
+	}
+	if _, err := proc.bestMove(time.Now().Add(engineGrace)); err != nil && !errors.Is(err, errEngineNoMove) {
+		return err
+	}
+	return nil
+}
+
+// stopTimer stops the pondering from being given up on. It must be called
+// with e.mu held.
+func (e *engine) stopTimer() {
+	if e.pondering != nil {
+		e.pondering.timer.Stop()
+	}
+}
+
+// analyse returns the engine's analysis of pos of the game, searched for
+// the given time.
+func (e *engine) analyse(game string, pos uciPosition, movetime time.Duration) (uciResult, string, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	res, err := e.search(game, pos, "movetime "+strconv.FormatInt(movetime.Milliseconds(), 10), movetime)
+	if err != nil {
+		return uciResult{}, "", err
+	}
+	return res, e.proc.name, nil
+}
+
+// close stops the engine for good, as the server shuts down.
+func (e *engine) close() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.stopTimer()
+	e.pondering = nil
+	e.closed = true
+	if e.proc != nil {
+		e.proc.close()
+		e.proc = nil
+	}
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"hash/fnv"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/rigurd/chess"
+)
+
+const (
+	// maxBulkPGNSize bounds a bulk import, which may hold many thousands of
+	// games.
+	maxBulkPGNSize = 64 << 20
+	// maxSearchResults bounds the games returned by one search.
+	maxSearchResults = 100
+	// maxImportErrors bounds the per-game errors reported by one import.
+	maxImportErrors = 20
+)
+
+// dbGame is a game stored in the game database. The moves are kept as PGN
+// so the game can be reloaded or downloaded as it was indexed.
+type dbGame struct {
+	ID     int           `json:"id"`
+	Tags   chess.PGNTags `json:"tags"`
+	Result string        `json:"result"`
+	ECO    chess.ECO     `json:"eco"`
+	Plies  int           `json:"plies"`
+	PGN    string        `json:"-"`
+}
+
+// gameDB is an in-memory collection of imported games, indexed by player,
+// result, ECO code and every position reached, so large collections can be
+// searched. Index values are the positions of games in games.
+type gameDB struct {
+	mu        sync.RWMutex
+	games     []dbGame
+	byPlayer  map[string][]int
+	byResult  map[string][]int
+	byECO     map[string][]int
+	byPosHash map[uint64][]int
+}
+
+// newGameDB returns an empty game database.
+func newGameDB() *gameDB {
+	return &gameDB{
+		byPlayer:  make(map[string][]int),
+		byResult:  make(map[string][]int),
+		byECO:     make(map[string][]int),
+		byPosHash: make(map[uint64][]int),
+	}
+}
+
+// positionHash hashes a position key for the position index, which would
+// otherwise hold a full key per position of every game.
+func positionHash(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return h.Sum64()
+}
+
+// add stores a parsed game and indexes it, returning its ID.
+func (db *gameDB) add(g *chess.GameState, tags chess.PGNTags) int {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	id := len(db.games)
+	rec := dbGame{
+		ID:     id,
+		Tags:   tags,
+		Result: chess.PGNResult(g.Result),
+		ECO:    g.Opening,
+		Plies:  len(g.History),
+		PGN:    g.PGN(tags),
+	}
+	db.games = append(db.games, rec)
+
+	white := strings.ToLower(strings.TrimSpace(tags.White))
+	black := strings.ToLower(strings.TrimSpace(tags.Black))
+	players := []string{white}
+	if black != white {
+		players = append(players, black)
+	}
+	for _, p := range players {
+		if p != "" && p != "?" {
+			db.byPlayer[p] = append(db.byPlayer[p], id)
+		}
+	}
+	db.byResult[rec.Result] = append(db.byResult[rec.Result], id)
+	if rec.ECO.Code != "" {
+		db.byECO[rec.ECO.Code] = append(db.byECO[rec.ECO.Code], id)
+	}
+	// Positions holds each position of the game once, with its count
+	hashes := make(map[uint64]bool, len(g.Positions))
+	for key := range g.Positions {
+		hashes[positionHash(key)] = true
+	}
+	for h := range hashes {
+		db.byPosHash[h] = append(db.byPosHash[h], id)
+	}
+	return id
+}
+
+// gameQuery narrows a search; empty fields match every game.
+type gameQuery struct {
+	Player   string
+	Result   string
+	ECO      string
+	Position *chess.GameState // games that reached this position
+}
+
+// search returns the stored games matching every field of q, in import
+// order, up to limit games.
+func (db *gameDB) search(q gameQuery, limit int) []dbGame {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	// Each filter narrows the candidates through its index
+	var candidates []int
+	filtered := false
+	narrow := func(ids []int) {
+		if !filtered {
+			candidates, filtered = ids, true
+			return
+		}
+		var kept []int
+		for _, id := range candidates {
+			if _, found := slices.BinarySearch(ids, id); found {
+				kept = append(kept, id)
+			}
+		}
+		candidates = kept
+	}
+	if q.Player != "" {
+		narrow(db.byPlayer[strings.ToLower(strings.TrimSpace(q.Player))])
+	}
+	if q.Result != "" {
+		narrow(db.byResult[q.Result])
+	}
+	if q.ECO != "" {
+		narrow(db.byECO[strings.ToUpper(q.ECO)])
+	}
+	if q.Position != nil {
+		narrow(db.byPosHash[positionHash(chess.PositionKey(q.Position))])
+	}
+
+	var results []dbGame
+	if !filtered {
+		for _, rec := range db.games {
+			if len(results) == limit {
+				break
+			}
+			results = append(results, rec)
+		}
+		return results
+	}
+	for _, id := range candidates {
+		if len(results) == limit {
+			break
+		}
+		results = append(results, db.games[id])
+	}
+	return results
+}
+
+// get returns the stored game with the given ID.
+func (db *gameDB) get(id int) (dbGame, bool) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	if id < 0 || id >= len(db.games) {
+		return dbGame{}, false
+	}
+	return db.games[id], true
+}
+
+// pgnImportResult reports the outcome of a bulk import.
+type pgnImportResult struct {
+	Imported int      `json:"imported"`
+	Failed   int      `json:"failed"`
+	Errors   []string `json:"errors,omitempty"`
+}
+
+// importPGNFile parses every game of a multi-game PGN file into db. Games
+// that fail to parse are skipped and reported rather than failing the whole
+// file.
+func importPGNFile(db *gameDB, text string) pgnImportResult {
+	var res pgnImportResult
+	for i, pgn := range chess.SplitPGN(text) {
+		g, tags, err := chess.ParsePGN(pgn)
+		if err != nil {
+			res.Failed++
+			if len(res.Errors) < maxImportErrors {
+				res.Errors = append(res.Errors, "game "+strconv.Itoa(i+1)+": "+err.Error())
+			}
+			continue
+		}
+		db.add(g, tags)
+		res.Imported++
+	}
+	return res
+}
+
+// handleBulkImportPGN ingests a multi-game PGN file, uploaded as the file
+// field or sent as the request body, into the game database. Only admins
+// may import.
+func (s *Server) handleBulkImportPGN(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeAPIError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if !s.requireAdmin(w, r) {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxBulkPGNSize)
+	var src io.Reader = r.Body
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, codeBadRequest, "no file uploaded")
+			return
+		}
+		defer file.Close()
+		src = file
+	}
+	data, err := io.ReadAll(src)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, codeBadRequest, "could not read upload: "+err.Error())
+		return
+	}
+	if strings.TrimSpace(string(data)) == "" {
+		writeAPIError(w, http.StatusBadRequest, codeBadRequest, "no PGN given")
+		return
+	}
+	writeJSON(w, importPGNFile(s.db, string(data)))
+}
+
+// handleSearchGames searches the game database by player, result and eco,
+// and by fen for games that reached that position at any point.
+func (s *Server) handleSearchGames(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	q := gameQuery{
+		Player: r.FormValue("player"),
+		Result: r.FormValue("result"),
+		ECO:    r.FormValue("eco"),
+	}
+	if fen := r.FormValue("fen"); fen != "" {
+		pos, err := chess.ParseFEN(fen)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, codeBadRequest, "invalid FEN: "+err.Error())
+			return
+		}
+		q.Position = pos
+	}
+	results := s.db.search(q, maxSearchResults)
+	if results == nil {
+		results = []dbGame{}
+	}
+	writeJSON(w, results)
+}
+
+// handleDatabaseGamePGN downloads a stored game as PGN, given its id.
+func (s *Server) handleDatabaseGamePGN(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	id, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, codeBadRequest, "id must be a number")
+		return
+	}
+	rec, ok := s.db.get(id)
+	if !ok {
+		writeAPIError(w, http.StatusNotFound, codeGameNotFound, "unknown game "+strconv.Itoa(id))
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-chess-pgn")
+	w.Write([]byte(rec.PGN))
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestHandleBulkImportPGN(t *testing.T) {
+	s, ts := newTestServer(t)
+	c := newTestClient(t, s, ts)
+	const pgn = `[White "Anderssen"]
+[Black "Kieseritzky"]
+[Result "1-0"]
+
+1. e4 e5 2. f4 exf4 3. Bc4 Qh4+ 4. Kf1 b5 1-0
+`
+	admin := http.Header{"Authorization": {"Bearer " + testAdminToken}, "Content-Type": {"application/x-chess-pgn"}}
+	visitor := http.Header{"Content-Type": {"application/x-chess-pgn"}}
+
+	if status, _ := c.send(http.MethodGet, "/admin/pgn/import", nil, admin); status != http.StatusMethodNotAllowed {
+		t.Errorf("GET: status %d, want %d", status, http.StatusMethodNotAllowed)
+	}
+	if status, _ := c.send(http.MethodPost, "/admin/pgn/import", strings.NewReader(pgn), visitor); status != http.StatusForbidden {
+		t.Errorf("without the admin token: status %d, want %d", status, http.StatusForbidden)
+	}
+	if _, body := c.get("/api/games/search?player=Anderssen"); strings.TrimSpace(body) != "[]" {
+		t.Fatalf("a refused import reached the database: %s", body)
+	}
+
+	status, body := c.send(http.MethodPost, "/admin/pgn/import", strings.NewReader(pgn), admin)
+	if status != http.StatusOK {
+		t.Fatalf("as admin: status %d: %s", status, body)
+	}
+	var res pgnImportResult
+	if err := json.Unmarshal([]byte(body), &res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Imported != 1 || res.Failed != 0 {
+		t.Errorf("imported %d and failed %d, want 1 and 0", res.Imported, res.Failed)
+	}
+	if _, body := c.get("/api/games/search?player=Anderssen"); !strings.Contains(body, "Kieseritzky") {
+		t.Errorf("the imported game is not found: %s", body)
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/a-h/templ"
+	"github.com/rigurd/chess"
+)
+
+// GameManager holds the games being played, keyed by game ID. Each game runs
+// on its own goroutine, so players in one game never wait on another. Games are
+// saved to the store as they are played, and loaded back from it when
+// first asked for after a restart.
+type GameManager struct {
+	mu    sync.RWMutex
+	games map[string]*Game
+	store GameStore
+	wal   *moveLog // where moves are logged before they are played, or nil
+
+	webhooks *webhookSender // posts the games' events to their webhooks, or nil
+	engine   *engine        // plays the computer's hardest level and analyses, or nil
+	closing  atomic.Bool    // set once the games are being saved for good, when the computer stops moving
+}
+
+// NewGameManager returns a manager with no games loaded, keeping them in
+// store. When the store is shared with other server instances, the games
+// they save are dropped from memory, to be loaded again when next asked for.
+func NewGameManager(store GameStore) *GameManager {
+	m := &GameManager{games: make(map[string]*Game), store: store}
+	if shared, ok := store.(sharedStore); ok {
+		go shared.Subscribe(m.forget)
+	}
+	return m
+}
+
+// Create starts a new game at the standard starting position under a fresh
+// ID. Callers may set the game up further before sharing its ID, saving it
+// once they have.
+func (m *GameManager) Create() *Game {
+	g := newGame()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	g.wal = m.wal
+	for {
+		if m.games[g.ID] == nil {
+			err := m.store.Create(g)
+			if err == nil {
+				break
+			}
+			if !errors.Is(err, errGameExists) {
+				// The game is saved with its first move instead
+				log.Printf("creating game %s: %v", g.ID, err)
+				break
+			}
+		}
+		g.ID = newGameID()
+	}
+	m.games[g.ID] = g
+	return g
+}
+
+// Get returns the game with the given ID, loading it from the store if it
+// has not been asked for since the server started.
+func (m *GameManager) Get(id string) (*Game, bool) {
+	m.mu.RLock()
+	g, ok := m.games[id]
+	m.mu.RUnlock()
+	if ok {
+		return g, true
+	}
+
+	g, err := m.store.Load(id)
+	if err != nil {
+		if !errors.Is(err, errGameNotFound) {
+			log.Printf("loading game %s: %v", id, err)
+		}
+		return nil, false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	// Another request may have loaded the game meanwhile
+	if loaded, ok := m.games[id]; ok {
+		return loaded, true
+	}
+	g.wal = m.wal
+	m.games[id] = g
+	return g, true
+}
+
+// Save writes what changed in g to the store, archiving the game once it
+// has ended, tells its webhooks of its events since, and has the computer
+// reply if it plays the side to move. A failure is
+// logged rather than failing the request, as the game can still be played
+// from memory.
+func (m *GameManager) Save(g *Game) {
+	g.do(func() {
+		if events := g.webhookEvents(); len(events) > 0 && m.webhooks != nil {
+			m.webhooks.send(g.ID, g.Settings.Webhooks, events)
+		}
+		m.startComputer(g)
+		if g.archived && g.Result != chess.Ongoing {
+			// The archived copy stands until the game is reset or taken back
+			return
+		}
+		g.archived = false
+		if err := m.store.SaveMove(g); err != nil {
+			log.Printf("saving game %s: %v", g.ID, err)
+			if errors.Is(err, errStaleGame) {
+				m.forget(g.ID)
+			}
+			return
+		}
+		if _, volatile := m.store.(*memoryStore); g.logged && !volatile {
+			// The moves logged so far are in the store now
+			if err := m.wal.append(walEntry{Game: g.ID, Ply: len(g.History), Saved: true}); err != nil {
+				log.Printf("logging save of game %s: %v", g.ID, err)
+			}
+			g.logged = false
+		}
+		if g.Result != chess.Ongoing {
+			if err := m.store.Archive(g); err != nil {
+				log.Printf("archiving game %s: %v", g.ID, err)
+				return
+			}
+			g.archived = true
+		}
+	})
+}
+
+// Close saves every game held in memory and closes the store and move log,
+// as the server shuts down.
+func (m *GameManager) Close() error {
+	m.closing.Store(true)
+	m.mu.RLock()
+	games := make([]*Game, 0, len(m.games))
+	for _, g := range m.games {
+		games = append(games, g)
+	}
+	m.mu.RUnlock()
+
+	for _, g := range games {
+		m.Save(g)
+	}
+	log.Printf("saved %d games", len(games))
+	if m.engine != nil {
+		m.engine.close()
+	}
+	if m.wal != nil {
+		m.wal.Close()
+	}
+	return m.store.Close()
+}
+
+// forget drops the game with the given ID from memory, so it is loaded
+// from the store when next asked for.
+func (m *GameManager) forget(id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.games, id)
+}
+
+// gamePath returns the URL of a page or action of g, e.g. "/game/{id}/move".
+func gamePath(g *Game, suffix string) string {
+	return "/game/" + g.ID + suffix
+}
+
+// handleIndex shows the landing page, and on POST starts a new game for the
+// visitor and sends them to it. Games are only created on POST, so crawlers
+// and link previews fetching the page do not fill the store.
+func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		templ.Handler(indexPage()).ServeHTTP(w, r)
+		return
+	case http.MethodPost:
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.requireRate(w, r, rateCreates) {
+		return
+	}
+	g := s.games.Create()
+	s.audit(r, g, "create", "")
+	http.Redirect(w, r, gamePath(g, ""), http.StatusSeeOther)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/a-h/templ"
+)
+
+// maxListedGames bounds the archived games listed at once.
+const maxListedGames = 100
+
+// archiveFilterFrom reads the player, date, result and opening parameters
+// of a listing of archived games.
+func archiveFilterFrom(r *http.Request) archiveFilter {
+	return newArchiveFilter(r.FormValue("player"), r.FormValue("date"), r.FormValue("result"), r.FormValue("opening"))
+}
+
+// archivePageFrom reads the limit, sort ("-date", the default, for the
+// most recently finished first, or "date") and cursor parameters of a
+// listing of archived games.
+func archivePageFrom(r *http.Request) (archivePage, error) {
+	p := archivePage{Limit: maxListedGames}
+	if v := r.FormValue("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return p, errors.New("limit must be a positive number")
+		}
+		p.Limit = min(n, maxListedGames)
+	}
+	switch r.FormValue("sort") {
+	case "", "-date":
+	case "date":
+		p.Oldest = true
+	default:
+		return p, errors.New(`sort must be "date" or "-date"`)
+	}
+	var err error
+	p.After, err = parseArchiveCursor(r.FormValue("cursor"))
+	return p, err
+}
+
+// listArchived returns the page of archived games the request asks for,
+// and the cursor of the next page, or "" on the last page. A bad request
+// is answered here with fail, returning ok false.
+func (s *Server) listArchived(w http.ResponseWriter, r *http.Request, fail errorWriter) (games []GameSummary, next string, ok bool) {
+	p, err := archivePageFrom(r)
+	if err != nil {
+		fail(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		return nil, "", false
+	}
+	games, next, err = s.archivedPage(archiveFilterFrom(r), p)
+	if err != nil {
+		fail(w, http.StatusInternalServerError, codeInternal, "could not list games: "+err.Error())
+		return nil, "", false
+	}
+	return games, next, true
+}
+
+// archivedPage returns page p of the archived games matching f, and the
+// cursor of the next page, or "" on the last page.
+func (s *Server) archivedPage(f archiveFilter, p archivePage) (games []GameSummary, next string, err error) {
+	// One game more than asked for tells whether there is a next page
+	p.Limit++
+	games, err = s.games.store.Archived(f, p)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(games) == p.Limit {
+		games = games[:len(games)-1]
+		next = cursorOf(games[len(games)-1]).String()
+	}
+	return games, next, nil
+}
+
+// handleGames shows the archived games matching the request's filters,
+// most recently finished first, each linking to its replay.
+func (s *Server) handleGames(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	games, next, ok := s.listArchived(w, r, writeTextError)
+	if !ok {
+		return
+	}
+	templ.Handler(gamesPage(games, r.URL.Query(), next)).ServeHTTP(w, r)
+}
+
+// nextPageURL returns the /games page after the one with query q, which
+// ended at cursor.
+func nextPageURL(q url.Values, cursor string) templ.SafeURL {
+	next := url.Values{}
+	for k, v := range q {
+		next[k] = v
+	}
+	next.Set("cursor", cursor)
+	return templ.SafeURL("/games?" + next.Encode())
+}
+
+// gameList is a page of the games listing API.
+type gameList struct {
+	Games []GameSummary `json:"games"`
+	Next  string        `json:"next,omitempty"` // cursor of the next page, if any
+}
+
+// handleListGames returns a page of the archived games matching the
+// request's filters as JSON, with the cursor to pass to get the next page.
+func (s *Server) handleListGames(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	games, next, ok := s.listArchived(w, r, writeAPIError)
+	if !ok {
+		return
+	}
+	if games == nil {
+		games = []GameSummary{}
+	}
+	writeJSON(w, gameList{Games: games, Next: next})
+}
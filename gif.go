@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"net/http"
+	"strconv"
+
+	"github.com/rigurd/chess"
+)
+
+const (
+	defaultFrameDelay = 1000 // milliseconds
+	minFrameDelay     = 100
+	maxFrameDelay     = 10000
+	finalFrameHold    = 3 // the last position stays this many delays
+)
+
+// gifPalette holds every color the board renderer uses, so frames convert
+// without dithering.
+var gifPalette = color.Palette{lightSquareColor, darkSquareColor, whitePieceColor, blackPieceColor}
+
+// renderGameGIF draws every position of g's mainline, from the start to
+// the current move, as frames of an animated GIF shown delay milliseconds
+// apart.
+func renderGameGIF(g *chess.GameState, size int, flip bool, delay int) (*gif.GIF, error) {
+	pos, err := g.PositionAt(0)
+	if err != nil {
+		return nil, err
+	}
+	anim := &gif.GIF{}
+	addFrame := func() {
+		frame := image.NewPaletted(image.Rect(0, 0, size, size), gifPalette)
+		draw.Draw(frame, frame.Bounds(), renderBoardImage(pos, size, flip), image.Point{}, draw.Src)
+		anim.Image = append(anim.Image, frame)
+		anim.Delay = append(anim.Delay, delay/10)
+	}
+
+	addFrame()
+	for _, rec := range g.History {
+		if rec.Move.Drop != chess.Empty {
+			chess.Drop(pos, rec.Move.Drop, rec.Move.To)
+		} else {
+			chess.Play(pos, rec.Move)
+		}
+		addFrame()
+	}
+	anim.Delay[len(anim.Delay)-1] *= finalFrameHold
+	return anim, nil
+}
+
+// serveGameGIF writes the game so far as an animated GIF download. The
+// delay parameter sets the milliseconds between moves, and size and flip
+// work as for the static images.
+func serveGameGIF(w http.ResponseWriter, r *http.Request, g *Game) {
+	size, err := imageSizeParam(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	flip := r.FormValue("flip") == "1"
+	delay := defaultFrameDelay
+	if s := r.FormValue("delay"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			http.Error(w, "delay must be a number", http.StatusBadRequest)
+			return
+		}
+		delay = min(max(n, minFrameDelay), maxFrameDelay)
+	}
+
+	// Render from a copy so other requests are not held up meanwhile
+	var state *chess.GameState
+	g.do(func() { state = g.Clone() })
+
+	anim, err := renderGameGIF(state, size, flip, delay)
+	if err != nil {
+		http.Error(w, "failed to replay game: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, anim); err != nil {
+		http.Error(w, "failed to encode image", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/gif")
+	w.Header().Set("Content-Disposition", `attachment; filename="game.gif"`)
+	w.Write(buf.Bytes())
+}
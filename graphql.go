@@ -0,0 +1,533 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"sync"
+
+	"github.com/graph-gophers/graphql-go"
+	"github.com/rigurd/chess"
+	"golang.org/x/net/websocket"
+)
+
+// graphqlSchema describes the games the /graphql endpoint serves. Players
+// are identified by their session, as on the web.
+const graphqlSchema = `
+schema {
+	query: Query
+	mutation: Mutation
+	subscription: Subscription
+}
+
+scalar Time
+
+type Query {
+	# The game with the given ID, or null if there is none.
+	game(id: ID!): Game
+	# A page of the finished games matching the filters, most recently
+	# finished first unless oldestFirst is set.
+	games(player: String, date: String, result: String, opening: String, first: Int, after: String, oldestFirst: Boolean): GameList!
+}
+
+type Mutation {
+	# Starts a new game, from the position given as FEN, or from the start
+	# of the variant given.
+	createGame(fen: String, variant: String): Game!
+	# Plays a move, in SAN or UCI, for the player to move.
+	makeMove(gameId: ID!, move: String!): Game!
+}
+
+type Subscription {
+	# The game, then the game again after every change to it, until it is
+	# over.
+	gameUpdated(id: ID!): Game!
+}
+
+type Game {
+	id: ID!
+	fen: String!
+	variant: String!
+	turn: String!
+	inCheck: Boolean!
+	result: String!
+	endReason: String
+	drawOffer: String
+	ply: Int!
+	moves: [Move!]!
+	lastMove: Move
+	players: Players!
+}
+
+type Move {
+	ply: Int!
+	color: String!
+	san: String!
+	uci: String!
+	time: Time!
+}
+
+type Players {
+	white: Player!
+	black: Player!
+}
+
+type Player {
+	# The name given in the game's PGN tags, if any.
+	name: String
+	# Whether someone has taken the side.
+	seated: Boolean!
+	# Whether the side is played by the one asking.
+	you: Boolean!
+}
+
+type GameList {
+	games: [GameSummary!]!
+	# Cursor to pass as after for the next page, or null on the last page.
+	next: String
+}
+
+type GameSummary {
+	id: ID!
+	white: String!
+	black: String!
+	date: String!
+	result: String!
+	endReason: String
+	eco: String
+	opening: String
+	plies: Int!
+	updated: Time!
+}
+`
+
+// graphqlRequestKey is the context key of the HTTP request a GraphQL
+// operation came with.
+type graphqlRequestKey struct{}
+
+// requestOf returns the HTTP request a GraphQL operation came with.
+func requestOf(ctx context.Context) *http.Request {
+	return ctx.Value(graphqlRequestKey{}).(*http.Request)
+}
+
+// graphqlError is an error of a GraphQL operation with a machine-readable
+// code, given in its extensions.
+type graphqlError struct {
+	msg  string
+	code string
+}
+
+func (e graphqlError) Error() string { return e.msg }
+
+func (e graphqlError) Extensions() map[string]any { return map[string]any{"code": e.code} }
+
+// The GraphQL types, resolved from their fields
+type (
+	gqlGame struct {
+		ID        graphql.ID
+		Fen       string
+		Variant   string
+		Turn      string
+		InCheck   bool
+		Result    string
+		EndReason *string
+		DrawOffer *string
+		Ply       int32
+		Moves     []*gqlMove
+		LastMove  *gqlMove
+		Players   *gqlPlayers
+	}
+	gqlMove struct {
+		Ply   int32
+		Color string
+		San   string
+		Uci   string
+		Time  graphql.Time
+	}
+	gqlPlayers struct {
+		White *gqlPlayer
+		Black *gqlPlayer
+	}
+	gqlPlayer struct {
+		Name   *string
+		Seated bool
+		You    bool
+	}
+	gqlGameList struct {
+		Games []*gqlGameSummary
+		Next  *string
+	}
+	gqlGameSummary struct {
+		ID        graphql.ID
+		White     string
+		Black     string
+		Date      string
+		Result    string
+		EndReason *string
+		Eco       *string
+		Opening   *string
+		Plies     int32
+		Updated   graphql.Time
+	}
+)
+
+// optional returns a pointer to s, or nil for "", the GraphQL null.
+func optional(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// newGQLGame returns the state of g as seen by session. It must be called
+// from a command of g's goroutine.
+func newGQLGame(g *Game, session string) *gqlGame {
+	ag := newAPIGame(g, session)
+	gg := &gqlGame{
+		ID:        graphql.ID(ag.ID),
+		Fen:       ag.FEN,
+		Variant:   string(ag.Variant),
+		Turn:      string(ag.Turn),
+		InCheck:   ag.InCheck,
+		Result:    string(ag.Result),
+		EndReason: optional(ag.EndReason),
+		DrawOffer: optional(string(ag.DrawOffer)),
+		Ply:       int32(ag.Ply),
+		Moves:     make([]*gqlMove, len(g.History)),
+		Players: &gqlPlayers{
+			White: &gqlPlayer{Name: optional(g.Tags.White), Seated: g.Players[chess.White] != "", You: ag.You == chess.White},
+			Black: &gqlPlayer{Name: optional(g.Tags.Black), Seated: g.Players[chess.Black] != "", You: ag.You == chess.Black},
+		},
+	}
+	for ply, rec := range g.History {
+		m := newAPIMove(ply, rec)
+		gg.Moves[ply] = &gqlMove{Ply: int32(m.Ply), Color: string(m.Color), San: m.SAN, Uci: m.UCI, Time: graphql.Time{Time: m.Time}}
+	}
+	if n := len(gg.Moves); n > 0 {
+		gg.LastMove = gg.Moves[n-1]
+	}
+	return gg
+}
+
+// graphqlResolver resolves the root fields of the GraphQL schema.
+type graphqlResolver struct {
+	s *Server
+}
+
+func (q *graphqlResolver) Game(ctx context.Context, args struct{ ID graphql.ID }) *gqlGame {
+	g, ok := q.s.games.Get(string(args.ID))
+	if !ok {
+		return nil
+	}
+	var gg *gqlGame
+	g.do(func() { gg = newGQLGame(g, sessionOf(requestOf(ctx))) })
+	return gg
+}
+
+func (q *graphqlResolver) Games(args struct {
+	Player, Date, Result, Opening *string
+	First                         *int32
+	After                         *string
+	OldestFirst                   *bool
+}) (*gqlGameList, error) {
+	str := func(s *string) string {
+		if s == nil {
+			return ""
+		}
+		return *s
+	}
+	p := archivePage{Limit: maxListedGames, Oldest: args.OldestFirst != nil && *args.OldestFirst}
+	if args.First != nil {
+		if *args.First < 1 {
+			return nil, errors.New("first must be a positive number")
+		}
+		p.Limit = min(int(*args.First), maxListedGames)
+	}
+	var err error
+	if p.After, err = parseArchiveCursor(str(args.After)); err != nil {
+		return nil, err
+	}
+	f := newArchiveFilter(str(args.Player), str(args.Date), str(args.Result), str(args.Opening))
+	games, next, err := q.s.archivedPage(f, p)
+	if err != nil {
+		return nil, errors.New("could not list games: " + err.Error())
+	}
+	list := &gqlGameList{Games: make([]*gqlGameSummary, len(games)), Next: optional(next)}
+	for i, sum := range games {
+		list.Games[i] = &gqlGameSummary{
+			ID:        graphql.ID(sum.ID),
+			White:     sum.Tags.White,
+			Black:     sum.Tags.Black,
+			Date:      sum.Tags.Date,
+			Result:    sum.Result,
+			EndReason: optional(sum.EndReason),
+			Eco:       optional(sum.ECO.Code),
+			Opening:   optional(sum.ECO.Name),
+			Plies:     int32(sum.Plies),
+			Updated:   graphql.Time{Time: sum.Updated},
+		}
+	}
+	return list, nil
+}
+
+func (q *graphqlResolver) CreateGame(ctx context.Context, args struct{ Fen, Variant *string }) (*gqlGame, error) {
+	if q.s.draining.Load() {
+		return nil, graphqlError{"Server is shutting down", "UNAVAILABLE"}
+	}
+	var fen, variant string
+	if args.Fen != nil {
+		fen = *args.Fen
+	}
+	if args.Variant != nil {
+		variant = *args.Variant
+	}
+	start, err := parseGameStart(fen, variant)
+	if err != nil {
+		return nil, graphqlError{err.Error(), "BAD_REQUEST"}
+	}
+
+	r := requestOf(ctx)
+	if !canPlay(r) {
+		return nil, graphqlError{"This API key is read-only", "FORBIDDEN"}
+	}
+	if wait, ok := q.s.checkRate(r, rateCreates); !ok {
+		return nil, graphqlError{rateLimitedMessage(rateCreates, wait), "RATE_LIMITED"}
+	}
+	g := q.s.games.Create()
+	var gg *gqlGame
+	g.update(func() {
+		start.apply(g)
+		q.s.audit(r, g, "create", g.FEN())
+		gg = newGQLGame(g, sessionOf(r))
+	})
+	q.s.games.Save(g)
+	return gg, nil
+}
+
+func (q *graphqlResolver) MakeMove(ctx context.Context, args struct {
+	GameID graphql.ID
+	Move   string
+}) (*gqlGame, error) {
+	if q.s.draining.Load() {
+		return nil, graphqlError{"Server is shutting down", "UNAVAILABLE"}
+	}
+	if !canPlay(requestOf(ctx)) {
+		return nil, graphqlError{"This API key is read-only", "FORBIDDEN"}
+	}
+	if wait, ok := q.s.checkRate(requestOf(ctx), rateMoves); !ok {
+		return nil, graphqlError{rateLimitedMessage(rateMoves, wait), "RATE_LIMITED"}
+	}
+	g, ok := q.s.games.Get(string(args.GameID))
+	if !ok {
+		return nil, graphqlError{"unknown game " + string(args.GameID), "NOT_FOUND"}
+	}
+	defer q.s.games.Save(g)
+
+	r := requestOf(ctx)
+	var gg *gqlGame
+	var err error
+	g.update(func() {
+		if !g.claimSide(sessionOf(r), g.CurrentPlayer) {
+			err = graphqlError{"You are not playing " + string(g.CurrentPlayer) + " in this game", "FORBIDDEN"}
+			return
+		}
+		plies := len(g.History)
+		err = playText(g, args.Move)
+		q.s.auditMoves(r, g, plies)
+		gg = newGQLGame(g, sessionOf(r))
+	})
+	var moveErr chess.MoveError
+	switch {
+	case err == nil:
+		return gg, nil
+	case errors.Is(err, errMoveNotLogged):
+		return nil, graphqlError{err.Error(), "UNAVAILABLE"}
+	case errors.Is(err, errTouchMove):
+		return nil, graphqlError{err.Error(), "TOUCH_MOVE"}
+	case errors.As(err, &moveErr):
+		return nil, graphqlError{err.Error(), string(moveErr)}
+	}
+	return nil, err
+}
+
+func (q *graphqlResolver) GameUpdated(ctx context.Context, args struct{ ID graphql.ID }) (<-chan *gqlGame, error) {
+	g, ok := q.s.games.Get(string(args.ID))
+	if !ok {
+		return nil, graphqlError{"unknown game " + string(args.ID), "NOT_FOUND"}
+	}
+	session := sessionOf(requestOf(ctx))
+	updates, unsubscribe := g.subscribe()
+	c := make(chan *gqlGame)
+	go func() {
+		defer close(c)
+		defer unsubscribe()
+		for {
+			var gg *gqlGame
+			g.do(func() { gg = newGQLGame(g, session) })
+			select {
+			case c <- gg:
+			case <-ctx.Done():
+				return
+			}
+			if gg.Result != string(chess.Ongoing) {
+				return
+			}
+			select {
+			case <-updates:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return c, nil
+}
+
+// newGraphQLSchema returns the GraphQL schema of the games of s.
+func newGraphQLSchema(s *Server) *graphql.Schema {
+	return graphql.MustParseSchema(graphqlSchema, &graphqlResolver{s: s},
+		graphql.UseFieldResolvers(), graphql.MaxDepth(10))
+}
+
+// graphqlRequest is a GraphQL operation sent to /graphql.
+type graphqlRequest struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+// handleGraphQL runs the GraphQL operation POSTed as JSON. Subscriptions
+// are served over a WebSocket opened on the same path.
+func (s *Server) handleGraphQL(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Upgrade") == "websocket" {
+		ws := websocket.Server{
+			Handshake: s.checkGraphQLHandshake,
+			Handler:   func(conn *websocket.Conn) { s.serveGraphQLWS(conn, r) },
+		}
+		keepOpen(w)
+		ws.ServeHTTP(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeAPIError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method not allowed")
+		return
+	}
+	// Only JSON is taken, which a form on another site cannot send
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+		writeAPIError(w, http.StatusUnsupportedMediaType, codeUnsupportedMediaType, "send the operation as application/json")
+		return
+	}
+
+	var req graphqlRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAPIBody)).Decode(&req); err != nil {
+		writeAPIError(w, http.StatusBadRequest, codeBadRequest, "invalid JSON body: "+err.Error())
+		return
+	}
+	ctx := context.WithValue(r.Context(), graphqlRequestKey{}, r)
+	writeJSON(w, s.graphql.Exec(ctx, req.Query, req.OperationName, req.Variables))
+}
+
+// graphqlWSProtocol is the WebSocket subprotocol of GraphQL subscriptions,
+// that of the graphql-ws library.
+const graphqlWSProtocol = "graphql-transport-ws"
+
+// checkGraphQLHandshake accepts a WebSocket from the same origin, or one
+// allowed by CORS, speaking graphqlWSProtocol.
+func (s *Server) checkGraphQLHandshake(config *websocket.Config, r *http.Request) error {
+	origin, err := websocket.Origin(config, r)
+	if err != nil {
+		return err
+	}
+	if origin != nil && s.corsOrigin(origin.String()) != "" {
+		config.Origin = origin
+	} else if err := checkSameOrigin(config, r); err != nil {
+		return err
+	}
+	for _, p := range config.Protocol {
+		if p == graphqlWSProtocol {
+			config.Protocol = []string{graphqlWSProtocol}
+			return nil
+		}
+	}
+	return errors.New("the " + graphqlWSProtocol + " subprotocol is required")
+}
+
+// graphqlWSMessage is a message of graphqlWSProtocol.
+type graphqlWSMessage struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// serveGraphQLWS runs the operations the client subscribes to over conn,
+// each until it completes or the client ends it, until the client goes
+// away.
+func (s *Server) serveGraphQLWS(conn *websocket.Conn, r *http.Request) {
+	defer conn.Close()
+	ctx, cancel := context.WithCancel(context.WithValue(r.Context(), graphqlRequestKey{}, r))
+	defer cancel()
+
+	var mu sync.Mutex
+	running := make(map[string]context.CancelFunc)
+	send := func(id, typ string, payload any) {
+		msg := graphqlWSMessage{ID: id, Type: typ}
+		if payload != nil {
+			msg.Payload, _ = json.Marshal(payload)
+		}
+		websocket.JSON.Send(conn, msg)
+	}
+
+	acked := false
+	for {
+		var msg graphqlWSMessage
+		if err := websocket.JSON.Receive(conn, &msg); err != nil {
+			return
+		}
+		switch msg.Type {
+		case "connection_init":
+			acked = true
+			send("", "connection_ack", nil)
+		case "ping":
+			send("", "pong", nil)
+		case "subscribe":
+			var req graphqlRequest
+			if !acked || json.Unmarshal(msg.Payload, &req) != nil {
+				return
+			}
+			mu.Lock()
+			if _, dup := running[msg.ID]; dup {
+				mu.Unlock()
+				return
+			}
+			opCtx, opCancel := context.WithCancel(ctx)
+			running[msg.ID] = opCancel
+			mu.Unlock()
+			go func(id string) {
+				defer func() {
+					mu.Lock()
+					delete(running, id)
+					mu.Unlock()
+					opCancel()
+				}()
+				responses, err := s.graphql.Subscribe(opCtx, req.Query, req.OperationName, req.Variables)
+				if err != nil {
+					send(id, "error", []map[string]string{{"message": err.Error()}})
+					return
+				}
+				for resp := range responses {
+					send(id, "next", resp)
+				}
+				if opCtx.Err() == nil {
+					send(id, "complete", nil)
+				}
+			}(msg.ID)
+		case "complete":
+			mu.Lock()
+			if stop, ok := running[msg.ID]; ok {
+				stop()
+			}
+			mu.Unlock()
+		}
+	}
+}
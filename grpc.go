@@ -0,0 +1,284 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/rigurd/chess"
+	"github.com/rigurd/rigurdpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// rpcsChanging are the methods of the chess service that change games, so
+// need an API key scoped to play.
+var rpcsChanging = map[string]bool{
+	rigurdpb.Chess_CreateGame_FullMethodName: true,
+	rigurdpb.Chess_MakeMove_FullMethodName:   true,
+}
+
+// chessService serves the games of a server over gRPC, for other backend
+// services. Its callers authenticate with the API keys of the HTTP API,
+// and play as them.
+type chessService struct {
+	rigurdpb.UnimplementedChessServer
+	s    *Server
+	stop chan struct{} // closed when the server shuts down, ending the streams
+}
+
+// newGRPCServer returns a gRPC server with the chess service of s, and a
+// function ending its streams, which GracefulStop would wait for.
+func newGRPCServer(s *Server) (*grpc.Server, func()) {
+	svc := &chessService{s: s, stop: make(chan struct{})}
+	srv := grpc.NewServer(
+		grpc.UnaryInterceptor(svc.authenticateUnary),
+		grpc.StreamInterceptor(svc.authenticateStream),
+	)
+	rigurdpb.RegisterChessServer(srv, svc)
+	return srv, func() { close(svc.stop) }
+}
+
+// authenticate checks the API key a call to method carries in its
+// "authorization" metadata, as "Bearer <key>" like on the HTTP API,
+// returning ctx with the key attached. An unknown key, or a read-only key
+// used to change a game, is refused.
+func (c *chessService) authenticate(ctx context.Context, method string) (context.Context, error) {
+	var token string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get("authorization"); len(v) > 0 {
+			token, _ = strings.CutPrefix(v[0], "Bearer ")
+		}
+	}
+	if !strings.HasPrefix(token, apiKeyPrefix) {
+		return nil, status.Error(codes.Unauthenticated, "an API key is required")
+	}
+	key, err := c.s.games.store.APIKey(hashAPIKey(token))
+	if err != nil {
+		if !errors.Is(err, errAPIKeyNotFound) {
+			return nil, status.Error(codes.Unavailable, "could not check the API key")
+		}
+		return nil, status.Error(codes.Unauthenticated, "invalid API key")
+	}
+	if key.Scope != scopePlay && rpcsChanging[method] {
+		return nil, status.Error(codes.PermissionDenied, "this API key is read-only")
+	}
+	return context.WithValue(ctx, apiKeyKey{}, key), nil
+}
+
+// authenticateUnary authenticates each unary call before handling it.
+func (c *chessService) authenticateUnary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx, err := c.authenticate(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// authenticateStream authenticates each streaming call before handling it.
+func (c *chessService) authenticateStream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := c.authenticate(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, authenticatedStream{ss, ctx})
+}
+
+// authenticatedStream is a server stream whose context has the caller's
+// API key attached.
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s authenticatedStream) Context() context.Context { return s.ctx }
+
+// rpcPlayer returns the session of the API key ctx was authenticated with,
+// which the caller plays as.
+func rpcPlayer(ctx context.Context) string {
+	key, _ := ctx.Value(apiKeyKey{}).(APIKey)
+	return apiKeyPlayerPrefix + key.ID
+}
+
+// toProtoGame returns the state of g as the gRPC service returns it.
+func toProtoGame(g apiGame) *rigurdpb.Game {
+	pg := &rigurdpb.Game{
+		Id:        g.ID,
+		Fen:       g.FEN,
+		Variant:   string(g.Variant),
+		Turn:      string(g.Turn),
+		InCheck:   g.InCheck,
+		Result:    string(g.Result),
+		EndReason: g.EndReason,
+		DrawOffer: string(g.DrawOffer),
+		Ply:       int32(g.Ply),
+	}
+	if m := g.LastMove; m != nil {
+		pg.LastMove = &rigurdpb.Move{
+			Ply:   int32(m.Ply),
+			Color: string(m.Color),
+			San:   m.SAN,
+			Uci:   m.UCI,
+			Time:  timestamppb.New(m.Time),
+		}
+	}
+	return pg
+}
+
+// auditRPC records that player did action, with detail, to g over gRPC.
+func (c *chessService) auditRPC(ctx context.Context, g *Game, player, action, detail string) {
+	var ip string
+	if p, ok := peer.FromContext(ctx); ok {
+		ip = p.Addr.String()
+		if host, _, err := net.SplitHostPort(ip); err == nil {
+			ip = host
+		}
+	}
+	c.s.games.audit(AuditEvent{
+		Time:    time.Now(),
+		Game:    g.ID,
+		Action:  action,
+		Detail:  detail,
+		Session: player,
+		IP:      ip,
+	})
+}
+
+// game returns the game with the given ID, or a NotFound error.
+func (c *chessService) game(id string) (*Game, error) {
+	g, ok := c.s.games.Get(id)
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "unknown game %s", id)
+	}
+	return g, nil
+}
+
+func (c *chessService) CreateGame(ctx context.Context, req *rigurdpb.CreateGameRequest) (*rigurdpb.Game, error) {
+	start, err := parseGameStart(req.Fen, req.Variant)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	g := c.s.games.Create()
+	var ag apiGame
+	g.update(func() {
+		start.apply(g)
+		c.auditRPC(ctx, g, rpcPlayer(ctx), "create", g.FEN())
+		ag = newAPIGame(g, "")
+	})
+	c.s.games.Save(g)
+	return toProtoGame(ag), nil
+}
+
+func (c *chessService) GetGame(ctx context.Context, req *rigurdpb.GetGameRequest) (*rigurdpb.Game, error) {
+	g, err := c.game(req.Id)
+	if err != nil {
+		return nil, err
+	}
+	var ag apiGame
+	g.do(func() { ag = newAPIGame(g, "") })
+	return toProtoGame(ag), nil
+}
+
+func (c *chessService) MakeMove(ctx context.Context, req *rigurdpb.MakeMoveRequest) (*rigurdpb.Game, error) {
+	if req.Move == "" {
+		return nil, status.Error(codes.InvalidArgument, "move is required")
+	}
+	if c.s.draining.Load() {
+		return nil, status.Error(codes.Unavailable, "server is shutting down")
+	}
+	g, err := c.game(req.GameId)
+	if err != nil {
+		return nil, err
+	}
+	defer c.s.games.Save(g)
+
+	player := rpcPlayer(ctx)
+	var ag apiGame
+	g.update(func() {
+		if !g.claimSide(player, g.CurrentPlayer) {
+			err = status.Errorf(codes.PermissionDenied, "this API key is not playing %s in this game", g.CurrentPlayer)
+			return
+		}
+		plies := len(g.History)
+		err = playText(g, req.Move)
+		for _, rec := range g.History[min(plies, len(g.History)):] {
+			c.auditRPC(ctx, g, player, "move", rec.SAN)
+		}
+		ag = newAPIGame(g, player)
+	})
+	var moveErr chess.MoveError
+	switch {
+	case err == nil:
+		return toProtoGame(ag), nil
+	case status.Code(err) != codes.Unknown:
+		return nil, err
+	case errors.Is(err, errMoveNotLogged):
+		return nil, status.Error(codes.Unavailable, err.Error())
+	case errors.Is(err, chess.ErrGameOver), errors.Is(err, errTouchMove):
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	case errors.As(err, &moveErr):
+		return nil, status.Errorf(codes.InvalidArgument, "%s (%s)", err, string(moveErr))
+	default:
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+}
+
+func (c *chessService) LegalMoves(ctx context.Context, req *rigurdpb.LegalMovesRequest) (*rigurdpb.LegalMovesResponse, error) {
+	var from *chess.Square
+	if req.Square != "" {
+		sq, err := chess.ParseSquareName(req.Square)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid square %s", req.Square)
+		}
+		from = &sq
+	}
+	g, err := c.game(req.GameId)
+	if err != nil {
+		return nil, err
+	}
+	resp := &rigurdpb.LegalMovesResponse{}
+	g.do(func() {
+		if g.Result != chess.Ongoing {
+			return
+		}
+		for _, m := range legalMoves(&g.GameState) {
+			if from != nil && (m.Drop != chess.Empty || m.From != *from) {
+				continue
+			}
+			resp.Moves = append(resp.Moves, &rigurdpb.Move{San: chess.SANWithSuffix(&g.GameState, m), Uci: chess.UCI(m)})
+		}
+	})
+	return resp, nil
+}
+
+func (c *chessService) StreamGame(req *rigurdpb.StreamGameRequest, stream grpc.ServerStreamingServer[rigurdpb.Game]) error {
+	g, err := c.game(req.Id)
+	if err != nil {
+		return err
+	}
+	updates, unsubscribe := g.subscribe()
+	defer unsubscribe()
+	for {
+		var ag apiGame
+		g.do(func() { ag = newAPIGame(g, "") })
+		if err := stream.Send(toProtoGame(ag)); err != nil {
+			return err
+		}
+		if ag.Result != chess.Ongoing {
+			return nil
+		}
+		select {
+		case <-updates:
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		case <-c.stop:
+			return status.Error(codes.Unavailable, "server is shutting down")
+		}
+	}
+}
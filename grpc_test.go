@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/rigurd/chess"
+	"github.com/rigurd/rigurdpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// newTestAPIKey issues an API key of scope on s, returning the key itself.
+func newTestAPIKey(t *testing.T, s *Server, scope apiScope) string {
+	t.Helper()
+	token := newAPIKey()
+	key := APIKey{ID: newGameID(), Name: "test", Scope: scope, Created: time.Now().UTC()}
+	if err := s.games.store.CreateAPIKey(key, hashAPIKey(token)); err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// newTestGRPCClient serves the gRPC API of s in memory, returning a client
+// of it.
+func newTestGRPCClient(t *testing.T, s *Server) rigurdpb.ChessClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv, endStreams := newGRPCServer(s)
+	go srv.Serve(lis)
+	t.Cleanup(func() {
+		endStreams()
+		srv.Stop()
+	})
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return rigurdpb.NewChessClient(conn)
+}
+
+// withAPIKey returns ctx sending key as the bearer token of its calls.
+func withBearer(ctx context.Context, key string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+key)
+}
+
+func TestGRPCAuthentication(t *testing.T) {
+	s, _ := newTestServer(t)
+	client := newTestGRPCClient(t, s)
+	g := newTestGame(t, s, chess.StartFEN)
+	ctx := context.Background()
+	reader := newTestAPIKey(t, s, scopeRead)
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		call func(ctx context.Context) error
+		want codes.Code
+	}{
+		{"no key", ctx, func(ctx context.Context) error {
+			_, err := client.GetGame(ctx, &rigurdpb.GetGameRequest{Id: g.ID})
+			return err
+		}, codes.Unauthenticated},
+		{"unknown key", withBearer(ctx, newAPIKey()), func(ctx context.Context) error {
+			_, err := client.GetGame(ctx, &rigurdpb.GetGameRequest{Id: g.ID})
+			return err
+		}, codes.Unauthenticated},
+		{"admin token", metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+testAdminToken), func(ctx context.Context) error {
+			_, err := client.GetGame(ctx, &rigurdpb.GetGameRequest{Id: g.ID})
+			return err
+		}, codes.Unauthenticated},
+		{"stream without key", ctx, func(ctx context.Context) error {
+			stream, err := client.StreamGame(ctx, &rigurdpb.StreamGameRequest{Id: g.ID})
+			if err != nil {
+				return err
+			}
+			_, err = stream.Recv()
+			return err
+		}, codes.Unauthenticated},
+		{"read-only key reads", withBearer(ctx, reader), func(ctx context.Context) error {
+			_, err := client.GetGame(ctx, &rigurdpb.GetGameRequest{Id: g.ID})
+			return err
+		}, codes.OK},
+		{"read-only key moves", withBearer(ctx, reader), func(ctx context.Context) error {
+			_, err := client.MakeMove(ctx, &rigurdpb.MakeMoveRequest{GameId: g.ID, Move: "e4"})
+			return err
+		}, codes.PermissionDenied},
+		{"read-only key creates", withBearer(ctx, reader), func(ctx context.Context) error {
+			_, err := client.CreateGame(ctx, &rigurdpb.CreateGameRequest{})
+			return err
+		}, codes.PermissionDenied},
+	}
+	for _, tt := range tests {
+		if got := status.Code(tt.call(tt.ctx)); got != tt.want {
+			t.Errorf("%s: code %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	var fen string
+	g.do(func() { fen = g.FEN() })
+	if fen != chess.StartFEN {
+		t.Errorf("FEN after refused calls = %q, want the start", fen)
+	}
+}
+
+func TestGRPCMakeMovePlaysAsAPIKey(t *testing.T) {
+	s, _ := newTestServer(t)
+	client := newTestGRPCClient(t, s)
+	white := withBearer(context.Background(), newTestAPIKey(t, s, scopePlay))
+	black := withBearer(context.Background(), newTestAPIKey(t, s, scopePlay))
+
+	pg, err := client.CreateGame(white, &rigurdpb.CreateGameRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.MakeMove(white, &rigurdpb.MakeMoveRequest{GameId: pg.Id, Move: "e4"}); err != nil {
+		t.Fatalf("white e4: %v", err)
+	}
+	// The player the request names is ignored for the API key's own
+	_, err = client.MakeMove(black, &rigurdpb.MakeMoveRequest{GameId: pg.Id, Move: "e5", Player: "anyone"})
+	if err != nil {
+		t.Fatalf("black e5: %v", err)
+	}
+	_, err = client.MakeMove(black, &rigurdpb.MakeMoveRequest{GameId: pg.Id, Move: "Nf3"})
+	if code := status.Code(err); code != codes.PermissionDenied {
+		t.Errorf("black moving white: code %v, want %v", code, codes.PermissionDenied)
+	}
+	if _, err := client.MakeMove(white, &rigurdpb.MakeMoveRequest{GameId: pg.Id, Move: "Nf3"}); err != nil {
+		t.Errorf("white Nf3: %v", err)
+	}
+}
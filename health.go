@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/rigurd/chess"
+)
+
+// healthTimeout bounds how long a health check waits on the store.
+const healthTimeout = 2 * time.Second
+
+// pingStore is a GameStore reached over a connection, which can be checked
+// to still be up.
+type pingStore interface {
+	GameStore
+	// Ping checks the store can be reached.
+	Ping(ctx context.Context) error
+}
+
+func (st *sqlStore) Ping(ctx context.Context) error { return st.db.PingContext(ctx) }
+
+func (st *redisStore) Ping(ctx context.Context) error { return st.rdb.Ping(ctx).Err() }
+
+// healthCheck is the outcome of checking one thing the server depends on.
+type healthCheck struct {
+	Status string `json:"status"` // "ok" or "error"
+	Error  string `json:"error,omitempty"`
+}
+
+// healthReport is what /healthz and /readyz answer.
+type healthReport struct {
+	Status       string                 `json:"status"` // "ok" or "unavailable"
+	ShuttingDown bool                   `json:"shutting_down,omitempty"`
+	Checks       map[string]healthCheck `json:"checks"`
+	Games        gameCounts             `json:"games"`
+	Uptime       string                 `json:"uptime"`
+}
+
+// gameCounts counts the games a server holds in memory.
+type gameCounts struct {
+	Loaded  int `json:"loaded"`  // held in memory
+	Ongoing int `json:"ongoing"` // of those, still being played
+}
+
+// counts returns how many games are held in memory, and how many of them
+// are still being played.
+func (m *GameManager) counts() gameCounts {
+	m.mu.RLock()
+	games := make([]*Game, 0, len(m.games))
+	for _, g := range m.games {
+		games = append(games, g)
+	}
+	m.mu.RUnlock()
+
+	c := gameCounts{Loaded: len(games)}
+	for _, g := range games {
+		g.do(func() {
+			if g.Result == chess.Ongoing {
+				c.Ongoing++
+			}
+		})
+	}
+	return c
+}
+
+// health checks what the server depends on, reporting unavailable if any
+// check fails.
+func (s *Server) health(ctx context.Context) healthReport {
+	report := healthReport{
+		Status: "ok",
+		Checks: make(map[string]healthCheck),
+		Games:  s.games.counts(),
+		Uptime: time.Since(s.started).Round(time.Second).String(),
+	}
+
+	store := healthCheck{Status: "ok"}
+	if st, ok := s.games.store.(pingStore); ok {
+		ctx, cancel := context.WithTimeout(ctx, healthTimeout)
+		defer cancel()
+		if err := st.Ping(ctx); err != nil {
+			store = healthCheck{Status: "error", Error: err.Error()}
+		}
+	}
+	report.Checks["store"] = store
+
+	for _, check := range report.Checks {
+		if check.Status != "ok" {
+			report.Status = "unavailable"
+		}
+	}
+	return report
+}
+
+// writeHealth answers a probe with the report, as 503 Service Unavailable
+// unless it is ok.
+func writeHealth(w http.ResponseWriter, report healthReport) {
+	w.Header().Set("Cache-Control", "no-store")
+	status := http.StatusOK
+	if report.Status != "ok" {
+		status = http.StatusServiceUnavailable
+	}
+	writeAPIStatus(w, status, report)
+}
+
+// requireProbe answers 405 Method Not Allowed unless the request is a GET
+// or HEAD, as probes send.
+func requireProbe(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return false
+	}
+	return true
+}
+
+// handleLivez answers the liveness probe: the server is up if it answers at
+// all, so it is not restarted for the store being down.
+func (s *Server) handleLivez(w http.ResponseWriter, r *http.Request) {
+	if !requireProbe(w, r) {
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte("ok\n"))
+}
+
+// handleHealthz reports whether the store can be reached, with the number
+// of games in memory.
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	if !requireProbe(w, r) {
+		return
+	}
+	writeHealth(w, s.health(r.Context()))
+}
+
+// handleReadyz answers the readiness probe with the health report, as
+// unavailable too once the server is shutting down, so load balancers stop
+// sending it players before it stops.
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	if !requireProbe(w, r) {
+		return
+	}
+	report := s.health(r.Context())
+	if s.draining.Load() {
+		report.Status = "unavailable"
+		report.ShuttingDown = true
+	}
+	writeHealth(w, report)
+}
@@ -0,0 +1,389 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/rigurd/chess"
+)
+
+const (
+	defaultImageSize = 400
+	minImageSize     = 64
+	maxImageSize     = 1024
+	maxCachedImages  = 256
+)
+
+var (
+	lightSquareColor = color.RGBA{0xf0, 0xd9, 0xb5, 0xff}
+	darkSquareColor  = color.RGBA{0xb5, 0x88, 0x63, 0xff}
+	whitePieceColor  = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	blackPieceColor  = color.RGBA{0x00, 0x00, 0x00, 0xff}
+)
+
+// pieceMasks are 12x12 silhouettes of each piece type, scaled to the square
+// size when drawn.
+var pieceMasks = map[string][]string{
+	"P": {
+		"............",
+		"............",
+		".....##.....",
+		"....####....",
+		"....####....",
+		".....##.....",
+		"....####....",
+		".....##.....",
+		"....####....",
+		"...######...",
+		"..########..",
+		"............",
+	},
+	"N": {
+		"............",
+		".....##.....",
+		"....####....",
+		"...######...",
+		"..###.####..",
+		"..##..####..",
+		".....#####..",
+		"....#####...",
+		"....#####...",
+		"...######...",
+		"..########..",
+		"............",
+	},
+	"B": {
+		"............",
+		".....##.....",
+		"....####....",
+		"...###.##...",
+		"...##.###...",
+		"...######...",
+		"....####....",
+		".....##.....",
+		"....####....",
+		"...######...",
+		"..########..",
+		"............",
+	},
+	"R": {
+		"............",
+		"..##.##.##..",
+		"..########..",
+		"...######...",
+		"....####....",
+		"....####....",
+		"....####....",
+		"....####....",
+		"...######...",
+		"..########..",
+		"..########..",
+		"............",
+	},
+	"Q": {
+		"............",
+		".#...##...#.",
+		".##..##..##.",
+		".###.##.###.",
+		".##########.",
+		"..########..",
+		"...######...",
+		"...######...",
+		"....####....",
+		"...######...",
+		"..########..",
+		"............",
+	},
+	"K": {
+		".....##.....",
+		"....####....",
+		".....##.....",
+		"...######...",
+		"..########..",
+		"..########..",
+		"...######...",
+		"....####....",
+		"....####....",
+		"...######...",
+		"..########..",
+		"............",
+	},
+}
+
+// imageKey identifies a cached board image.
+type imageKey struct {
+	hash   uint64
+	flip   bool
+	size   int
+	format string // "png" or "svg"
+}
+
+var (
+	imageCache   = make(map[imageKey][]byte)
+	imageCacheMu sync.Mutex
+)
+
+// cachedImage returns the encoded image stored under key, if any.
+func cachedImage(key imageKey) ([]byte, bool) {
+	imageCacheMu.Lock()
+	defer imageCacheMu.Unlock()
+	data, ok := imageCache[key]
+	return data, ok
+}
+
+// cacheImage stores an encoded image, starting afresh once the cache is full.
+func cacheImage(key imageKey, data []byte) {
+	imageCacheMu.Lock()
+	defer imageCacheMu.Unlock()
+	if len(imageCache) >= maxCachedImages {
+		imageCache = make(map[imageKey][]byte)
+	}
+	imageCache[key] = data
+}
+
+// boardHash returns a hash of the piece placement.
+func boardHash(g *Game) uint64 {
+	h := fnv.New64a()
+	for _, row := range g.Board {
+		for _, p := range row {
+			h.Write([]byte(p))
+			h.Write([]byte{0})
+		}
+	}
+	return h.Sum64()
+}
+
+// renderBoardImage draws the board as a size x size image, from Black's
+// side when flip is set.
+func renderBoardImage(g *chess.GameState, size int, flip bool) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	sq := size / 8
+	for r := 0; r < 8; r++ {
+		for c := 0; c < 8; c++ {
+			br, bc := r, c
+			if flip {
+				br, bc = 7-r, 7-c
+			}
+			bg := lightSquareColor
+			if (br+bc)%2 == 1 {
+				bg = darkSquareColor
+			}
+			rect := image.Rect(c*sq, r*sq, (c+1)*sq, (r+1)*sq)
+			draw.Draw(img, rect, &image.Uniform{bg}, image.Point{}, draw.Src)
+			if p := g.Board[br][bc]; p != chess.Empty {
+				drawPiece(img, rect, p)
+			}
+		}
+	}
+	return img
+}
+
+// drawPiece paints a piece silhouette into rect with a contrasting outline.
+func drawPiece(img *image.RGBA, rect image.Rectangle, p chess.Piece) {
+	mask := pieceMasks[chess.PieceLetter(p)]
+	fill, outline := whitePieceColor, blackPieceColor
+	if !chess.IsWhite(p) {
+		fill, outline = blackPieceColor, whitePieceColor
+	}
+	set := func(x, y int) bool {
+		return y >= 0 && y < len(mask) && x >= 0 && x < len(mask[y]) && mask[y][x] == '#'
+	}
+
+	n := len(mask)
+	cell := rect.Dx() / n
+	if cell == 0 {
+		return
+	}
+	off := image.Pt(rect.Min.X+(rect.Dx()-cell*n)/2, rect.Min.Y+(rect.Dy()-cell*n)/2)
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			var col color.RGBA
+			switch {
+			case set(x, y):
+				col = fill
+			case set(x-1, y) || set(x+1, y) || set(x, y-1) || set(x, y+1):
+				col = outline
+			default:
+				continue
+			}
+			cellRect := image.Rect(x*cell, y*cell, (x+1)*cell, (y+1)*cell).Add(off)
+			draw.Draw(img, cellRect, &image.Uniform{col}, image.Point{}, draw.Src)
+		}
+	}
+}
+
+// renderBoardSVG draws the board as a size x size SVG document, from
+// Black's side when flip is set. Pieces use the same silhouettes as the PNG
+// so both formats look alike without depending on the viewer's fonts.
+func renderBoardSVG(g *Game, size int, flip bool) []byte {
+	var b bytes.Buffer
+	sq := size / 8
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`, size, size, size, size)
+	b.WriteString("<defs>")
+	for _, letter := range []string{"P", "N", "B", "R", "Q", "K"} {
+		writeSVGSymbol(&b, "w"+letter, pieceMasks[letter], whitePieceColor, blackPieceColor)
+		writeSVGSymbol(&b, "b"+letter, pieceMasks[letter], blackPieceColor, whitePieceColor)
+	}
+	b.WriteString("</defs>")
+	for r := 0; r < 8; r++ {
+		for c := 0; c < 8; c++ {
+			br, bc := r, c
+			if flip {
+				br, bc = 7-r, 7-c
+			}
+			bg := lightSquareColor
+			if (br+bc)%2 == 1 {
+				bg = darkSquareColor
+			}
+			fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%d" height="%d" fill="%s"/>`, c*sq, r*sq, sq, sq, svgColor(bg))
+			if p := g.Board[br][bc]; p != chess.Empty {
+				id := "b" + chess.PieceLetter(p)
+				if chess.IsWhite(p) {
+					id = "w" + chess.PieceLetter(p)
+				}
+				fmt.Fprintf(&b, `<use href="#%s" x="%d" y="%d" width="%d" height="%d"/>`, id, c*sq, r*sq, sq, sq)
+			}
+		}
+	}
+	b.WriteString("</svg>")
+	return b.Bytes()
+}
+
+// writeSVGSymbol writes a piece silhouette as a reusable symbol, outlined
+// like drawPiece does.
+func writeSVGSymbol(b *bytes.Buffer, id string, mask []string, fill, outline color.RGBA) {
+	n := len(mask)
+	set := func(x, y int) bool {
+		return y >= 0 && y < n && x >= 0 && x < len(mask[y]) && mask[y][x] == '#'
+	}
+	fmt.Fprintf(b, `<symbol id="%s" viewBox="0 0 %d %d" shape-rendering="crispEdges">`, id, n, n)
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			var col color.RGBA
+			switch {
+			case set(x, y):
+				col = fill
+			case set(x-1, y) || set(x+1, y) || set(x, y-1) || set(x, y+1):
+				col = outline
+			default:
+				continue
+			}
+			fmt.Fprintf(b, `<rect x="%d" y="%d" width="1" height="1" fill="%s"/>`, x, y, svgColor(col))
+		}
+	}
+	b.WriteString("</symbol>")
+}
+
+// svgColor formats a color as an SVG hex color.
+func svgColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// handleBoardPNG serves the current position as a PNG image.
+func (s *Server) handleBoardPNG(w http.ResponseWriter, r *http.Request, g *Game) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	serveBoardImage(w, r, g, "png")
+}
+
+// handleGameImage serves a game's current position as a static image at
+// /image/{gameID}.svg or /image/{gameID}.png, for embedding in forums, chat
+// apps and link previews, and the whole game as an animation at
+// /image/{gameID}.gif. The size and flip parameters work as for /board.png.
+func (s *Server) handleGameImage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := strings.TrimPrefix(r.URL.Path, "/image/")
+	id, format, ok := strings.Cut(name, ".")
+	if !ok || (format != "svg" && format != "png" && format != "gif") {
+		http.Error(w, "image must be .svg, .png or .gif", http.StatusNotFound)
+		return
+	}
+	g, found := s.games.Get(id)
+	if !found {
+		http.Error(w, "unknown game "+id, http.StatusNotFound)
+		return
+	}
+	if format == "gif" {
+		serveGameGIF(w, r, g)
+		return
+	}
+	serveBoardImage(w, r, g, format)
+}
+
+// imageSizeParam reads the size parameter of an image request, clamped to
+// the supported range and rounded down to a multiple of 8.
+func imageSizeParam(r *http.Request) (int, error) {
+	size := defaultImageSize
+	if s := r.FormValue("size"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return 0, errors.New("size must be a number")
+		}
+		size = min(max(n, minImageSize), maxImageSize)
+	}
+	return size - size%8, nil
+}
+
+// serveBoardImage writes g's position as a PNG or SVG image, reusing a
+// cached encoding of the same placement when there is one.
+func serveBoardImage(w http.ResponseWriter, r *http.Request, g *Game, format string) {
+	size, err := imageSizeParam(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	flip := r.FormValue("flip") == "1"
+
+	var (
+		key  imageKey
+		data []byte
+		ok   bool
+		img  *image.RGBA
+	)
+	g.do(func() {
+		key = imageKey{hash: boardHash(g), flip: flip, size: size, format: format}
+		data, ok = cachedImage(key)
+		if !ok {
+			if format == "svg" {
+				data = renderBoardSVG(g, size, flip)
+			} else {
+				img = renderBoardImage(&g.GameState, size, flip)
+			}
+		}
+	})
+
+	if !ok {
+		if img != nil {
+			var buf bytes.Buffer
+			if err := png.Encode(&buf, img); err != nil {
+				http.Error(w, "failed to encode image", http.StatusInternalServerError)
+				return
+			}
+			data = buf.Bytes()
+		}
+		cacheImage(key, data)
+	}
+
+	if format == "svg" {
+		w.Header().Set("Content-Type", "image/svg+xml")
+	} else {
+		w.Header().Set("Content-Type", "image/png")
+	}
+	w.Write(data)
+}
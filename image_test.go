@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/rigurd/chess"
+)
+
+func TestHandleBoardPNG(t *testing.T) {
+	s, ts := newTestServer(t)
+	g := newTestGame(t, s, "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1")
+	path := ts.URL + gamePath(g, "/board.png")
+
+	get := func(query string) ([]byte, image.Image) {
+		t.Helper()
+		resp, err := http.Get(path + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s: status %d: %s", query, resp.StatusCode, data)
+		}
+		if ct := resp.Header.Get("Content-Type"); ct != "image/png" {
+			t.Fatalf("%s: content type %q, want image/png", query, ct)
+		}
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		return data, img
+	}
+
+	sizes := []struct {
+		query string
+		want  int
+	}{
+		{"", defaultImageSize},
+		{"?size=203", 200},
+		{"?size=1", minImageSize},
+		{"?size=5000", maxImageSize},
+	}
+	for _, tt := range sizes {
+		if _, img := get(tt.query); img.Bounds().Dx() != tt.want || img.Bounds().Dy() != tt.want {
+			t.Errorf("%q: image is %v, want %dx%d", tt.query, img.Bounds().Size(), tt.want, tt.want)
+		}
+	}
+
+	// A point inside the rook in the top left corner: black's on a8, or
+	// white's on h1 once flipped
+	const x, y = 22, 10
+	if _, img := get(""); img.At(x, y) != color.Color(blackPieceColor) {
+		t.Errorf("top left corner is %v, want black's rook", img.At(x, y))
+	}
+	if _, img := get("?flip=1"); img.At(x, y) != color.Color(whitePieceColor) {
+		t.Errorf("flipped top left corner is %v, want white's rook", img.At(x, y))
+	}
+
+	var key imageKey
+	g.do(func() { key = imageKey{hash: boardHash(g), size: defaultImageSize, format: "png"} })
+	cached, ok := cachedImage(key)
+	start, _ := get("")
+	if !ok || string(cached) != string(start) {
+		t.Error("the image of the position is not cached")
+	}
+	var err error
+	g.update(func() {
+		var m chess.Move
+		if m, err = chess.ParseUCI(&g.GameState, "e2e4"); err == nil {
+			err = g.play(m)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if moved, _ := get(""); string(moved) == string(start) {
+		t.Error("the image did not change with the position")
+	}
+
+	c := newTestClient(t, s, ts)
+	if status, _ := c.do(http.MethodPost, gamePath(g, "/board.png"), nil, nil); status != http.StatusMethodNotAllowed {
+		t.Errorf("POST: status %d, want %d", status, http.StatusMethodNotAllowed)
+	}
+	if status, _ := c.get(gamePath(g, "/board.png?size=big")); status != http.StatusBadRequest {
+		t.Errorf("size=big: status %d, want %d", status, http.StatusBadRequest)
+	}
+}
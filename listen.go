@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// checkTLS reports a TLS configuration the server cannot be started with.
+func checkTLS(cfg Config) error {
+	switch {
+	case (cfg.TLSCert == "") != (cfg.TLSKey == ""):
+		return errors.New("TLS needs both a certificate and its key")
+	case cfg.TLSCert != "" && len(cfg.AutocertHosts) > 0:
+		return errors.New("give either a TLS certificate or autocert hosts, not both")
+	case cfg.RedirectAddr != "" && !cfg.tls():
+		return errors.New("redirecting to HTTPS needs TLS")
+	}
+	return nil
+}
+
+// tls reports whether the server is served over HTTPS.
+func (cfg Config) tls() bool {
+	return cfg.TLSCert != "" || len(cfg.AutocertHosts) > 0
+}
+
+// httpServer returns the server of the pages and APIs, with the configured
+// timeouts, and for autocert a TLS configuration getting certificates from
+// Let's Encrypt. The certificate manager is returned too, if any, to answer
+// its challenges over plain HTTP.
+func (s *Server) httpServer() (*http.Server, *autocert.Manager) {
+	srv := &http.Server{
+		Addr:              s.config.Addr,
+		Handler:           s,
+		ReadHeaderTimeout: s.config.ReadHeaderTimeout,
+		ReadTimeout:       s.config.ReadTimeout,
+		WriteTimeout:      s.config.WriteTimeout,
+		IdleTimeout:       s.config.IdleTimeout,
+	}
+	if len(s.config.AutocertHosts) == 0 {
+		return srv, nil
+	}
+	m := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(s.config.AutocertHosts...),
+		Cache:      autocert.DirCache(s.config.AutocertCache),
+	}
+	srv.TLSConfig = m.TLSConfig()
+	return srv, m
+}
+
+// listen serves srv over HTTPS if it is configured, otherwise over HTTP.
+func (s *Server) listen(srv *http.Server) error {
+	switch {
+	case s.config.TLSCert != "":
+		return srv.ListenAndServeTLS(s.config.TLSCert, s.config.TLSKey)
+	case srv.TLSConfig != nil:
+		// The certificates come from the TLS configuration
+		return srv.ListenAndServeTLS("", "")
+	}
+	return srv.ListenAndServe()
+}
+
+// redirectServer returns the server of plain HTTP on the redirect address,
+// sending visitors to HTTPS and answering the ACME challenges of m, if
+// any, or nil if there is no redirect address.
+func (s *Server) redirectServer(m *autocert.Manager) *http.Server {
+	if s.config.RedirectAddr == "" {
+		return nil
+	}
+	var h http.Handler = http.HandlerFunc(s.redirectHTTPS)
+	if m != nil {
+		h = m.HTTPHandler(h)
+	}
+	return &http.Server{
+		Addr:              s.config.RedirectAddr,
+		Handler:           h,
+		ReadHeaderTimeout: s.config.ReadHeaderTimeout,
+		ReadTimeout:       s.config.ReadTimeout,
+		WriteTimeout:      s.config.WriteTimeout,
+		IdleTimeout:       s.config.IdleTimeout,
+	}
+}
+
+// redirectHTTPS sends a plain HTTP request to the same URL over HTTPS, on
+// the port the server listens on.
+func (s *Server) redirectHTTPS(w http.ResponseWriter, r *http.Request) {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if _, port, err := net.SplitHostPort(s.config.Addr); err == nil && port != "443" {
+		host = net.JoinHostPort(host, port)
+	}
+	http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+}
+
+// keepOpen lifts the server's read and write timeouts off a response that
+// stays open, a WebSocket or a stream, which they would otherwise cut.
+func keepOpen(w http.ResponseWriter) {
+	rc := http.NewResponseController(w)
+	rc.SetReadDeadline(time.Time{})
+	rc.SetWriteDeadline(time.Time{})
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rigurd/chess"
+	"golang.org/x/net/websocket"
+)
+
+// maxChatMessages is how many of a game's latest chat messages are kept
+// for those who join later. Chat is not stored, so a game loaded again
+// starts with none.
+const maxChatMessages = 100
+
+// maxChatLength bounds the length of a chat message, in bytes.
+const maxChatLength = 500
+
+// ChatMessage is a message a player sent to the game's chat.
+type ChatMessage struct {
+	ID   int              `json:"id"` // increases with each message of the game
+	From chess.PieceColor `json:"from"`
+	Text string           `json:"text"`
+	Time time.Time        `json:"time"`
+}
+
+// liveEvent is a message pushed over a game's WebSocket: "update" with the
+// game and its board after any change, "chat" with a message of the
+// players, "end" with the game once it is over, and "error" with why a
+// command of the client was refused.
+type liveEvent struct {
+	Type  string       `json:"type"`
+	Game  *apiGame     `json:"game,omitempty"`
+	Board string       `json:"board,omitempty"` // HTML of the board, as /board renders it
+	Chat  *ChatMessage `json:"chat,omitempty"`
+	Error string       `json:"error,omitempty"`
+}
+
+// liveCommand is a message a client sends over a game's WebSocket. Only
+// "chat", sending Text to the chat, is understood; moves are made over
+// HTTP as usual.
+type liveCommand struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// liveView is what one WebSocket client has been sent of a game.
+type liveView struct {
+	started  bool
+	lastChat int  // ID of the last chat message sent
+	over     bool // whether the game was over at the last update
+}
+
+// catchUp returns the events bringing the client up to date with g: the
+// game as it is now, its board with the threats overlay if threats is set,
+// the chat messages it has not been sent, and the end of the game if that
+// happened since. It must be called from a command of g's goroutine.
+func (v *liveView) catchUp(g *Game, session string, threats bool) []liveEvent {
+	ag := newAPIGame(g, session)
+	var board strings.Builder
+	chessboardWithLabels(g, threatSquares(g, threats)).Render(context.Background(), &board)
+	events := []liveEvent{{Type: "update", Game: &ag, Board: board.String()}}
+	for _, m := range g.Chat {
+		if m.ID > v.lastChat {
+			events = append(events, liveEvent{Type: "chat", Chat: &m})
+			v.lastChat = m.ID
+		}
+	}
+	over := g.Result != chess.Ongoing
+	if over && !v.over && v.started {
+		events = append(events, liveEvent{Type: "end", Game: &ag})
+	}
+	v.over = over
+	v.started = true
+	return events
+}
+
+// checkSameOrigin refuses a WebSocket opened by a page of another site,
+// which would otherwise chat with the visitor's session. Clients that are
+// not browsers send no Origin and are let through.
+func checkSameOrigin(config *websocket.Config, r *http.Request) error {
+	origin, err := websocket.Origin(config, r)
+	if err != nil {
+		return err
+	}
+	if origin != nil && origin.Host != r.Host {
+		return errors.New("cross-origin WebSocket refused")
+	}
+	config.Origin = origin
+	return nil
+}
+
+// handleWebSocket pushes the game's updates, chat and end to the client as
+// they happen, over a WebSocket, to players and spectators alike.
+func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request, g *Game) {
+	ws := websocket.Server{
+		Handshake: checkSameOrigin,
+		Handler:   func(conn *websocket.Conn) { s.serveLive(conn, r, g) },
+	}
+	keepOpen(w)
+	ws.ServeHTTP(w, r)
+}
+
+// serveLive sends the events of g over conn until the client goes away,
+// taking its chat messages meanwhile.
+func (s *Server) serveLive(conn *websocket.Conn, r *http.Request, g *Game) {
+	defer conn.Close()
+	updates, unsubscribe := g.subscribe()
+	defer unsubscribe()
+
+	// The client's commands are read on a goroutine of their own, which
+	// notices when the client goes away
+	gone := make(chan struct{})
+	go func() {
+		defer close(gone)
+		for {
+			var cmd liveCommand
+			if err := websocket.JSON.Receive(conn, &cmd); err != nil {
+				return
+			}
+			var err error
+			switch cmd.Type {
+			case "chat":
+				err = s.chat(r, g, cmd.Text)
+			default:
+				err = errors.New("unknown command " + cmd.Type)
+			}
+			if err != nil {
+				websocket.JSON.Send(conn, liveEvent{Type: "error", Error: err.Error()})
+			}
+		}
+	}()
+
+	var view liveView
+	session, threats := sessionOf(r), showThreats(r)
+	for {
+		var events []liveEvent
+		g.do(func() { events = view.catchUp(g, session, threats) })
+		for _, e := range events {
+			if err := websocket.JSON.Send(conn, e); err != nil {
+				return
+			}
+		}
+		select {
+		case <-updates:
+		case <-gone:
+			return
+		}
+	}
+}
+
+// chat adds text to g's chat from the request's player. Spectators may
+// read the chat but not write to it.
+func (s *Server) chat(r *http.Request, g *Game, text string) error {
+	text = strings.TrimSpace(text)
+	switch {
+	case s.draining.Load():
+		return errors.New("Server is shutting down")
+	case text == "":
+		return nil
+	case !canPlay(r):
+		return errors.New("This API key is read-only")
+	case len(text) > maxChatLength:
+		return errors.New("Message too long")
+	}
+	var err error
+	g.update(func() {
+		side, seated := g.sideOf(sessionOf(r))
+		if !seated {
+			err = errors.New("Only the players can chat")
+			return
+		}
+		id := 1
+		if n := len(g.Chat); n > 0 {
+			id = g.Chat[n-1].ID + 1
+		}
+		g.Chat = append(g.Chat, ChatMessage{ID: id, From: side, Text: text, Time: time.Now()})
+		if len(g.Chat) > maxChatMessages {
+			g.Chat = g.Chat[len(g.Chat)-maxChatMessages:]
+		}
+		s.audit(r, g, "chat", text)
+	})
+	return err
+}
@@ -1,294 +1,326 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"flag"
 	"log"
-	"math"
 	"net/http"
+	"os"
+	"os/signal"
 	"strconv"
-	"sync"
+	"syscall"
+	"time"
 
 	"github.com/a-h/templ"
+	"github.com/rigurd/chess"
 )
 
-// Piece represents a chess piece
-type Piece string
-
-const (
-	Empty       Piece = ""
-	WhitePawn   Piece = "♙"
-	WhiteRook   Piece = "♖"
-	WhiteKnight Piece = "♘"
-	WhiteBishop Piece = "♗"
-	WhiteQueen  Piece = "♕"
-	WhiteKing   Piece = "♔"
-	BlackPawn   Piece = "♟"
-	BlackRook   Piece = "♜"
-	BlackKnight Piece = "♞"
-	BlackBishop Piece = "♝"
-	BlackQueen  Piece = "♛"
-	BlackKing   Piece = "♚"
-)
-
-// Square represents a square on the board
-type Square struct {
-	Row int
-	Col int
+// Game is a chess game as played through the web interface: the rules state
+// plus what the viewer has selected and chosen.
+type Game struct {
+	chess.GameState
+	ID               string // identifies the game in shareable URLs
+	SelectedSquare   *chess.Square
+	SelectedDrop     chess.Piece       // reserve piece awaiting a target square
+	PendingPromotion *PendingPromotion // pawn move awaiting a promotion choice
+	Settings         GameSettings
+	LastError        error                       // why the last click was rejected, shown to the player
+	Tags             chess.PGNTags               // tag pairs for PGN export, from an import or the reset
+	Players          map[chess.PieceColor]string // session playing each side, kept across resets
+	Takeback         *TakebackRequest            // takeback awaiting the opponent's answer
+	Chat             []ChatMessage               // latest messages of the players' chat, kept across resets
+	stored           storedGame                  // what the store last saved of the game
+	archived         bool                        // whether the store has archived the finished game
+	started          time.Time                   // when the game was started, reset or loaded
+	wal              *moveLog                    // where moves are logged before they are played, or nil
+	logged           bool                        // whether moves were logged since the game was last saved
+	notified         notifiedState               // how far the webhooks were told of the game
+	actor            gameActor                   // runs the commands reading or changing the game
+	thinking         bool                        // whether the computer is thinking of its move
 }
 
-// PieceColor represents the color of a piece
-type PieceColor string
-
-const (
-	White PieceColor = "white"
-	Black PieceColor = "black"
-)
+// newGame returns a game set up at the standard starting position.
+func newGame() *Game {
+	g := &Game{ID: newGameID(), Players: make(map[chess.PieceColor]string)}
+	g.ResetBoard()
+	g.markNotified()
+	return g
+}
 
-// GameState holds the current state of the chess game.
-type GameState struct {
-	Board          [8][8]Piece
-	CurrentPlayer  PieceColor
-	SelectedSquare *Square
-	mu             sync.Mutex
+// newGameID returns a random, unguessable game ID, so only those a game's
+// link was shared with can play in it.
+func newGameID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
 }
 
-// Global game state (for simplicity in this example)
-var game *GameState
-
-func (gs *GameState) ResetBoard() {
-	gs.Board = [8][8]Piece{
-		{BlackRook, BlackKnight, BlackBishop, BlackQueen, BlackKing, BlackBishop, BlackKnight, BlackRook},
-		{BlackPawn, BlackPawn, BlackPawn, BlackPawn, BlackPawn, BlackPawn, BlackPawn, BlackPawn},
-		{Empty, Empty, Empty, Empty, Empty, Empty, Empty, Empty},
-		{Empty, Empty, Empty, Empty, Empty, Empty, Empty, Empty},
-		{Empty, Empty, Empty, Empty, Empty, Empty, Empty, Empty},
-		{Empty, Empty, Empty, Empty, Empty, Empty, Empty, Empty},
-		{WhitePawn, WhitePawn, WhitePawn, WhitePawn, WhitePawn, WhitePawn, WhitePawn, WhitePawn},
-		{WhiteRook, WhiteKnight, WhiteBishop, WhiteQueen, WhiteKing, WhiteBishop, WhiteKnight, WhiteRook},
+// ResetBoard puts the game back to the starting position and clears the
+// interface state of the previous game. Its goroutine and subscribers are
+// left untouched, as it is reset from one of its commands.
+func (g *Game) ResetBoard() {
+	g.GameState.ResetBoard()
+	g.SelectedSquare = nil
+	g.SelectedDrop = chess.Empty
+	g.PendingPromotion = nil
+	g.Settings = g.Settings.keptOnReset()
+	g.LastError = nil
+	g.Takeback = nil
+	g.started = time.Now()
+	g.Tags = chess.PGNTags{
+		Event: "Casual game",
+		Site:  "rigurd",
+		Date:  time.Now().Format("2006.01.02"),
 	}
-	gs.CurrentPlayer = White
-	gs.SelectedSquare = nil
 }
 
-func main() {
-	// Initialize the game state
-	game = &GameState{}
-	game.ResetBoard()
+// clearSelection drops any half-made move once the game has ended.
+func (g *Game) clearSelection() {
+	g.SelectedSquare = nil
+	g.SelectedDrop = chess.Empty
+	g.PendingPromotion = nil
+}
 
-	http.HandleFunc("/", handleGetBoard)
-	http.HandleFunc("/move", handleMove)
-	http.HandleFunc("/reset", handleReset)
+func main() {
+	cfg, err := loadConfig(os.Args[0], os.Args[1:])
+	switch {
+	case errors.Is(err, flag.ErrHelp):
+		return
+	case err != nil:
+		log.Fatalf("invalid configuration: %v", err)
+	}
 
-	log.Println("Starting server on :8080")
-	if err := http.ListenAndServe(":8080", nil); err != nil {
+	srv, err := NewServer(cfg)
+	if err != nil {
 		log.Fatalf("failed to start server: %v", err)
 	}
-}
 
-func handleGetBoard(w http.ResponseWriter, r *http.Request) {
-	templ.Handler(page(game)).ServeHTTP(w, r)
+	// A deploy stops the server with SIGTERM; the games are saved first
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	scheme := "http"
+	if cfg.tls() {
+		scheme = "https"
+	}
+	log.Printf("Starting server on %s (%s)", srv.config.Addr, scheme)
+	if err := srv.ListenAndServe(ctx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatalf("server failed: %v", err)
+	}
 }
 
-func handleReset(w http.ResponseWriter, r *http.Request) {
-	game.mu.Lock()
-	defer game.mu.Unlock()
-	game.ResetBoard()
-	templ.Handler(chessboardWithLabels(game)).ServeHTTP(w, r)
+func (s *Server) handleGetBoard(w http.ResponseWriter, r *http.Request, g *Game) {
+	g.do(func() {
+		templ.Handler(page(g, seatText(g, sessionOf(r)), chessboardWithLabels(g, threatSquares(g, showThreats(r))))).ServeHTTP(w, r)
+	})
 }
 
-func handleMove(w http.ResponseWriter, r *http.Request) {
+func (s *Server) handleReset(w http.ResponseWriter, r *http.Request, g *Game) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	row, _ := strconv.Atoi(r.FormValue("row"))
-	col, _ := strconv.Atoi(r.FormValue("col"))
-	to := Square{Row: row, Col: col}
-
-	game.mu.Lock()
-	defer game.mu.Unlock()
-
-	if game.SelectedSquare == nil {
-		// Attempt to select a piece
-		if game.Board[to.Row][to.Col] != Empty && isCorrectPlayer(game.Board[to.Row][to.Col], game.CurrentPlayer) {
-			game.SelectedSquare = &to
+	g.update(func() {
+		if !requirePlayer(w, r, g) {
+			return
 		}
-	} else {
-		// A piece is already selected, attempt to move it
-		from := game.SelectedSquare
-
-		// Deselect if clicking the same square
-		if from.Row == to.Row && from.Col == to.Col {
-			game.SelectedSquare = nil
-			templ.Handler(chessboardWithLabels(game)).ServeHTTP(w, r)
+		variant := chess.ParseVariant(r.FormValue("variant"))
+		if _, computer := g.computerSide(); computer && variant != chess.Standard {
+			http.Error(w, errComputerVariant.Error(), http.StatusBadRequest)
 			return
 		}
+		g.ResetBoard()
+		g.Variant = variant
+		g.Settings = settingsFromRequest(r, g.Settings)
+		s.audit(r, g, "reset", string(g.Variant))
+		templ.Handler(chessboardWithLabels(g, threatSquares(g, showThreats(r)))).ServeHTTP(w, r)
+	})
+}
 
-		// Check if the move is valid according to chess rules
-		if isValidMove(game, *from, to) {
-			// Move the piece
-			game.Board[to.Row][to.Col] = game.Board[from.Row][from.Col]
-			game.Board[from.Row][from.Col] = Empty
-
-			// Switch player
-			if game.CurrentPlayer == White {
-				game.CurrentPlayer = Black
-			} else {
-				game.CurrentPlayer = White
-			}
-		}
-		// Deselect after any move attempt (valid or invalid)
-		game.SelectedSquare = nil
+// handleResign ends the game with the player to move resigning.
+func (s *Server) handleResign(w http.ResponseWriter, r *http.Request, g *Game) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
 	}
 
-	templ.Handler(chessboardWithLabels(game)).ServeHTTP(w, r)
+	g.update(func() {
+		if !requireSide(w, r, g, g.CurrentPlayer) {
+			return
+		}
+		if g.Result == chess.Ongoing {
+			chess.Resign(&g.GameState)
+			g.clearSelection()
+			s.audit(r, g, "resign", "")
+		}
+		templ.Handler(chessboardWithLabels(g, threatSquares(g, showThreats(r)))).ServeHTTP(w, r)
+	})
 }
 
-// isValidMove checks if a move is valid for the given piece type.
-func isValidMove(g *GameState, from, to Square) bool {
-	piece := g.Board[from.Row][from.Col]
-	targetPiece := g.Board[to.Row][to.Col]
-
-	// Cannot capture your own piece
-	if targetPiece != Empty && isCorrectPlayer(targetPiece, g.CurrentPlayer) {
-		return false
+func (s *Server) handleMove(w http.ResponseWriter, r *http.Request, g *Game) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
 	}
-
-	switch piece {
-	case WhitePawn, BlackPawn:
-		return isValidPawnMove(g, from, to)
-	case WhiteRook, BlackRook:
-		return isValidRookMove(g, from, to)
-	case WhiteKnight, BlackKnight:
-		return isValidKnightMove(from, to)
-	case WhiteBishop, BlackBishop:
-		return isValidBishopMove(g, from, to)
-	case WhiteQueen, BlackQueen:
-		return isValidQueenMove(g, from, to)
-	case WhiteKing, BlackKing:
-		return isValidKingMove(from, to)
+	if !s.requireRate(w, r, rateMoves) {
+		return
 	}
-	return false
-}
 
-// isValidPawnMove checks pawn-specific move logic.
-func isValidPawnMove(g *GameState, from, to Square) bool {
-	targetPiece := g.Board[to.Row][to.Col]
-	rowDiff := to.Row - from.Row
-	colDiff := to.Col - from.Col
+	row, err1 := strconv.Atoi(r.FormValue("row"))
+	col, err2 := strconv.Atoi(r.FormValue("col"))
+	to := chess.Square{Row: row, Col: col}
+	if err1 != nil || err2 != nil || !chess.OnBoard(to) {
+		http.Error(w, "row and col must be between 0 and 7", http.StatusBadRequest)
+		return
+	}
 
-	if g.CurrentPlayer == White {
-		// Move one step forward
-		if colDiff == 0 && targetPiece == Empty && rowDiff == -1 {
-			return true
-		}
-		// Move two steps forward from start
-		if colDiff == 0 && targetPiece == Empty && from.Row == 6 && rowDiff == -2 && g.Board[from.Row-1][from.Col] == Empty {
-			return true
-		}
-		// Capture
-		if math.Abs(float64(colDiff)) == 1 && rowDiff == -1 && targetPiece != Empty {
-			return true
-		}
-	} else { // Black Player
-		// Move one step forward
-		if colDiff == 0 && targetPiece == Empty && rowDiff == 1 {
-			return true
-		}
-		// Move two steps forward from start
-		if colDiff == 0 && targetPiece == Empty && from.Row == 1 && rowDiff == 2 && g.Board[from.Row+1][from.Col] == Empty {
-			return true
+	g.update(func() {
+		if !requireSide(w, r, g, g.CurrentPlayer) {
+			return
 		}
-		// Capture
-		if math.Abs(float64(colDiff)) == 1 && rowDiff == 1 && targetPiece != Empty {
-			return true
+
+		plies := len(g.History)
+		g.LastError = handleClick(g, to)
+		s.auditMoves(r, g, plies)
+		var moveErr chess.MoveError
+		if errors.As(g.LastError, &moveErr) {
+			w.Header().Set("X-Move-Error", string(moveErr))
 		}
-	}
-	return false
+		templ.Handler(chessboardWithLabels(g, threatSquares(g, showThreats(r)))).ServeHTTP(w, r)
+	})
 }
 
-// isValidRookMove checks if the move is a valid straight line and the path is clear.
-func isValidRookMove(g *GameState, from, to Square) bool {
-	if from.Row != to.Row && from.Col != to.Col {
-		return false // Not a straight line
+// handleTextMove plays a move typed as SAN ("Nf3") or UCI ("g1f3"), for
+// players who would rather use the keyboard than click squares.
+func (s *Server) handleTextMove(w http.ResponseWriter, r *http.Request, g *Game) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
 	}
-	return isPathClear(g, from, to)
-}
+	if !s.requireRate(w, r, rateMoves) {
+		return
+	}
+
+	g.update(func() {
+		if !requireSide(w, r, g, g.CurrentPlayer) {
+			return
+		}
 
-// isValidKnightMove checks for the L-shaped knight move.
-func isValidKnightMove(from, to Square) bool {
-	absRowDiff := math.Abs(float64(to.Row - from.Row))
-	absColDiff := math.Abs(float64(to.Col - from.Col))
-	return (absRowDiff == 2 && absColDiff == 1) || (absRowDiff == 1 && absColDiff == 2)
+		plies := len(g.History)
+		g.LastError = playText(g, r.FormValue("move"))
+		s.auditMoves(r, g, plies)
+		var moveErr chess.MoveError
+		if errors.As(g.LastError, &moveErr) {
+			w.Header().Set("X-Move-Error", string(moveErr))
+		}
+		templ.Handler(chessboardWithLabels(g, threatSquares(g, showThreats(r)))).ServeHTTP(w, r)
+	})
 }
 
-// isValidBishopMove checks if the move is a valid diagonal and the path is clear.
-func isValidBishopMove(g *GameState, from, to Square) bool {
-	if math.Abs(float64(to.Row-from.Row)) != math.Abs(float64(to.Col-from.Col)) {
-		return false // Not a diagonal
+// playText parses and plays a typed move. It returns why the move was
+// rejected, if it was.
+func playText(g *Game, text string) error {
+	m, err := parseTextMove(g, text)
+	if err != nil {
+		return err
 	}
-	return isPathClear(g, from, to)
+	g.clearSelection()
+	return g.play(m)
 }
 
-// isValidQueenMove combines rook and bishop logic.
-func isValidQueenMove(g *GameState, from, to Square) bool {
-	isStraight := from.Row == to.Row || from.Col == to.Col
-	isDiagonal := math.Abs(float64(to.Row-from.Row)) == math.Abs(float64(to.Col-from.Col))
-	if !isStraight && !isDiagonal {
-		return false
+// parseTextMove parses a typed move, checking it may be played in g now.
+func parseTextMove(g *Game, text string) (chess.Move, error) {
+	if g.Result != chess.Ongoing {
+		return chess.Move{}, chess.ErrGameOver
+	}
+	m, err := chess.ParseMove(&g.GameState, text)
+	if err != nil {
+		return chess.Move{}, err
 	}
-	return isPathClear(g, from, to)
+	// Under touch-move a selected piece that can move must be the one moved
+	if touchMoveLocked(g) && (m.Drop != chess.Empty || m.From != *g.SelectedSquare) {
+		return chess.Move{}, errTouchMove
+	}
+	return m, nil
 }
 
-// isValidKingMove checks for a one-square move in any direction.
-func isValidKingMove(from, to Square) bool {
-	absRowDiff := math.Abs(float64(to.Row - from.Row))
-	absColDiff := math.Abs(float64(to.Col - from.Col))
-	return absRowDiff <= 1 && absColDiff <= 1
-}
+// handleClick applies a click on a board square: selecting a piece, moving
+// the selected piece there, or placing a pending drop. It returns why the
+// click was rejected, if it was.
+func handleClick(g *Game, to chess.Square) error {
+	// No further moves once the game is over
+	if g.Result != chess.Ongoing {
+		return chess.ErrGameOver
+	}
+
+	// Clicking the board while choosing a promotion piece abandons the move,
+	// though under touch-move the pawn stays selected
+	if g.PendingPromotion != nil {
+		if g.Settings.TouchMove {
+			from := g.PendingPromotion.From
+			g.SelectedSquare = &from
+		}
+		g.PendingPromotion = nil
+		return nil
+	}
 
-// isPathClear checks if there are any pieces between 'from' and 'to'.
-func isPathClear(g *GameState, from, to Square) bool {
-	rowStep := 0
-	if to.Row > from.Row {
-		rowStep = 1
-	} else if to.Row < from.Row {
-		rowStep = -1
+	// A reserve piece is pending, so this click chooses where to drop it
+	if g.SelectedDrop != chess.Empty {
+		p := g.SelectedDrop
+		g.SelectedDrop = chess.Empty
+		if !chess.IsValidDrop(&g.GameState, p, to) {
+			return chess.ErrIllegalDrop
+		}
+		return g.play(chess.Move{To: to, Drop: p})
 	}
 
-	colStep := 0
-	if to.Col > from.Col {
-		colStep = 1
-	} else if to.Col < from.Col {
-		colStep = -1
+	if g.SelectedSquare == nil {
+		// Attempt to select a piece
+		p := g.Board[to.Row][to.Col]
+		if p == chess.Empty {
+			return nil
+		}
+		if chess.ColorOf(p) != g.CurrentPlayer {
+			return chess.ErrWrongTurn
+		}
+		g.SelectedSquare = &to
+		return nil
 	}
 
-	currRow, currCol := from.Row+rowStep, from.Col+colStep
-	for currRow != to.Row || currCol != to.Col {
-		if g.Board[currRow][currCol] != Empty {
-			return false // Path is blocked
+	// A piece is already selected, attempt to move it
+	from := *g.SelectedSquare
+
+	// Deselect if clicking the same square, unless touch-move forbids it
+	if from == to {
+		if !touchMoveLocked(g) {
+			g.SelectedSquare = nil
 		}
-		currRow += rowStep
-		currCol += colStep
+		return nil
 	}
-	return true // Path is clear
-}
 
-// isCorrectPlayer checks if a piece belongs to the current player.
-func isCorrectPlayer(p Piece, player PieceColor) bool {
-	isWhite := isWhitePieceMove(p)
-	if player == White {
-		return isWhite
+	// Check if the move is valid according to chess rules
+	if err := chess.ValidateMove(&g.GameState, from, to); err != nil {
+		// Deselect after an invalid attempt, unless the touched piece must
+		// still be moved
+		if !touchMoveLocked(g) {
+			g.SelectedSquare = nil
+		}
+		return err
 	}
-	return !isWhite
-}
 
-// isWhitePieceMove determines the color of a piece.
-func isWhitePieceMove(p Piece) bool {
-	switch p {
-	case WhitePawn, WhiteRook, WhiteKnight, WhiteBishop, WhiteQueen, WhiteKing:
-		return true
+	g.SelectedSquare = nil
+	switch {
+	case chess.IsPromotionMove(&g.GameState, from, to) && g.Settings.AutoQueen:
+		return g.play(chess.Move{From: from, To: to, Promotion: chess.PieceFromLetter("Q", g.CurrentPlayer)})
+	case chess.IsPromotionMove(&g.GameState, from, to):
+		// Wait for the player to pick the promotion piece
+		g.PendingPromotion = &PendingPromotion{From: from, To: to}
 	default:
-		return false
+		return g.play(chess.Move{From: from, To: to})
 	}
+	return nil
 }
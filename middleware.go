@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"time"
+)
+
+// maxRequestIDLen bounds a request ID taken from the client.
+const maxRequestIDLen = 64
+
+// middleware wraps a handler in something done for every request.
+type middleware func(http.Handler) http.Handler
+
+// chain wraps h in mws, the first of them outermost.
+func chain(h http.Handler, mws ...middleware) http.Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
+
+// requestIDKey is the context key of the request's ID.
+type requestIDKey struct{}
+
+// requestIDOf returns the ID withRequestID gave the request.
+func requestIDOf(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDKey{}).(string)
+	return id
+}
+
+// validRequestID reports whether id, sent by a client, is short and plain
+// enough to be logged as is.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_', c == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// withRequestID gives each request an ID, the one in its X-Request-ID
+// header if a proxy in front set one, and returns it in the same header so
+// a player's report can be matched to the logs.
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if !validRequestID(id) {
+			id = newGameID()
+		}
+		w.Header().Set("X-Request-ID", id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+// statusRecorder remembers the status and size of a response, for the
+// access log and to tell whether a panic can still be answered. It passes
+// flushes and hijacks through, for the streams and WebSockets.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int // 0 until the header is written
+	bytes  int
+}
+
+// recordStatus returns w as a statusRecorder, wrapping it unless an outer
+// middleware already did.
+func recordStatus(w http.ResponseWriter) *statusRecorder {
+	if rec, ok := w.(*statusRecorder); ok {
+		return rec
+	}
+	return &statusRecorder{ResponseWriter: w}
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += n
+	return n, err
+}
+
+func (rec *statusRecorder) Flush() {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	http.NewResponseController(rec.ResponseWriter).Flush()
+}
+
+func (rec *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(rec.ResponseWriter).Hijack()
+	if err == nil && rec.status == 0 {
+		rec.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+func (rec *statusRecorder) Unwrap() http.ResponseWriter { return rec.ResponseWriter }
+
+// probePaths are the health checks, whose successes are not logged as
+// orchestrators call them every few seconds.
+var probePaths = map[string]bool{"/livez": true, "/healthz": true, "/readyz": true}
+
+// logRequests logs each request once it is answered: the client, method,
+// path, status, size, time taken and request ID.
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := recordStatus(w)
+		next.ServeHTTP(rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		if probePaths[r.URL.Path] && rec.status < 300 {
+			return
+		}
+		log.Printf("%s %s %s %d %dB %s id=%s", clientIP(r), r.Method, r.URL.Path,
+			rec.status, rec.bytes, time.Since(start).Round(time.Microsecond), requestIDOf(r))
+	})
+}
+
+// commandPanic is a panic of a command on a game's goroutine, carried to
+// the goroutine that ran the command with the stack it happened on.
+type commandPanic struct {
+	value any
+	stack []byte
+}
+
+func (p commandPanic) String() string { return fmt.Sprint(p.value) }
+
+// recoverPanics answers a request whose handler panicked with 500 Internal
+// Server Error, if nothing was sent yet, and logs the panic with its stack,
+// so a bug in one handler does not bring down every game.
+func recoverPanics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := recordStatus(w)
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+			if err, ok := p.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+				panic(p)
+			}
+			stack := debug.Stack()
+			if cp, ok := p.(commandPanic); ok {
+				stack = cp.stack
+			}
+			log.Printf("panic serving %s %s, request %s: %v\n%s", r.Method, r.URL.Path, requestIDOf(r), p, stack)
+			if rec.status != 0 {
+				// Too late to answer otherwise
+				return
+			}
+			errorsFor(r)(rec, http.StatusInternalServerError, codeInternal, "Internal server error")
+		}()
+		next.ServeHTTP(rec, r)
+	})
+}
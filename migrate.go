@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/rigurd/chess"
+)
+
+// gameSchema is the version of the layout stores write games in. Bump it
+// with a migration in gameMigrations whenever what a gameRow or storedMove
+// holds changes meaning, so games stored by older servers still load.
+const gameSchema = 1
+
+// gameMigrations upgrade a stored game from the schema version of their
+// index to the next one, in place.
+var gameMigrations = []func(row *gameRow, moves []storedMove) error{
+	// Games stored before the schema was versioned may have been written
+	// with their variant, tags or settings left empty
+	0: func(row *gameRow, moves []storedMove) error {
+		if row.Variant == "" {
+			row.Variant = string(chess.Standard)
+		}
+		if row.Tags == "" {
+			row.Tags = "{}"
+		}
+		if row.Settings == "" {
+			row.Settings = "{}"
+		}
+		return nil
+	},
+}
+
+// migrateGame upgrades the stored game with the given ID to gameSchema. A
+// game stored by a newer server, with a schema this one does not know, is
+// refused rather than misread.
+func migrateGame(id string, row *gameRow, moves []storedMove) error {
+	if row.Schema > gameSchema {
+		return fmt.Errorf("game %s: stored with schema %d, newer than %d", id, row.Schema, gameSchema)
+	}
+	for row.Schema < gameSchema {
+		if err := gameMigrations[row.Schema](row, moves); err != nil {
+			return fmt.Errorf("game %s: migrating from schema %d: %w", id, row.Schema, err)
+		}
+		row.Schema++
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	_ "embed"
+	"net/http"
+
+	"github.com/a-h/templ"
+)
+
+// openAPISpec describes the JSON API. It is written by hand and is the
+// reference for the API: change it along with the handlers it describes.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// handleOpenAPI serves the OpenAPI specification of the JSON API, from
+// which clients can be generated.
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPISpec)
+}
+
+// handleAPIDocs shows the documentation of the JSON API in Swagger UI.
+func (s *Server) handleAPIDocs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	templ.Handler(apiDocsPage()).ServeHTTP(w, r)
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/rigurd/chess"
+)
+
+// opening is a named main line given in coordinate notation ("e2e4").
+type opening struct {
+	Name  string
+	Moves []string
+}
+
+// openingBook is the small set of lines the opening trainer knows.
+var openingBook = []opening{
+	{Name: "Ruy Lopez", Moves: []string{"e2e4", "e7e5", "g1f3", "b8c6", "f1b5", "a7a6", "b5a4", "g8f6", "e1g1", "f8e7"}},
+	{Name: "Italian Game", Moves: []string{"e2e4", "e7e5", "g1f3", "b8c6", "f1c4", "f8c5", "c2c3", "g8f6"}},
+	{Name: "Sicilian Najdorf", Moves: []string{"e2e4", "c7c5", "g1f3", "d7d6", "d2d4", "c5d4", "f3d4", "g8f6", "b1c3", "a7a6"}},
+	{Name: "French Defence", Moves: []string{"e2e4", "e7e6", "d2d4", "d7d5", "b1c3", "g8f6"}},
+	{Name: "Caro-Kann Defence", Moves: []string{"e2e4", "c7c6", "d2d4", "d7d5", "b1c3", "d5e4", "c3e4"}},
+	{Name: "Queen's Gambit Declined", Moves: []string{"d2d4", "d7d5", "c2c4", "e7e6", "b1c3", "g8f6", "c1g5", "f8e7"}},
+	{Name: "King's Indian Defence", Moves: []string{"d2d4", "g8f6", "c2c4", "g7g6", "b1c3", "f8g7", "e2e4", "d7d6"}},
+	{Name: "English Opening", Moves: []string{"c2c4", "e7e5", "b1c3", "g8f6", "g2g3", "d7d5"}},
+}
+
+var (
+	openingOnce      sync.Once
+	openingPositions map[string]map[string]string
+)
+
+// openingIndex returns, for each opening, the book move played from every
+// position of its line keyed by chess.PositionKey, so a move order reaching
+// the line by transposition is still on book. The position at the end of a
+// line has no move. It is built the first time it is needed.
+func openingIndex() map[string]map[string]string {
+	openingOnce.Do(func() {
+		openingPositions = map[string]map[string]string{}
+		for _, o := range openingBook {
+			book := map[string]string{}
+			g := chess.NewGameState()
+			for i, uci := range o.Moves {
+				book[chess.PositionKey(g)] = uci
+				m, err := chess.ParseUCI(g, uci)
+				if err != nil {
+					panic(fmt.Sprintf("opening %s, ply %d: %v", o.Name, i, err))
+				}
+				chess.Play(g, m)
+			}
+			book[chess.PositionKey(g)] = ""
+			openingPositions[o.Name] = book
+		}
+	})
+	return openingPositions
+}
+
+// findOpening looks up a book line by name, ignoring case.
+func findOpening(name string) (opening, bool) {
+	for _, o := range openingBook {
+		if strings.EqualFold(o.Name, name) {
+			return o, true
+		}
+	}
+	return opening{}, false
+}
+
+// openingCheckRequest is the JSON body accepted by POST /api/opening/check.
+type openingCheckRequest struct {
+	Opening string   `json:"opening"`
+	Moves   []string `json:"moves"`
+}
+
+// openingCheckResult reports how a move sequence compares to a book line.
+// Ply is the number of moves up to the last position on book, and Expected
+// the book move from there: the move that should have been played when the
+// sequence left the book, or the next one while it is on book. Expected is
+// empty once the line runs out.
+type openingCheckResult struct {
+	Opening  string `json:"opening"`
+	OnBook   bool   `json:"onBook"`
+	Ply      int    `json:"ply"`
+	Expected string `json:"expected,omitempty"`
+}
+
+// checkOpening replays moves, in SAN or UCI, from the start and looks up
+// each position reached in the book line of o, so a sequence that
+// transposes into the line is on book once it reaches it. A sequence that
+// ends off book diverged after its last position on book; one that reached
+// the end of the line stays on book whatever follows. It returns an error
+// for an illegal move.
+func checkOpening(o opening, moves []string) (openingCheckResult, error) {
+	book := openingIndex()[o.Name]
+	res := openingCheckResult{Opening: o.Name}
+	g := chess.NewGameState()
+	res.Expected = book[chess.PositionKey(g)]
+	for i, s := range moves {
+		m, err := chess.ParseMove(g, s)
+		if err != nil {
+			return openingCheckResult{}, fmt.Errorf("move %d, %s: %w", i+1, s, err)
+		}
+		chess.Play(g, m)
+		if res.Expected == "" && res.Ply > 0 {
+			// Played past the end of the book line
+			continue
+		}
+		if next, ok := book[chess.PositionKey(g)]; ok {
+			res.Ply, res.Expected = i+1, next
+		}
+	}
+	res.OnBook = res.Ply == len(moves) || res.Expected == ""
+	return res, nil
+}
+
+// handleOpeningCheck checks a move sequence against a named opening.
+func (s *Server) handleOpeningCheck(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeAPIError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req openingCheckRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeAPIError(w, http.StatusBadRequest, codeBadRequest, "invalid JSON: "+err.Error())
+		return
+	}
+	o, ok := findOpening(req.Opening)
+	if !ok {
+		writeAPIError(w, http.StatusNotFound, codeNotFound, "unknown opening "+req.Opening)
+		return
+	}
+	res, err := checkOpening(o, req.Moves)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, res)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestOpeningIndex(t *testing.T) {
+	// Building the index replays every line, so an illegal move panics
+	for _, o := range openingBook {
+		if n := len(openingIndex()[o.Name]); n != len(o.Moves)+1 {
+			t.Errorf("%s: %d positions for %d moves", o.Name, n, len(o.Moves))
+		}
+	}
+}
+
+func TestCheckOpening(t *testing.T) {
+	ruy, _ := findOpening("ruy lopez")
+	qgd, _ := findOpening("queen's gambit declined")
+	tests := []struct {
+		name  string
+		o     opening
+		moves []string
+		want  openingCheckResult
+	}{
+		{"no moves yet", ruy, nil, openingCheckResult{Opening: "Ruy Lopez", OnBook: true, Ply: 0, Expected: "e2e4"}},
+		{"on book", ruy, []string{"e2e4", "e7e5", "g1f3"}, openingCheckResult{Opening: "Ruy Lopez", OnBook: true, Ply: 3, Expected: "b8c6"}},
+		{"on book in SAN", ruy, []string{"e4", "e5"}, openingCheckResult{Opening: "Ruy Lopez", OnBook: true, Ply: 2, Expected: "g1f3"}},
+		{"the whole line", ruy, ruy.Moves, openingCheckResult{Opening: "Ruy Lopez", OnBook: true, Ply: len(ruy.Moves)}},
+		{"past the line", ruy, append(ruy.Moves[:len(ruy.Moves):len(ruy.Moves)], "f1e1"), openingCheckResult{Opening: "Ruy Lopez", OnBook: true, Ply: len(ruy.Moves)}},
+		{"off book at once", ruy, []string{"d2d4"}, openingCheckResult{Opening: "Ruy Lopez", OnBook: false, Ply: 0, Expected: "e2e4"}},
+		{"off book later", ruy, []string{"e2e4", "e7e5", "g1f3", "b8c6", "f1c4"}, openingCheckResult{Opening: "Ruy Lopez", OnBook: false, Ply: 4, Expected: "f1b5"}},
+		{"transposed move order", qgd, []string{"c4", "e6", "d4", "d5"}, openingCheckResult{Opening: "Queen's Gambit Declined", OnBook: true, Ply: 4, Expected: "b1c3"}},
+		{"transposing through positions off book", qgd, []string{"d4", "Nf6", "c4", "e6", "Nc3", "d5"}, openingCheckResult{Opening: "Queen's Gambit Declined", OnBook: true, Ply: 6, Expected: "c1g5"}},
+	}
+	for _, tt := range tests {
+		got, err := checkOpening(tt.o, tt.moves)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+
+	for _, moves := range [][]string{{"e2e5"}, {"e4", "e4"}, {"castle"}} {
+		if _, err := checkOpening(ruy, moves); err == nil {
+			t.Errorf("%v: the illegal move was accepted", moves)
+		}
+	}
+}
+
+func TestHandleOpeningCheck(t *testing.T) {
+	s, ts := newTestServer(t)
+	c := newTestClient(t, s, ts)
+
+	if status, _ := c.get("/api/opening/check"); status != http.StatusMethodNotAllowed {
+		t.Errorf("GET: status %d, want %d", status, http.StatusMethodNotAllowed)
+	}
+	if status, _ := c.postJSON("/api/opening/check", openingCheckRequest{Opening: "Bongcloud"}); status != http.StatusNotFound {
+		t.Errorf("unknown opening: status %d, want %d", status, http.StatusNotFound)
+	}
+	if status, _ := c.postJSON("/api/opening/check", openingCheckRequest{Opening: "French Defence", Moves: []string{"e2e5"}}); status != http.StatusBadRequest {
+		t.Errorf("illegal move: status %d, want %d", status, http.StatusBadRequest)
+	}
+	status, body := c.postJSON("/api/opening/check", openingCheckRequest{Opening: "French Defence", Moves: []string{"e2e4", "e7e5"}})
+	if status != http.StatusOK {
+		t.Fatalf("status %d: %s", status, body)
+	}
+	var got openingCheckResult
+	if err := json.Unmarshal([]byte(body), &got); err != nil {
+		t.Fatal(err)
+	}
+	if want := (openingCheckResult{Opening: "French Defence", OnBook: false, Ply: 1, Expected: "e7e6"}); got != want {
+		t.Errorf("%+v, want %+v", got, want)
+	}
+}
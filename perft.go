@@ -0,0 +1,30 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/rigurd/chess"
+)
+
+// maxPerftDepth bounds /api/perft so a request cannot tie up the server.
+const maxPerftDepth = 4
+
+// handlePerft returns perft node counts for the current position.
+func (s *Server) handlePerft(w http.ResponseWriter, r *http.Request, g *Game) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	depth, err := strconv.Atoi(r.FormValue("depth"))
+	if err != nil || depth < 1 || depth > maxPerftDepth {
+		writeAPIError(w, http.StatusBadRequest, codeBadRequest, "depth must be between 1 and "+strconv.Itoa(maxPerftDepth))
+		return
+	}
+
+	var pos *chess.GameState
+	g.do(func() { pos = chess.CopyPosition(&g.GameState) })
+
+	writeJSON(w, map[string]int{"depth": depth, "nodes": chess.Perft(pos, depth)})
+}
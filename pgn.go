@@ -0,0 +1,73 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/rigurd/chess"
+)
+
+// maxPGNSize bounds an imported PGN, which is far more than any single game
+// needs.
+const maxPGNSize = 1 << 20
+
+// handlePGN downloads the game so far in Portable Game Notation.
+func (s *Server) handlePGN(w http.ResponseWriter, r *http.Request, g *Game) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var pgn string
+	g.do(func() { pgn = g.PGN(g.Tags) })
+
+	w.Header().Set("Content-Type", "application/x-chess-pgn")
+	w.Header().Set("Content-Disposition", `attachment; filename="g.pgn"`)
+	w.Write([]byte(pgn))
+}
+
+// handleImportPGN starts a new game read from PGN, either pasted into the
+// pgn field or uploaded as the file field, and shows its board. Every move
+// is checked by the rules engine on the way in.
+func (s *Server) handleImportPGN(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxPGNSize)
+	text := r.FormValue("pgn")
+	if file, _, err := r.FormFile("file"); err == nil {
+		defer file.Close()
+		data, err := io.ReadAll(file)
+		if err != nil {
+			http.Error(w, "could not read upload: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		text = string(data)
+	}
+	if strings.TrimSpace(text) == "" {
+		http.Error(w, "no PGN given", http.StatusBadRequest)
+		return
+	}
+
+	imported, tags, err := chess.ParsePGN(text)
+	if err != nil {
+		http.Error(w, "invalid PGN: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !s.requireRate(w, r, rateCreates) {
+		return
+	}
+
+	g := s.games.Create()
+	g.update(func() {
+		g.GameState = *imported
+		g.Tags = tags
+		s.audit(r, g, "import", "")
+	})
+	s.games.Save(g)
+
+	http.Redirect(w, r, gamePath(g, ""), http.StatusSeeOther)
+}
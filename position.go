@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/a-h/templ"
+	"github.com/rigurd/chess"
+)
+
+// positionRequest is the JSON body accepted by POST /api/position. Board rows
+// run from rank 8 down to rank 1 and hold FEN piece letters ("K", "p", ...),
+// with "" or "." for an empty square.
+type positionRequest struct {
+	Board    [][]string `json:"board"`
+	Side     string     `json:"side"`
+	Castling string     `json:"castling"`
+	EP       string     `json:"ep"`
+}
+
+// positionFromRequest builds a game from a JSON position description.
+func positionFromRequest(req positionRequest) (*chess.GameState, error) {
+	if len(req.Board) != 8 {
+		return nil, fmt.Errorf("board must have 8 ranks, got %d", len(req.Board))
+	}
+	g := chess.NewGameState()
+	for r, rank := range req.Board {
+		if len(rank) != 8 {
+			return nil, fmt.Errorf("rank %d must have 8 squares, got %d", 8-r, len(rank))
+		}
+		for c, code := range rank {
+			p, err := chess.PieceFromFEN(code)
+			if err != nil {
+				return nil, err
+			}
+			g.Board[r][c] = p
+		}
+	}
+
+	side, err := chess.ParseSide(req.Side)
+	if err != nil {
+		return nil, err
+	}
+	g.CurrentPlayer = side
+
+	castling, err := chess.ParseCastling(req.Castling)
+	if err != nil {
+		return nil, err
+	}
+	g.Castling = castling
+
+	if req.EP != "" && req.EP != "-" {
+		ep, err := chess.ParseSquareName(req.EP)
+		if err != nil {
+			return nil, err
+		}
+		g.EnPassant = &ep
+	}
+
+	if err := chess.ValidatePosition(g); err != nil {
+		return nil, err
+	}
+	g.StartFEN = g.FEN()
+	return g, nil
+}
+
+// handleSetPosition replaces the game with a position given as JSON.
+func (s *Server) handleSetPosition(w http.ResponseWriter, r *http.Request, g *Game) {
+	if r.Method != http.MethodPost {
+		writeAPIError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req positionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeAPIError(w, http.StatusBadRequest, codeBadRequest, "invalid JSON: "+err.Error())
+		return
+	}
+	pos, err := positionFromRequest(req)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+
+	g.update(func() {
+		if !requireAPIPlayer(w, r, g) {
+			return
+		}
+		g.setPosition(pos)
+		s.audit(r, g, "set-position", g.FEN())
+		writeJSON(w, map[string]string{"status": "ok"})
+	})
+}
+
+// setPosition starts a fresh game from pos, which must already be
+// validated. Repetition counting starts over from this position.
+func (g *Game) setPosition(pos *chess.GameState) {
+	g.ResetBoard()
+	g.GameState = *pos
+	g.Positions = map[string]int{chess.PositionKey(&g.GameState): 1}
+	chess.UpdateStatus(&g.GameState)
+}
+
+// handleNew starts a new game from the position given as fen=..., or from
+// the standard start when it is absent, and shows its board. With
+// opponent=computer the visitor plays the computer, on the side color=
+// names, at the difficulty level= names. It only answers POST, so another
+// site cannot start games, and seat the computer, in a visitor's name.
+func (s *Server) handleNew(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	fen := r.FormValue("fen")
+	if fen == "" {
+		fen = chess.StartFEN
+	}
+	pos, err := chess.ParseFEN(fen)
+	if err != nil {
+		http.Error(w, "invalid FEN: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	computer, err := parseOpponent(r.FormValue("opponent"), r.FormValue("color"), r.FormValue("level"), pos.Variant)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !s.requireRate(w, r, rateCreates) {
+		return
+	}
+
+	g := s.games.Create()
+	g.update(func() {
+		g.setPosition(pos)
+		if computer != nil {
+			computer.seat(g, sessionOf(r))
+		}
+		s.audit(r, g, "create", g.FEN())
+	})
+	s.games.Save(g)
+	http.Redirect(w, r, gamePath(g, ""), http.StatusSeeOther)
+}
+
+// handleFEN returns the current position in Forsyth-Edwards Notation.
+func (s *Server) handleFEN(w http.ResponseWriter, r *http.Request, g *Game) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var fen string
+	g.do(func() { fen = g.FEN() })
+
+	writeJSON(w, map[string]string{"fen": fen})
+}
+
+// handleEPD returns the current position in Extended Position Description on
+// GET, and on POST replaces the game with the position of an EPD record sent
+// as JSON {"epd": "..."}, so positions can be exchanged with other tools.
+func (s *Server) handleEPD(w http.ResponseWriter, r *http.Request, g *Game) {
+	switch r.Method {
+	case http.MethodGet:
+		var epd string
+		g.do(func() { epd = (&chess.EPD{Position: &g.GameState}).String() })
+		writeJSON(w, map[string]string{"epd": epd})
+	case http.MethodPost:
+		var req struct {
+			EPD string `json:"epd"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeAPIError(w, http.StatusBadRequest, codeBadRequest, "invalid JSON: "+err.Error())
+			return
+		}
+		e, err := chess.ParseEPD(req.EPD)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, codeBadRequest, "invalid EPD: "+err.Error())
+			return
+		}
+
+		g.update(func() {
+			if !requireAPIPlayer(w, r, g) {
+				return
+			}
+			g.setPosition(e.Position)
+			s.audit(r, g, "set-position", g.FEN())
+			writeJSON(w, map[string]string{"status": "ok"})
+		})
+	default:
+		writeAPIError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// handleView shows a read-only board of the position given as fen=..., so
+// a position can be shared without sharing the live game.
+func (s *Server) handleView(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	fen := r.FormValue("fen")
+	if fen == "" {
+		fen = chess.StartFEN
+	}
+	pos, err := chess.ParseFEN(fen)
+	if err != nil {
+		http.Error(w, "invalid FEN: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	templ.Handler(viewPage(&Game{GameState: *pos})).ServeHTTP(w, r)
+}
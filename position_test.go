@@ -0,0 +1,116 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/rigurd/chess"
+)
+
+// positionCases are positions given both as FEN and, through
+// requestFromFEN, as JSON, with the error each must be refused with, or ""
+// if it is legal.
+var positionCases = []struct {
+	name    string
+	fen     string
+	wantErr string
+}{
+	{"the start", chess.StartFEN, ""},
+	{"kings only", "4k3/8/8/8/8/8/8/4K3 w - - 0 1", ""},
+	{"two white kings", "4k3/8/8/8/8/8/8/3KK3 w - - 0 1", "white must have exactly one king"},
+	{"no black king", "8/8/8/8/8/8/8/4K3 w - - 0 1", "black must have exactly one king"},
+	{"pawn on the last rank", "P3k3/8/8/8/8/8/8/4K3 w - - 0 1", "first or last rank"},
+	{"nine pawns", "4k3/8/8/8/8/P7/PPPPPPPP/4K3 w - - 0 1", "white has 9 pawns"},
+	{"side not to move in check", "4k3/8/8/8/8/8/8/4R1K1 w - - 0 1", "black is in check but it is white to move"},
+	{"three checkers", "4r2k/8/8/8/1b6/3n4/8/4K3 w - - 0 1", "checked by 3 pieces"},
+	{"castling without the rook", "4k3/8/8/8/8/8/8/4K3 w K - 0 1", "castling rights K need"},
+	{"en passant without the pawn", "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq e6 0 1", "does not follow a two-step pawn advance"},
+}
+
+// requestFromFEN returns the JSON position request describing fen.
+func requestFromFEN(fen string) positionRequest {
+	fields := strings.Fields(fen)
+	var req positionRequest
+	for _, rank := range strings.Split(fields[0], "/") {
+		var squares []string
+		for _, ch := range rank {
+			if ch >= '1' && ch <= '8' {
+				for range ch - '0' {
+					squares = append(squares, ".")
+				}
+			} else {
+				squares = append(squares, string(ch))
+			}
+		}
+		req.Board = append(req.Board, squares)
+	}
+	req.Side, req.Castling, req.EP = fields[1], fields[2], fields[3]
+	return req
+}
+
+func TestPositionFromRequest(t *testing.T) {
+	for _, tt := range positionCases {
+		_, fenErr := chess.ParseFEN(tt.fen)
+		pos, err := positionFromRequest(requestFromFEN(tt.fen))
+		for format, err := range map[string]error{"FEN": fenErr, "JSON": err} {
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("%s as %s: %v", tt.name, format, err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("%s as %s: error %v, want one saying %q", tt.name, format, err, tt.wantErr)
+			}
+		}
+		if err == nil && pos.FEN() != tt.fen {
+			t.Errorf("%s: the JSON position is %s", tt.name, pos.FEN())
+		}
+	}
+
+	start := func(change func(*positionRequest)) positionRequest {
+		req := requestFromFEN(chess.StartFEN)
+		change(&req)
+		return req
+	}
+	bad := []struct {
+		name    string
+		req     positionRequest
+		wantErr string
+	}{
+		{"seven ranks", start(func(r *positionRequest) { r.Board = r.Board[:7] }), "board must have 8 ranks"},
+		{"short rank", start(func(r *positionRequest) { r.Board[3] = r.Board[3][:7] }), "rank 5 must have 8 squares"},
+		{"unknown side", start(func(r *positionRequest) { r.Side = "x" }), "side"},
+		{"unknown piece", start(func(r *positionRequest) { r.Board[4][4] = "X" }), "X"},
+	}
+	for _, tt := range bad {
+		if _, err := positionFromRequest(tt.req); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: error %v, want one saying %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestHandleSetPosition(t *testing.T) {
+	s, ts := newTestServer(t)
+	g := newTestGame(t, s, chess.StartFEN)
+	player, spectator := newTestClient(t, s, ts), newTestClient(t, s, ts)
+	g.update(func() { g.Players[chess.White] = player.session() })
+	path := gamePath(g, "/api/position")
+	kings := "4k3/8/8/8/8/8/8/4K3 w - - 0 1"
+
+	if status, _ := player.get(path); status != http.StatusMethodNotAllowed {
+		t.Errorf("GET: status %d, want %d", status, http.StatusMethodNotAllowed)
+	}
+	if status, _ := spectator.postJSON(path, requestFromFEN(kings)); status != http.StatusForbidden {
+		t.Errorf("spectator: status %d, want %d", status, http.StatusForbidden)
+	}
+	if status, body := player.postJSON(path, requestFromFEN("8/8/8/8/8/8/8/4K3 w - - 0 1")); status != http.StatusBadRequest {
+		t.Errorf("illegal position: status %d, want %d: %s", status, http.StatusBadRequest, body)
+	}
+	if status, body := player.postJSON(path, requestFromFEN(kings)); status != http.StatusOK {
+		t.Fatalf("status %d: %s", status, body)
+	}
+	var fen string
+	g.do(func() { fen = g.FEN() })
+	if fen != kings {
+		t.Errorf("FEN %s, want %s", fen, kings)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/a-h/templ"
+	"github.com/rigurd/chess"
+)
+
+// PendingPromotion is a pawn move to the last rank waiting for the player to
+// choose the piece it becomes.
+type PendingPromotion struct {
+	From chess.Square
+	To   chess.Square
+}
+
+// handlePromote completes a pending promotion with the chosen piece.
+func (s *Server) handlePromote(w http.ResponseWriter, r *http.Request, g *Game) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	g.update(func() {
+		if !requireSide(w, r, g, g.CurrentPlayer) {
+			return
+		}
+
+		pending := g.PendingPromotion
+		if pending == nil || g.Result != chess.Ongoing {
+			http.Error(w, "No promotion pending", http.StatusConflict)
+			return
+		}
+		promo := chess.PromotionPiece(r.FormValue("piece"), g.CurrentPlayer)
+		if promo == chess.Empty {
+			http.Error(w, "Invalid promotion piece", http.StatusBadRequest)
+			return
+		}
+
+		// Re-check the move in case the position changed since it was chosen
+		g.PendingPromotion = nil
+		if chess.IsValidMove(&g.GameState, pending.From, pending.To) {
+			plies := len(g.History)
+			g.LastError = g.play(chess.Move{From: pending.From, To: pending.To, Promotion: promo})
+			s.auditMoves(r, g, plies)
+		}
+		templ.Handler(chessboardWithLabels(g, threatSquares(g, showThreats(r)))).ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rateSweepInterval is how often buckets back to full are forgotten.
+const rateSweepInterval = time.Minute
+
+// rateLimit is how often a client may do something: N times in each
+// period Per, all at once if it waited long enough. The zero value is no
+// limit.
+type rateLimit struct {
+	N   int
+	Per time.Duration
+}
+
+// parseRateLimit reads a limit written as N/period, e.g. "30/10s" or
+// "10/m", or "0" for none.
+func parseRateLimit(s string) (rateLimit, error) {
+	if s == "0" || s == "" {
+		return rateLimit{}, nil
+	}
+	count, period, ok := strings.Cut(s, "/")
+	n, err := strconv.Atoi(count)
+	if !ok || err != nil || n < 1 {
+		return rateLimit{}, fmt.Errorf("rate limit %q is not N/period, e.g. 30/10s", s)
+	}
+	if period != "" && (period[0] < '0' || period[0] > '9') {
+		period = "1" + period
+	}
+	per, err := time.ParseDuration(period)
+	if err != nil || per <= 0 {
+		return rateLimit{}, fmt.Errorf("rate limit %q has no valid period, e.g. 10s or m", s)
+	}
+	return rateLimit{N: n, Per: per}, nil
+}
+
+func (l rateLimit) String() string {
+	if l.N == 0 {
+		return "0"
+	}
+	return strconv.Itoa(l.N) + "/" + l.Per.String()
+}
+
+// Set lets a rateLimit be given as a flag.
+func (l *rateLimit) Set(s string) error {
+	var err error
+	*l, err = parseRateLimit(s)
+	return err
+}
+
+// rateKind is a kind of request rate limited on its own.
+type rateKind string
+
+const (
+	rateMoves   rateKind = "moves"
+	rateCreates rateKind = "games created"
+)
+
+// tokenBucket holds the requests a client may still make at once, refilled
+// at the rate of its limit.
+type tokenBucket struct {
+	limit  rateLimit
+	tokens float64
+	last   time.Time
+}
+
+// refill adds the tokens earned since the bucket was last used.
+func (b *tokenBucket) refill(now time.Time) {
+	rate := float64(b.limit.N) / b.limit.Per.Seconds()
+	b.tokens = min(float64(b.limit.N), b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+}
+
+// wait returns how long until the bucket holds a token again.
+func (b *tokenBucket) wait() time.Duration {
+	rate := float64(b.limit.N) / b.limit.Per.Seconds()
+	return time.Duration(math.Ceil((1 - b.tokens) / rate * float64(time.Second)))
+}
+
+// rateLimiter keeps a token bucket for each client and kind of request.
+type rateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+	swept   time.Time
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{buckets: make(map[string]*tokenBucket), swept: time.Now()}
+}
+
+// rateKey is a bucket a request draws from, under its limit.
+type rateKey struct {
+	key   string
+	limit rateLimit
+}
+
+// take draws a token from each of keys' buckets, unless one is empty,
+// then returning how long until it is not.
+func (rl *rateLimiter) take(now time.Time, keys ...rateKey) (wait time.Duration, ok bool) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.sweep(now)
+
+	buckets := make([]*tokenBucket, 0, len(keys))
+	for _, k := range keys {
+		if k.limit.N == 0 {
+			continue
+		}
+		b := rl.buckets[k.key]
+		if b == nil || b.limit != k.limit {
+			b = &tokenBucket{limit: k.limit, tokens: float64(k.limit.N), last: now}
+			rl.buckets[k.key] = b
+		}
+		b.refill(now)
+		if b.tokens < 1 {
+			wait = max(wait, b.wait())
+		}
+		buckets = append(buckets, b)
+	}
+	if wait > 0 {
+		return wait, false
+	}
+	for _, b := range buckets {
+		b.tokens--
+	}
+	return 0, true
+}
+
+// sweep forgets the buckets full again, as new ones are, at most once
+// each rateSweepInterval.
+func (rl *rateLimiter) sweep(now time.Time) {
+	if now.Sub(rl.swept) < rateSweepInterval {
+		return
+	}
+	rl.swept = now
+	for key, b := range rl.buckets {
+		b.refill(now)
+		if b.tokens >= float64(b.limit.N) {
+			delete(rl.buckets, key)
+		}
+	}
+}
+
+// rateLimits returns the limits of kind for a session and for an IP
+// address, which the sessions behind it share.
+func (s *Server) rateLimits(kind rateKind) (session, ip rateLimit) {
+	switch kind {
+	case rateMoves:
+		return s.config.MoveLimit, s.config.MoveLimitIP
+	case rateCreates:
+		return s.config.CreateLimit, s.config.CreateLimitIP
+	}
+	return rateLimit{}, rateLimit{}
+}
+
+// checkRate counts a request of kind against the limits of its session and
+// IP address, returning how long to wait, in whole seconds, if it is over
+// either.
+func (s *Server) checkRate(r *http.Request, kind rateKind) (wait time.Duration, ok bool) {
+	session, ip := s.rateLimits(kind)
+	wait, ok = s.limits.take(time.Now(),
+		rateKey{"session " + string(kind) + " " + sessionOf(r), session},
+		rateKey{"ip " + string(kind) + " " + clientIP(r), ip})
+	return (wait + time.Second - 1).Truncate(time.Second), ok
+}
+
+// rateLimitedMessage tells a client over the limits of kind when to try
+// again.
+func rateLimitedMessage(kind rateKind, wait time.Duration) string {
+	return fmt.Sprintf("Too many %s; try again in %s", kind, wait)
+}
+
+// requireRate answers 429 Too Many Requests, with Retry-After, when the
+// request is over the limits of kind.
+func (s *Server) requireRate(w http.ResponseWriter, r *http.Request, kind rateKind) bool {
+	wait, ok := s.checkRate(r, kind)
+	if ok {
+		return true
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(int(wait/time.Second)))
+	errorsFor(r)(w, http.StatusTooManyRequests, codeRateLimited, rateLimitedMessage(kind, wait))
+	return false
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"expvar"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/rigurd/chess"
+)
+
+// reapInterval is how often the reaper looks for idle games.
+const reapInterval = time.Minute
+
+// reaperStats counts the games the reaper collected, served with the other
+// expvar metrics at /debug/vars: "aborted" and "adjudicated" games it
+// ended, and "evicted" games it dropped from memory, including those.
+var reaperStats = expvar.NewMap("reaper")
+
+// handleDebugVars serves the expvar metrics to admins. They include the
+// command line the server was started with, flags and secrets included, so
+// no one else may read them.
+func (s *Server) handleDebugVars(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if !s.requireAdmin(w, r) {
+		return
+	}
+	expvar.Handler().ServeHTTP(w, r)
+}
+
+// lastActive returns when g was last played: its last move, or when it was
+// started or loaded if that was later. It must be called from a command
+// of g's goroutine.
+func (g *Game) lastActive() time.Time {
+	last := g.started
+	if n := len(g.History); n > 0 && g.History[n-1].Time.After(last) {
+		last = g.History[n-1].Time
+	}
+	return last
+}
+
+// reapIdle collects the games idle for ttl every reapInterval, forever.
+func (m *GameManager) reapIdle(ttl time.Duration) {
+	ticker := time.NewTicker(reapInterval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		if n := m.reap(ttl, now); n > 0 {
+			log.Printf("reaped %d games idle for %v", n, ttl)
+		}
+	}
+}
+
+// reap ends the games in progress nobody has moved in for ttl as abandoned
+// by the player to move, saves them, and drops them and the finished games
+// idle as long from memory. It returns how many games were dropped.
+func (m *GameManager) reap(ttl time.Duration, now time.Time) int {
+	m.mu.RLock()
+	games := make([]*Game, 0, len(m.games))
+	for _, g := range m.games {
+		games = append(games, g)
+	}
+	m.mu.RUnlock()
+
+	reaped := 0
+	for _, g := range games {
+		var idle bool
+		g.update(func() {
+			idle = now.Sub(g.lastActive()) >= ttl
+			if idle && g.Result == chess.Ongoing {
+				chess.Abandon(&g.GameState)
+				g.clearSelection()
+				g.Takeback = nil
+				action := "adjudicate"
+				if g.Result == chess.Aborted {
+					action = "abort"
+					reaperStats.Add("aborted", 1)
+				} else {
+					reaperStats.Add("adjudicated", 1)
+				}
+				m.audit(AuditEvent{Time: now, Game: g.ID, Action: action, Detail: g.EndReason})
+			}
+		})
+		if !idle {
+			continue
+		}
+		m.Save(g)
+		m.forget(g.ID)
+		reaperStats.Add("evicted", 1)
+		reaped++
+	}
+	return reaped
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestHandleDebugVars(t *testing.T) {
+	s, ts := newTestServer(t)
+	c := newTestClient(t, s, ts)
+
+	if status, body := c.get("/debug/vars"); status != http.StatusForbidden {
+		t.Fatalf("without the admin token: status %d, want %d: %s", status, http.StatusForbidden, body)
+	}
+	status, body := c.send(http.MethodGet, "/debug/vars", nil, http.Header{"Authorization": {"Bearer " + testAdminToken}})
+	if status != http.StatusOK {
+		t.Fatalf("as admin: status %d: %s", status, body)
+	}
+	var vars map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(body), &vars); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := vars["reaper"]; !ok {
+		t.Error("the reaper counters are missing")
+	}
+}
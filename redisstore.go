@@ -0,0 +1,528 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// redisActive is the sorted set of the games in progress, scored by
+	// when each was last played.
+	redisActive = "rigurd:active"
+	// redisArchived is the list of the archived games' summaries, most
+	// recently archived first.
+	redisArchived = "rigurd:archived"
+	// redisDeleted is the sorted set of the deleted games, scored by when
+	// each was deleted.
+	redisDeleted = "rigurd:deleted"
+	// redisAudit is the list of the audit events, as JSON, most recent
+	// first.
+	redisAudit = "rigurd:audit"
+	// redisAPIKeys is the hash of the API keys issued, as JSON, by the
+	// hash of each key.
+	redisAPIKeys = "rigurd:apikeys"
+	// redisChannel carries "instance id" for each game saved, so other
+	// server instances drop their copy of the game.
+	redisChannel = "rigurd:saved"
+)
+
+// errStaleGame is returned by redisStore.SaveMove when another server
+// instance saved the game since this one loaded it.
+var errStaleGame = errors.New("game changed by another server")
+
+// redisStore is a GameStore in Redis, shared by several server instances
+// behind a load balancer. Each game is a hash of its gameRow fields and a
+// version, with its mainline a list of JSON storedMoves, and an archived
+// game one JSON redisArchive. Saves are published so the other instances
+// drop their copy of the game and load it afresh, and a save based on a
+// stale copy is refused.
+type redisStore struct {
+	rdb      *redis.Client
+	instance string // tells this server's saves from those of others
+}
+
+// openRedisStore connects to the Redis server at url, e.g.
+// "redis://localhost:6379/0".
+func openRedisStore(url string) (*redisStore, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+	rdb := redis.NewClient(opts)
+	if err := rdb.Ping(context.Background()).Err(); err != nil {
+		rdb.Close()
+		return nil, err
+	}
+	return &redisStore{rdb: rdb, instance: newGameID()}, nil
+}
+
+// redisGameKey, redisMovesKey and redisArchiveKey return the keys of a
+// game's hash, move list and archived copy.
+func redisGameKey(id string) string    { return "rigurd:game:" + id }
+func redisMovesKey(id string) string   { return "rigurd:moves:" + id }
+func redisArchiveKey(id string) string { return "rigurd:archive:" + id }
+
+// redisArchive is an archived game as stored.
+type redisArchive struct {
+	Row   gameRow      `json:"row"`
+	Moves []storedMove `json:"moves"`
+}
+
+// rowFields returns row as the fields of a game hash.
+func rowFields(row gameRow) map[string]any {
+	return map[string]any{
+		"schema":     row.Schema,
+		"variant":    row.Variant,
+		"start_fen":  row.StartFEN,
+		"result":     row.Result,
+		"end_reason": row.EndReason,
+		"draw_offer": row.DrawOffer,
+		"white":      row.White,
+		"black":      row.Black,
+		"tags":       row.Tags,
+		"settings":   row.Settings,
+		"updated":    time.Now().UnixNano(),
+	}
+}
+
+// encodeMoves returns the list entries of the moves of g from ply on.
+func encodeMoves(g *Game, from int) []any {
+	var entries []any
+	for _, m := range storedMoves(g)[from:] {
+		data, _ := json.Marshal(m)
+		entries = append(entries, string(data))
+	}
+	return entries
+}
+
+func (st *redisStore) Create(g *Game) error {
+	ctx := context.Background()
+	key := redisGameKey(g.ID)
+	row := rowOf(g)
+	moves := mainline(g)
+	err := st.rdb.Watch(ctx, func(tx *redis.Tx) error {
+		n, err := tx.Exists(ctx, key).Result()
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			return errGameExists
+		}
+		_, err = tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+			fields := rowFields(row)
+			fields["version"] = 1
+			p.HSet(ctx, key, fields)
+			p.Del(ctx, redisMovesKey(g.ID))
+			if entries := encodeMoves(g, 0); len(entries) > 0 {
+				p.RPush(ctx, redisMovesKey(g.ID), entries...)
+			}
+			p.ZAdd(ctx, redisActive, redis.Z{Score: float64(time.Now().Unix()), Member: g.ID})
+			return nil
+		})
+		return err
+	}, key)
+	if errors.Is(err, redis.TxFailedErr) {
+		// Another instance created a game under the ID meanwhile
+		return errGameExists
+	}
+	if err != nil {
+		return err
+	}
+	g.stored = storedGame{row: row, moves: moves, version: 1}
+	return nil
+}
+
+func (st *redisStore) Load(id string) (*Game, error) {
+	ctx := context.Background()
+	fields, err := st.rdb.HGetAll(ctx, redisGameKey(id)).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return st.loadArchived(id)
+	}
+	entries, err := st.rdb.LRange(ctx, redisMovesKey(id), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	moves := make([]storedMove, len(entries))
+	for i, entry := range entries {
+		if err := json.Unmarshal([]byte(entry), &moves[i]); err != nil {
+			return nil, err
+		}
+	}
+	schema, _ := strconv.Atoi(fields["schema"])
+	row := gameRow{
+		Schema:    schema,
+		Variant:   fields["variant"],
+		StartFEN:  fields["start_fen"],
+		Result:    fields["result"],
+		EndReason: fields["end_reason"],
+		DrawOffer: fields["draw_offer"],
+		White:     fields["white"],
+		Black:     fields["black"],
+		Tags:      fields["tags"],
+		Settings:  fields["settings"],
+	}
+	g, err := restoreGame(id, row, moves)
+	if err != nil {
+		return nil, err
+	}
+	g.stored.version, _ = strconv.ParseInt(fields["version"], 10, 64)
+	return g, nil
+}
+
+// loadArchived reads the game's archived copy back.
+func (st *redisStore) loadArchived(id string) (*Game, error) {
+	data, err := st.rdb.Get(context.Background(), redisArchiveKey(id)).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, errGameNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var archived redisArchive
+	if err := json.Unmarshal([]byte(data), &archived); err != nil {
+		return nil, err
+	}
+	g, err := restoreGame(id, archived.Row, archived.Moves)
+	if err != nil {
+		return nil, err
+	}
+	// Nothing of the game is left in its hash
+	g.stored = storedGame{}
+	g.archived = true
+	return g, nil
+}
+
+// SaveMove trims the moves taken back since g was last saved and appends
+// those played since, failing with errStaleGame if another instance saved
+// the game in between.
+func (st *redisStore) SaveMove(g *Game) error {
+	row := rowOf(g)
+	moves := mainline(g)
+	keep := 0
+	for keep < len(moves) && keep < len(g.stored.moves) && moves[keep] == g.stored.moves[keep] {
+		keep++
+	}
+	if row == g.stored.row && keep == len(moves) && keep == len(g.stored.moves) {
+		return nil
+	}
+
+	ctx := context.Background()
+	key := redisGameKey(g.ID)
+	err := st.rdb.Watch(ctx, func(tx *redis.Tx) error {
+		saved, err := tx.HMGet(ctx, key, "version").Result()
+		if err != nil {
+			return err
+		}
+		if saved[0] != nil && saved[0] != strconv.FormatInt(g.stored.version, 10) {
+			return errStaleGame
+		}
+		_, err = tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+			fields := rowFields(row)
+			fields["version"] = g.stored.version + 1
+			p.HSet(ctx, key, fields)
+			switch {
+			case keep == 0:
+				p.Del(ctx, redisMovesKey(g.ID))
+			case keep < len(g.stored.moves):
+				p.LTrim(ctx, redisMovesKey(g.ID), 0, int64(keep)-1)
+			}
+			if entries := encodeMoves(g, keep); len(entries) > 0 {
+				p.RPush(ctx, redisMovesKey(g.ID), entries...)
+			}
+			p.ZAdd(ctx, redisActive, redis.Z{Score: float64(time.Now().Unix()), Member: g.ID})
+			p.Publish(ctx, redisChannel, st.instance+" "+g.ID)
+			return nil
+		})
+		return err
+	}, key)
+	if errors.Is(err, redis.TxFailedErr) {
+		// Another instance saved the game meanwhile
+		return errStaleGame
+	}
+	if err != nil {
+		return err
+	}
+	g.stored = storedGame{row: row, moves: moves, version: g.stored.version + 1}
+	return nil
+}
+
+func (st *redisStore) List() ([]GameSummary, error) {
+	ctx := context.Background()
+	ids, err := st.rdb.ZRevRange(ctx, redisActive, 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	deleted, err := st.deletedIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var list []GameSummary
+	for _, id := range ids {
+		if deleted[id] {
+			continue
+		}
+		saved, err := st.rdb.HMGet(ctx, redisGameKey(id), "tags", "result", "end_reason", "updated").Result()
+		if err != nil {
+			return nil, err
+		}
+		plies, err := st.rdb.LLen(ctx, redisMovesKey(id)).Result()
+		if err != nil {
+			return nil, err
+		}
+		s := GameSummary{ID: id, Plies: int(plies)}
+		if tags, ok := saved[0].(string); ok {
+			if err := json.Unmarshal([]byte(tags), &s.Tags); err != nil {
+				return nil, err
+			}
+		}
+		s.Result, _ = saved[1].(string)
+		s.EndReason, _ = saved[2].(string)
+		if updated, ok := saved[3].(string); ok {
+			nanos, _ := strconv.ParseInt(updated, 10, 64)
+			s.Updated = time.Unix(0, nanos)
+		}
+		list = append(list, s)
+	}
+	return list, nil
+}
+
+// Archive replaces the game's hash and move list with its archived copy,
+// and lists it in the archive.
+func (st *redisStore) Archive(g *Game) error {
+	ctx := context.Background()
+	data, _ := json.Marshal(redisArchive{Row: rowOf(g), Moves: storedMoves(g)})
+	summary := summaryOf(g)
+	summary.Updated = time.Now()
+	entry, _ := json.Marshal(summary)
+	_, err := st.rdb.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.Set(ctx, redisArchiveKey(g.ID), data, 0)
+		p.LPush(ctx, redisArchived, entry)
+		p.Del(ctx, redisGameKey(g.ID), redisMovesKey(g.ID))
+		p.ZRem(ctx, redisActive, g.ID)
+		p.Publish(ctx, redisChannel, st.instance+" "+g.ID)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	g.stored = storedGame{}
+	return nil
+}
+
+// Archived scans the archive list, as Redis cannot query by field.
+func (st *redisStore) Archived(f archiveFilter, p archivePage) ([]GameSummary, error) {
+	ctx := context.Background()
+	entries, err := st.rdb.LRange(ctx, redisArchived, 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	deleted, err := st.deletedIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var list []GameSummary
+	for _, entry := range entries {
+		var s GameSummary
+		if err := json.Unmarshal([]byte(entry), &s); err != nil {
+			return nil, err
+		}
+		if f.matches(s) && !deleted[s.ID] {
+			list = append(list, s)
+		}
+	}
+	return p.apply(list), nil
+}
+
+// deletedIDs returns the IDs of the deleted games.
+func (st *redisStore) deletedIDs(ctx context.Context) (map[string]bool, error) {
+	ids, err := st.rdb.ZRange(ctx, redisDeleted, 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	deleted := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		deleted[id] = true
+	}
+	return deleted, nil
+}
+
+// exists fails with errGameNotFound unless the game is stored, in progress
+// or archived.
+func (st *redisStore) exists(ctx context.Context, id string) error {
+	n, err := st.rdb.Exists(ctx, redisGameKey(id), redisArchiveKey(id)).Result()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errGameNotFound
+	}
+	return nil
+}
+
+func (st *redisStore) Delete(id string, at time.Time) error {
+	ctx := context.Background()
+	if err := st.exists(ctx, id); err != nil {
+		return err
+	}
+	return st.rdb.ZAdd(ctx, redisDeleted, redis.Z{Score: float64(at.Unix()), Member: id}).Err()
+}
+
+func (st *redisStore) Restore(id string) error {
+	ctx := context.Background()
+	if err := st.exists(ctx, id); err != nil {
+		return err
+	}
+	return st.rdb.ZRem(ctx, redisDeleted, id).Err()
+}
+
+// Purge removes the games' keys and their entries in the archive list.
+func (st *redisStore) Purge(before time.Time) ([]string, error) {
+	ctx := context.Background()
+	ids, err := st.rdb.ZRangeByScore(ctx, redisDeleted, &redis.ZRangeBy{
+		Min: "-inf",
+		Max: "(" + strconv.FormatInt(before.Unix(), 10),
+	}).Result()
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+	purged := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		purged[id] = true
+	}
+	entries, err := st.rdb.LRange(ctx, redisArchived, 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	_, err = st.rdb.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		for _, entry := range entries {
+			var s GameSummary
+			if json.Unmarshal([]byte(entry), &s) == nil && purged[s.ID] {
+				p.LRem(ctx, redisArchived, 0, entry)
+			}
+		}
+		for _, id := range ids {
+			p.Del(ctx, redisGameKey(id), redisMovesKey(id), redisArchiveKey(id))
+			p.ZRem(ctx, redisActive, id)
+			p.ZRem(ctx, redisDeleted, id)
+			p.Publish(ctx, redisChannel, st.instance+" "+id)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+func (st *redisStore) Audit(e AuditEvent) error {
+	data, _ := json.Marshal(e)
+	return st.rdb.LPush(context.Background(), redisAudit, data).Err()
+}
+
+// AuditLog scans the audit list, as Archived does the archive.
+func (st *redisStore) AuditLog(f auditFilter, limit int) ([]AuditEvent, error) {
+	entries, err := st.rdb.LRange(context.Background(), redisAudit, 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	var list []AuditEvent
+	for _, entry := range entries {
+		if len(list) == limit {
+			break
+		}
+		var e AuditEvent
+		if err := json.Unmarshal([]byte(entry), &e); err != nil {
+			return nil, err
+		}
+		if f.matches(e) {
+			list = append(list, e)
+		}
+	}
+	return list, nil
+}
+
+func (st *redisStore) CreateAPIKey(k APIKey, hash string) error {
+	data, _ := json.Marshal(k)
+	return st.rdb.HSet(context.Background(), redisAPIKeys, hash, data).Err()
+}
+
+func (st *redisStore) APIKey(hash string) (APIKey, error) {
+	data, err := st.rdb.HGet(context.Background(), redisAPIKeys, hash).Result()
+	if errors.Is(err, redis.Nil) {
+		return APIKey{}, errAPIKeyNotFound
+	}
+	if err != nil {
+		return APIKey{}, err
+	}
+	var k APIKey
+	err = json.Unmarshal([]byte(data), &k)
+	return k, err
+}
+
+// apiKeys returns the API keys issued, by the hash of each key.
+func (st *redisStore) apiKeys(ctx context.Context) (map[string]APIKey, error) {
+	entries, err := st.rdb.HGetAll(ctx, redisAPIKeys).Result()
+	if err != nil {
+		return nil, err
+	}
+	keys := make(map[string]APIKey, len(entries))
+	for hash, data := range entries {
+		var k APIKey
+		if err := json.Unmarshal([]byte(data), &k); err != nil {
+			return nil, err
+		}
+		keys[hash] = k
+	}
+	return keys, nil
+}
+
+func (st *redisStore) APIKeys() ([]APIKey, error) {
+	keys, err := st.apiKeys(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	list := slices.Collect(maps.Values(keys))
+	slices.SortFunc(list, compareAPIKeys)
+	return list, nil
+}
+
+// RevokeAPIKey scans the keys for the one with the ID, there being few.
+func (st *redisStore) RevokeAPIKey(id string) error {
+	ctx := context.Background()
+	keys, err := st.apiKeys(ctx)
+	if err != nil {
+		return err
+	}
+	for hash, k := range keys {
+		if k.ID == id {
+			return st.rdb.HDel(ctx, redisAPIKeys, hash).Err()
+		}
+	}
+	return errAPIKeyNotFound
+}
+
+func (st *redisStore) Close() error { return st.rdb.Close() }
+
+// Subscribe calls forget with the ID of each game another server instance
+// saves, for as long as the connection to Redis lasts.
+func (st *redisStore) Subscribe(forget func(id string)) {
+	sub := st.rdb.Subscribe(context.Background(), redisChannel)
+	defer sub.Close()
+	for msg := range sub.Channel() {
+		instance, id, ok := strings.Cut(msg.Payload, " ")
+		if ok && instance != st.instance {
+			forget(id)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/a-h/templ"
+)
+
+// handleReplay shows the game as it stood after ply=N moves of the
+// mainline, replayed from the start, without touching the live game. A
+// replay opened from a link rather than the page's controls comes with the
+// rest of the game page.
+func (s *Server) handleReplay(w http.ResponseWriter, r *http.Request, g *Game) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ply, err := strconv.Atoi(r.FormValue("ply"))
+	if err != nil {
+		http.Error(w, "ply must be a number", http.StatusBadRequest)
+		return
+	}
+
+	g.do(func() {
+		pos, err := g.PositionAt(ply)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// The view keeps the whole history so the move list stays complete
+		view := &Game{ID: g.ID, GameState: *pos}
+		view.History = g.History
+		if r.Header.Get("HX-Request") == "" {
+			templ.Handler(page(g, seatText(g, sessionOf(r)), replayView(view, ply))).ServeHTTP(w, r)
+			return
+		}
+		templ.Handler(replayView(view, ply)).ServeHTTP(w, r)
+	})
+}
+
+// handleBoard shows the live board, as when returning from a replay.
+func (s *Server) handleBoard(w http.ResponseWriter, r *http.Request, g *Game) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	g.do(func() {
+		templ.Handler(chessboardWithLabels(g, threatSquares(g, showThreats(r)))).ServeHTTP(w, r)
+	})
+}
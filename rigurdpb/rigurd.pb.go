@@ -0,0 +1,665 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.5
+// 	protoc        v5.29.3
+// source: rigurdpb/rigurd.proto
+
+// The chess service lets other backend services create and play games on
+// this server, and follow them as they are played. Every call is made with
+// an API key of the HTTP API, as "authorization: Bearer <key>" metadata.
+
+package rigurdpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type CreateGameRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Fen           string                 `protobuf:"bytes,1,opt,name=fen,proto3" json:"fen,omitempty"`         // starting position; the standard one when empty
+	Variant       string                 `protobuf:"bytes,2,opt,name=variant,proto3" json:"variant,omitempty"` // "standard" or "crazyhouse"; not with fen
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateGameRequest) Reset() {
+	*x = CreateGameRequest{}
+	mi := &file_rigurdpb_rigurd_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateGameRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateGameRequest) ProtoMessage() {}
+
+func (x *CreateGameRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_rigurdpb_rigurd_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateGameRequest.ProtoReflect.Descriptor instead.
+func (*CreateGameRequest) Descriptor() ([]byte, []int) {
+	return file_rigurdpb_rigurd_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *CreateGameRequest) GetFen() string {
+	if x != nil {
+		return x.Fen
+	}
+	return ""
+}
+
+func (x *CreateGameRequest) GetVariant() string {
+	if x != nil {
+		return x.Variant
+	}
+	return ""
+}
+
+type GetGameRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetGameRequest) Reset() {
+	*x = GetGameRequest{}
+	mi := &file_rigurdpb_rigurd_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetGameRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetGameRequest) ProtoMessage() {}
+
+func (x *GetGameRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_rigurdpb_rigurd_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetGameRequest.ProtoReflect.Descriptor instead.
+func (*GetGameRequest) Descriptor() ([]byte, []int) {
+	return file_rigurdpb_rigurd_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *GetGameRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type MakeMoveRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	GameId        string                 `protobuf:"bytes,1,opt,name=game_id,json=gameId,proto3" json:"game_id,omitempty"`
+	Move          string                 `protobuf:"bytes,2,opt,name=move,proto3" json:"move,omitempty"`     // in SAN or UCI, e.g. "Nf3" or "g1f3"
+	Player        string                 `protobuf:"bytes,3,opt,name=player,proto3" json:"player,omitempty"` // ignored; the caller plays as its API key
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MakeMoveRequest) Reset() {
+	*x = MakeMoveRequest{}
+	mi := &file_rigurdpb_rigurd_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MakeMoveRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MakeMoveRequest) ProtoMessage() {}
+
+func (x *MakeMoveRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_rigurdpb_rigurd_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MakeMoveRequest.ProtoReflect.Descriptor instead.
+func (*MakeMoveRequest) Descriptor() ([]byte, []int) {
+	return file_rigurdpb_rigurd_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *MakeMoveRequest) GetGameId() string {
+	if x != nil {
+		return x.GameId
+	}
+	return ""
+}
+
+func (x *MakeMoveRequest) GetMove() string {
+	if x != nil {
+		return x.Move
+	}
+	return ""
+}
+
+func (x *MakeMoveRequest) GetPlayer() string {
+	if x != nil {
+		return x.Player
+	}
+	return ""
+}
+
+type LegalMovesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	GameId        string                 `protobuf:"bytes,1,opt,name=game_id,json=gameId,proto3" json:"game_id,omitempty"`
+	Square        string                 `protobuf:"bytes,2,opt,name=square,proto3" json:"square,omitempty"` // only the moves of the piece on this square, if set
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LegalMovesRequest) Reset() {
+	*x = LegalMovesRequest{}
+	mi := &file_rigurdpb_rigurd_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LegalMovesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LegalMovesRequest) ProtoMessage() {}
+
+func (x *LegalMovesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_rigurdpb_rigurd_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LegalMovesRequest.ProtoReflect.Descriptor instead.
+func (*LegalMovesRequest) Descriptor() ([]byte, []int) {
+	return file_rigurdpb_rigurd_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *LegalMovesRequest) GetGameId() string {
+	if x != nil {
+		return x.GameId
+	}
+	return ""
+}
+
+func (x *LegalMovesRequest) GetSquare() string {
+	if x != nil {
+		return x.Square
+	}
+	return ""
+}
+
+type LegalMovesResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Moves         []*Move                `protobuf:"bytes,1,rep,name=moves,proto3" json:"moves,omitempty"` // ply, color and time are unset
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LegalMovesResponse) Reset() {
+	*x = LegalMovesResponse{}
+	mi := &file_rigurdpb_rigurd_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LegalMovesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LegalMovesResponse) ProtoMessage() {}
+
+func (x *LegalMovesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_rigurdpb_rigurd_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LegalMovesResponse.ProtoReflect.Descriptor instead.
+func (*LegalMovesResponse) Descriptor() ([]byte, []int) {
+	return file_rigurdpb_rigurd_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *LegalMovesResponse) GetMoves() []*Move {
+	if x != nil {
+		return x.Moves
+	}
+	return nil
+}
+
+type StreamGameRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamGameRequest) Reset() {
+	*x = StreamGameRequest{}
+	mi := &file_rigurdpb_rigurd_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamGameRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamGameRequest) ProtoMessage() {}
+
+func (x *StreamGameRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_rigurdpb_rigurd_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamGameRequest.ProtoReflect.Descriptor instead.
+func (*StreamGameRequest) Descriptor() ([]byte, []int) {
+	return file_rigurdpb_rigurd_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *StreamGameRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+// Game is the state of a game.
+type Game struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Fen           string                 `protobuf:"bytes,2,opt,name=fen,proto3" json:"fen,omitempty"`
+	Variant       string                 `protobuf:"bytes,3,opt,name=variant,proto3" json:"variant,omitempty"`
+	Turn          string                 `protobuf:"bytes,4,opt,name=turn,proto3" json:"turn,omitempty"` // "white" or "black"
+	InCheck       bool                   `protobuf:"varint,5,opt,name=in_check,json=inCheck,proto3" json:"in_check,omitempty"`
+	Result        string                 `protobuf:"bytes,6,opt,name=result,proto3" json:"result,omitempty"` // "ongoing", "white wins", "black wins", "draw" or "aborted"
+	EndReason     string                 `protobuf:"bytes,7,opt,name=end_reason,json=endReason,proto3" json:"end_reason,omitempty"`
+	DrawOffer     string                 `protobuf:"bytes,8,opt,name=draw_offer,json=drawOffer,proto3" json:"draw_offer,omitempty"` // side offering a draw, if any
+	Ply           int32                  `protobuf:"varint,9,opt,name=ply,proto3" json:"ply,omitempty"`                             // moves played so far
+	LastMove      *Move                  `protobuf:"bytes,10,opt,name=last_move,json=lastMove,proto3" json:"last_move,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Game) Reset() {
+	*x = Game{}
+	mi := &file_rigurdpb_rigurd_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Game) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Game) ProtoMessage() {}
+
+func (x *Game) ProtoReflect() protoreflect.Message {
+	mi := &file_rigurdpb_rigurd_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Game.ProtoReflect.Descriptor instead.
+func (*Game) Descriptor() ([]byte, []int) {
+	return file_rigurdpb_rigurd_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *Game) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Game) GetFen() string {
+	if x != nil {
+		return x.Fen
+	}
+	return ""
+}
+
+func (x *Game) GetVariant() string {
+	if x != nil {
+		return x.Variant
+	}
+	return ""
+}
+
+func (x *Game) GetTurn() string {
+	if x != nil {
+		return x.Turn
+	}
+	return ""
+}
+
+func (x *Game) GetInCheck() bool {
+	if x != nil {
+		return x.InCheck
+	}
+	return false
+}
+
+func (x *Game) GetResult() string {
+	if x != nil {
+		return x.Result
+	}
+	return ""
+}
+
+func (x *Game) GetEndReason() string {
+	if x != nil {
+		return x.EndReason
+	}
+	return ""
+}
+
+func (x *Game) GetDrawOffer() string {
+	if x != nil {
+		return x.DrawOffer
+	}
+	return ""
+}
+
+func (x *Game) GetPly() int32 {
+	if x != nil {
+		return x.Ply
+	}
+	return 0
+}
+
+func (x *Game) GetLastMove() *Move {
+	if x != nil {
+		return x.LastMove
+	}
+	return nil
+}
+
+// Move is a move of a game.
+type Move struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Ply           int32                  `protobuf:"varint,1,opt,name=ply,proto3" json:"ply,omitempty"`
+	Color         string                 `protobuf:"bytes,2,opt,name=color,proto3" json:"color,omitempty"`
+	San           string                 `protobuf:"bytes,3,opt,name=san,proto3" json:"san,omitempty"`
+	Uci           string                 `protobuf:"bytes,4,opt,name=uci,proto3" json:"uci,omitempty"`
+	Time          *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=time,proto3" json:"time,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Move) Reset() {
+	*x = Move{}
+	mi := &file_rigurdpb_rigurd_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Move) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Move) ProtoMessage() {}
+
+func (x *Move) ProtoReflect() protoreflect.Message {
+	mi := &file_rigurdpb_rigurd_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Move.ProtoReflect.Descriptor instead.
+func (*Move) Descriptor() ([]byte, []int) {
+	return file_rigurdpb_rigurd_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *Move) GetPly() int32 {
+	if x != nil {
+		return x.Ply
+	}
+	return 0
+}
+
+func (x *Move) GetColor() string {
+	if x != nil {
+		return x.Color
+	}
+	return ""
+}
+
+func (x *Move) GetSan() string {
+	if x != nil {
+		return x.San
+	}
+	return ""
+}
+
+func (x *Move) GetUci() string {
+	if x != nil {
+		return x.Uci
+	}
+	return ""
+}
+
+func (x *Move) GetTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Time
+	}
+	return nil
+}
+
+var File_rigurdpb_rigurd_proto protoreflect.FileDescriptor
+
+var file_rigurdpb_rigurd_proto_rawDesc = string([]byte{
+	0x0a, 0x15, 0x72, 0x69, 0x67, 0x75, 0x72, 0x64, 0x70, 0x62, 0x2f, 0x72, 0x69, 0x67, 0x75, 0x72,
+	0x64, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x09, 0x72, 0x69, 0x67, 0x75, 0x72, 0x64, 0x2e,
+	0x76, 0x31, 0x1a, 0x1f, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x62, 0x75, 0x66, 0x2f, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x2e, 0x70, 0x72,
+	0x6f, 0x74, 0x6f, 0x22, 0x3f, 0x0a, 0x11, 0x43, 0x72, 0x65, 0x61, 0x74, 0x65, 0x47, 0x61, 0x6d,
+	0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x10, 0x0a, 0x03, 0x66, 0x65, 0x6e, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x66, 0x65, 0x6e, 0x12, 0x18, 0x0a, 0x07, 0x76, 0x61,
+	0x72, 0x69, 0x61, 0x6e, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x76, 0x61, 0x72,
+	0x69, 0x61, 0x6e, 0x74, 0x22, 0x20, 0x0a, 0x0e, 0x47, 0x65, 0x74, 0x47, 0x61, 0x6d, 0x65, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x22, 0x56, 0x0a, 0x0f, 0x4d, 0x61, 0x6b, 0x65, 0x4d, 0x6f,
+	0x76, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x17, 0x0a, 0x07, 0x67, 0x61, 0x6d,
+	0x65, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x67, 0x61, 0x6d, 0x65,
+	0x49, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x6d, 0x6f, 0x76, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x04, 0x6d, 0x6f, 0x76, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x70, 0x6c, 0x61, 0x79, 0x65, 0x72,
+	0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x70, 0x6c, 0x61, 0x79, 0x65, 0x72, 0x22, 0x44,
+	0x0a, 0x11, 0x4c, 0x65, 0x67, 0x61, 0x6c, 0x4d, 0x6f, 0x76, 0x65, 0x73, 0x52, 0x65, 0x71, 0x75,
+	0x65, 0x73, 0x74, 0x12, 0x17, 0x0a, 0x07, 0x67, 0x61, 0x6d, 0x65, 0x5f, 0x69, 0x64, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x67, 0x61, 0x6d, 0x65, 0x49, 0x64, 0x12, 0x16, 0x0a, 0x06,
+	0x73, 0x71, 0x75, 0x61, 0x72, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x73, 0x71,
+	0x75, 0x61, 0x72, 0x65, 0x22, 0x3b, 0x0a, 0x12, 0x4c, 0x65, 0x67, 0x61, 0x6c, 0x4d, 0x6f, 0x76,
+	0x65, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x25, 0x0a, 0x05, 0x6d, 0x6f,
+	0x76, 0x65, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x0f, 0x2e, 0x72, 0x69, 0x67, 0x75,
+	0x72, 0x64, 0x2e, 0x76, 0x31, 0x2e, 0x4d, 0x6f, 0x76, 0x65, 0x52, 0x05, 0x6d, 0x6f, 0x76, 0x65,
+	0x73, 0x22, 0x23, 0x0a, 0x11, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x47, 0x61, 0x6d, 0x65, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x22, 0x87, 0x02, 0x0a, 0x04, 0x47, 0x61, 0x6d, 0x65, 0x12,
+	0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12,
+	0x10, 0x0a, 0x03, 0x66, 0x65, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x66, 0x65,
+	0x6e, 0x12, 0x18, 0x0a, 0x07, 0x76, 0x61, 0x72, 0x69, 0x61, 0x6e, 0x74, 0x18, 0x03, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x07, 0x76, 0x61, 0x72, 0x69, 0x61, 0x6e, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x74,
+	0x75, 0x72, 0x6e, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x74, 0x75, 0x72, 0x6e, 0x12,
+	0x19, 0x0a, 0x08, 0x69, 0x6e, 0x5f, 0x63, 0x68, 0x65, 0x63, 0x6b, 0x18, 0x05, 0x20, 0x01, 0x28,
+	0x08, 0x52, 0x07, 0x69, 0x6e, 0x43, 0x68, 0x65, 0x63, 0x6b, 0x12, 0x16, 0x0a, 0x06, 0x72, 0x65,
+	0x73, 0x75, 0x6c, 0x74, 0x18, 0x06, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x72, 0x65, 0x73, 0x75,
+	0x6c, 0x74, 0x12, 0x1d, 0x0a, 0x0a, 0x65, 0x6e, 0x64, 0x5f, 0x72, 0x65, 0x61, 0x73, 0x6f, 0x6e,
+	0x18, 0x07, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x65, 0x6e, 0x64, 0x52, 0x65, 0x61, 0x73, 0x6f,
+	0x6e, 0x12, 0x1d, 0x0a, 0x0a, 0x64, 0x72, 0x61, 0x77, 0x5f, 0x6f, 0x66, 0x66, 0x65, 0x72, 0x18,
+	0x08, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x64, 0x72, 0x61, 0x77, 0x4f, 0x66, 0x66, 0x65, 0x72,
+	0x12, 0x10, 0x0a, 0x03, 0x70, 0x6c, 0x79, 0x18, 0x09, 0x20, 0x01, 0x28, 0x05, 0x52, 0x03, 0x70,
+	0x6c, 0x79, 0x12, 0x2c, 0x0a, 0x09, 0x6c, 0x61, 0x73, 0x74, 0x5f, 0x6d, 0x6f, 0x76, 0x65, 0x18,
+	0x0a, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0f, 0x2e, 0x72, 0x69, 0x67, 0x75, 0x72, 0x64, 0x2e, 0x76,
+	0x31, 0x2e, 0x4d, 0x6f, 0x76, 0x65, 0x52, 0x08, 0x6c, 0x61, 0x73, 0x74, 0x4d, 0x6f, 0x76, 0x65,
+	0x22, 0x82, 0x01, 0x0a, 0x04, 0x4d, 0x6f, 0x76, 0x65, 0x12, 0x10, 0x0a, 0x03, 0x70, 0x6c, 0x79,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52, 0x03, 0x70, 0x6c, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x63,
+	0x6f, 0x6c, 0x6f, 0x72, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x63, 0x6f, 0x6c, 0x6f,
+	0x72, 0x12, 0x10, 0x0a, 0x03, 0x73, 0x61, 0x6e, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03,
+	0x73, 0x61, 0x6e, 0x12, 0x10, 0x0a, 0x03, 0x75, 0x63, 0x69, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x03, 0x75, 0x63, 0x69, 0x12, 0x2e, 0x0a, 0x04, 0x74, 0x69, 0x6d, 0x65, 0x18, 0x05, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52,
+	0x04, 0x74, 0x69, 0x6d, 0x65, 0x32, 0xbe, 0x02, 0x0a, 0x05, 0x43, 0x68, 0x65, 0x73, 0x73, 0x12,
+	0x3b, 0x0a, 0x0a, 0x43, 0x72, 0x65, 0x61, 0x74, 0x65, 0x47, 0x61, 0x6d, 0x65, 0x12, 0x1c, 0x2e,
+	0x72, 0x69, 0x67, 0x75, 0x72, 0x64, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x72, 0x65, 0x61, 0x74, 0x65,
+	0x47, 0x61, 0x6d, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x0f, 0x2e, 0x72, 0x69,
+	0x67, 0x75, 0x72, 0x64, 0x2e, 0x76, 0x31, 0x2e, 0x47, 0x61, 0x6d, 0x65, 0x12, 0x35, 0x0a, 0x07,
+	0x47, 0x65, 0x74, 0x47, 0x61, 0x6d, 0x65, 0x12, 0x19, 0x2e, 0x72, 0x69, 0x67, 0x75, 0x72, 0x64,
+	0x2e, 0x76, 0x31, 0x2e, 0x47, 0x65, 0x74, 0x47, 0x61, 0x6d, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65,
+	0x73, 0x74, 0x1a, 0x0f, 0x2e, 0x72, 0x69, 0x67, 0x75, 0x72, 0x64, 0x2e, 0x76, 0x31, 0x2e, 0x47,
+	0x61, 0x6d, 0x65, 0x12, 0x37, 0x0a, 0x08, 0x4d, 0x61, 0x6b, 0x65, 0x4d, 0x6f, 0x76, 0x65, 0x12,
+	0x1a, 0x2e, 0x72, 0x69, 0x67, 0x75, 0x72, 0x64, 0x2e, 0x76, 0x31, 0x2e, 0x4d, 0x61, 0x6b, 0x65,
+	0x4d, 0x6f, 0x76, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x0f, 0x2e, 0x72, 0x69,
+	0x67, 0x75, 0x72, 0x64, 0x2e, 0x76, 0x31, 0x2e, 0x47, 0x61, 0x6d, 0x65, 0x12, 0x49, 0x0a, 0x0a,
+	0x4c, 0x65, 0x67, 0x61, 0x6c, 0x4d, 0x6f, 0x76, 0x65, 0x73, 0x12, 0x1c, 0x2e, 0x72, 0x69, 0x67,
+	0x75, 0x72, 0x64, 0x2e, 0x76, 0x31, 0x2e, 0x4c, 0x65, 0x67, 0x61, 0x6c, 0x4d, 0x6f, 0x76, 0x65,
+	0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1d, 0x2e, 0x72, 0x69, 0x67, 0x75, 0x72,
+	0x64, 0x2e, 0x76, 0x31, 0x2e, 0x4c, 0x65, 0x67, 0x61, 0x6c, 0x4d, 0x6f, 0x76, 0x65, 0x73, 0x52,
+	0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x3d, 0x0a, 0x0a, 0x53, 0x74, 0x72, 0x65, 0x61,
+	0x6d, 0x47, 0x61, 0x6d, 0x65, 0x12, 0x1c, 0x2e, 0x72, 0x69, 0x67, 0x75, 0x72, 0x64, 0x2e, 0x76,
+	0x31, 0x2e, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x47, 0x61, 0x6d, 0x65, 0x52, 0x65, 0x71, 0x75,
+	0x65, 0x73, 0x74, 0x1a, 0x0f, 0x2e, 0x72, 0x69, 0x67, 0x75, 0x72, 0x64, 0x2e, 0x76, 0x31, 0x2e,
+	0x47, 0x61, 0x6d, 0x65, 0x30, 0x01, 0x42, 0x1c, 0x5a, 0x1a, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62,
+	0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x72, 0x69, 0x67, 0x75, 0x72, 0x64, 0x2f, 0x72, 0x69, 0x67, 0x75,
+	0x72, 0x64, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+})
+
+var (
+	file_rigurdpb_rigurd_proto_rawDescOnce sync.Once
+	file_rigurdpb_rigurd_proto_rawDescData []byte
+)
+
+func file_rigurdpb_rigurd_proto_rawDescGZIP() []byte {
+	file_rigurdpb_rigurd_proto_rawDescOnce.Do(func() {
+		file_rigurdpb_rigurd_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_rigurdpb_rigurd_proto_rawDesc), len(file_rigurdpb_rigurd_proto_rawDesc)))
+	})
+	return file_rigurdpb_rigurd_proto_rawDescData
+}
+
+var file_rigurdpb_rigurd_proto_msgTypes = make([]protoimpl.MessageInfo, 8)
+var file_rigurdpb_rigurd_proto_goTypes = []any{
+	(*CreateGameRequest)(nil),     // 0: rigurd.v1.CreateGameRequest
+	(*GetGameRequest)(nil),        // 1: rigurd.v1.GetGameRequest
+	(*MakeMoveRequest)(nil),       // 2: rigurd.v1.MakeMoveRequest
+	(*LegalMovesRequest)(nil),     // 3: rigurd.v1.LegalMovesRequest
+	(*LegalMovesResponse)(nil),    // 4: rigurd.v1.LegalMovesResponse
+	(*StreamGameRequest)(nil),     // 5: rigurd.v1.StreamGameRequest
+	(*Game)(nil),                  // 6: rigurd.v1.Game
+	(*Move)(nil),                  // 7: rigurd.v1.Move
+	(*timestamppb.Timestamp)(nil), // 8: google.protobuf.Timestamp
+}
+var file_rigurdpb_rigurd_proto_depIdxs = []int32{
+	7, // 0: rigurd.v1.LegalMovesResponse.moves:type_name -> rigurd.v1.Move
+	7, // 1: rigurd.v1.Game.last_move:type_name -> rigurd.v1.Move
+	8, // 2: rigurd.v1.Move.time:type_name -> google.protobuf.Timestamp
+	0, // 3: rigurd.v1.Chess.CreateGame:input_type -> rigurd.v1.CreateGameRequest
+	1, // 4: rigurd.v1.Chess.GetGame:input_type -> rigurd.v1.GetGameRequest
+	2, // 5: rigurd.v1.Chess.MakeMove:input_type -> rigurd.v1.MakeMoveRequest
+	3, // 6: rigurd.v1.Chess.LegalMoves:input_type -> rigurd.v1.LegalMovesRequest
+	5, // 7: rigurd.v1.Chess.StreamGame:input_type -> rigurd.v1.StreamGameRequest
+	6, // 8: rigurd.v1.Chess.CreateGame:output_type -> rigurd.v1.Game
+	6, // 9: rigurd.v1.Chess.GetGame:output_type -> rigurd.v1.Game
+	6, // 10: rigurd.v1.Chess.MakeMove:output_type -> rigurd.v1.Game
+	4, // 11: rigurd.v1.Chess.LegalMoves:output_type -> rigurd.v1.LegalMovesResponse
+	6, // 12: rigurd.v1.Chess.StreamGame:output_type -> rigurd.v1.Game
+	8, // [8:13] is the sub-list for method output_type
+	3, // [3:8] is the sub-list for method input_type
+	3, // [3:3] is the sub-list for extension type_name
+	3, // [3:3] is the sub-list for extension extendee
+	0, // [0:3] is the sub-list for field type_name
+}
+
+func init() { file_rigurdpb_rigurd_proto_init() }
+func file_rigurdpb_rigurd_proto_init() {
+	if File_rigurdpb_rigurd_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_rigurdpb_rigurd_proto_rawDesc), len(file_rigurdpb_rigurd_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   8,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_rigurdpb_rigurd_proto_goTypes,
+		DependencyIndexes: file_rigurdpb_rigurd_proto_depIdxs,
+		MessageInfos:      file_rigurdpb_rigurd_proto_msgTypes,
+	}.Build()
+	File_rigurdpb_rigurd_proto = out.File
+	file_rigurdpb_rigurd_proto_goTypes = nil
+	file_rigurdpb_rigurd_proto_depIdxs = nil
+}
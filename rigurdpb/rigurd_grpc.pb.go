@@ -0,0 +1,299 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v5.29.3
+// source: rigurdpb/rigurd.proto
+
+// The chess service lets other backend services create and play games on
+// this server, and follow them as they are played. Every call is made with
+// an API key of the HTTP API, as "authorization: Bearer <key>" metadata.
+
+package rigurdpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Chess_CreateGame_FullMethodName = "/rigurd.v1.Chess/CreateGame"
+	Chess_GetGame_FullMethodName    = "/rigurd.v1.Chess/GetGame"
+	Chess_MakeMove_FullMethodName   = "/rigurd.v1.Chess/MakeMove"
+	Chess_LegalMoves_FullMethodName = "/rigurd.v1.Chess/LegalMoves"
+	Chess_StreamGame_FullMethodName = "/rigurd.v1.Chess/StreamGame"
+)
+
+// ChessClient is the client API for Chess service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ChessClient interface {
+	// CreateGame starts a new game, from the position given as FEN, or from
+	// the start of the variant given.
+	CreateGame(ctx context.Context, in *CreateGameRequest, opts ...grpc.CallOption) (*Game, error)
+	// GetGame returns the state of a game.
+	GetGame(ctx context.Context, in *GetGameRequest, opts ...grpc.CallOption) (*Game, error)
+	// MakeMove plays a move for the player to move and returns the game
+	// after it. A side is taken by the first API key to move it, as on the
+	// web.
+	MakeMove(ctx context.Context, in *MakeMoveRequest, opts ...grpc.CallOption) (*Game, error)
+	// LegalMoves returns the legal moves of the player to move.
+	LegalMoves(ctx context.Context, in *LegalMovesRequest, opts ...grpc.CallOption) (*LegalMovesResponse, error)
+	// StreamGame sends the game, then the game again after every change to
+	// it, until it is over or the call is cancelled.
+	StreamGame(ctx context.Context, in *StreamGameRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Game], error)
+}
+
+type chessClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewChessClient(cc grpc.ClientConnInterface) ChessClient {
+	return &chessClient{cc}
+}
+
+func (c *chessClient) CreateGame(ctx context.Context, in *CreateGameRequest, opts ...grpc.CallOption) (*Game, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Game)
+	err := c.cc.Invoke(ctx, Chess_CreateGame_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chessClient) GetGame(ctx context.Context, in *GetGameRequest, opts ...grpc.CallOption) (*Game, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Game)
+	err := c.cc.Invoke(ctx, Chess_GetGame_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chessClient) MakeMove(ctx context.Context, in *MakeMoveRequest, opts ...grpc.CallOption) (*Game, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Game)
+	err := c.cc.Invoke(ctx, Chess_MakeMove_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chessClient) LegalMoves(ctx context.Context, in *LegalMovesRequest, opts ...grpc.CallOption) (*LegalMovesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(LegalMovesResponse)
+	err := c.cc.Invoke(ctx, Chess_LegalMoves_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chessClient) StreamGame(ctx context.Context, in *StreamGameRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Game], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Chess_ServiceDesc.Streams[0], Chess_StreamGame_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StreamGameRequest, Game]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Chess_StreamGameClient = grpc.ServerStreamingClient[Game]
+
+// ChessServer is the server API for Chess service.
+// All implementations must embed UnimplementedChessServer
+// for forward compatibility.
+type ChessServer interface {
+	// CreateGame starts a new game, from the position given as FEN, or from
+	// the start of the variant given.
+	CreateGame(context.Context, *CreateGameRequest) (*Game, error)
+	// GetGame returns the state of a game.
+	GetGame(context.Context, *GetGameRequest) (*Game, error)
+	// MakeMove plays a move for the player to move and returns the game
+	// after it. A side is taken by the first API key to move it, as on the
+	// web.
+	MakeMove(context.Context, *MakeMoveRequest) (*Game, error)
+	// LegalMoves returns the legal moves of the player to move.
+	LegalMoves(context.Context, *LegalMovesRequest) (*LegalMovesResponse, error)
+	// StreamGame sends the game, then the game again after every change to
+	// it, until it is over or the call is cancelled.
+	StreamGame(*StreamGameRequest, grpc.ServerStreamingServer[Game]) error
+	mustEmbedUnimplementedChessServer()
+}
+
+// UnimplementedChessServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedChessServer struct{}
+
+func (UnimplementedChessServer) CreateGame(context.Context, *CreateGameRequest) (*Game, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateGame not implemented")
+}
+func (UnimplementedChessServer) GetGame(context.Context, *GetGameRequest) (*Game, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetGame not implemented")
+}
+func (UnimplementedChessServer) MakeMove(context.Context, *MakeMoveRequest) (*Game, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method MakeMove not implemented")
+}
+func (UnimplementedChessServer) LegalMoves(context.Context, *LegalMovesRequest) (*LegalMovesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method LegalMoves not implemented")
+}
+func (UnimplementedChessServer) StreamGame(*StreamGameRequest, grpc.ServerStreamingServer[Game]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamGame not implemented")
+}
+func (UnimplementedChessServer) mustEmbedUnimplementedChessServer() {}
+func (UnimplementedChessServer) testEmbeddedByValue()               {}
+
+// UnsafeChessServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ChessServer will
+// result in compilation errors.
+type UnsafeChessServer interface {
+	mustEmbedUnimplementedChessServer()
+}
+
+func RegisterChessServer(s grpc.ServiceRegistrar, srv ChessServer) {
+	// If the following call pancis, it indicates UnimplementedChessServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Chess_ServiceDesc, srv)
+}
+
+func _Chess_CreateGame_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateGameRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChessServer).CreateGame(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Chess_CreateGame_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChessServer).CreateGame(ctx, req.(*CreateGameRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Chess_GetGame_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetGameRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChessServer).GetGame(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Chess_GetGame_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChessServer).GetGame(ctx, req.(*GetGameRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Chess_MakeMove_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MakeMoveRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChessServer).MakeMove(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Chess_MakeMove_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChessServer).MakeMove(ctx, req.(*MakeMoveRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Chess_LegalMoves_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LegalMovesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChessServer).LegalMoves(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Chess_LegalMoves_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChessServer).LegalMoves(ctx, req.(*LegalMovesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Chess_StreamGame_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamGameRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ChessServer).StreamGame(m, &grpc.GenericServerStream[StreamGameRequest, Game]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Chess_StreamGameServer = grpc.ServerStreamingServer[Game]
+
+// Chess_ServiceDesc is the grpc.ServiceDesc for Chess service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Chess_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "rigurd.v1.Chess",
+	HandlerType: (*ChessServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateGame",
+			Handler:    _Chess_CreateGame_Handler,
+		},
+		{
+			MethodName: "GetGame",
+			Handler:    _Chess_GetGame_Handler,
+		},
+		{
+			MethodName: "MakeMove",
+			Handler:    _Chess_MakeMove_Handler,
+		},
+		{
+			MethodName: "LegalMoves",
+			Handler:    _Chess_LegalMoves_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamGame",
+			Handler:       _Chess_StreamGame_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "rigurdpb/rigurd.proto",
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/graph-gophers/graphql-go"
+	"google.golang.org/grpc"
+)
+
+// Server serves the chess web interface and API. It holds everything the
+// handlers share, so a server can be created afresh for each test and
+// exercised with httptest.
+type Server struct {
+	config  Config
+	games   *GameManager
+	db      *gameDB // games bulk imported for searching
+	graphql *graphql.Schema
+	bots    *botHub
+	limits  *rateLimiter
+	mux     *http.ServeMux
+	handler http.Handler // mux within the middleware
+	csrfKey []byte
+
+	started  time.Time
+	draining atomic.Bool   // set once shutting down, when moves are refused
+	stop     chan struct{} // closed once shutting down, ending the streams
+}
+
+// NewServer returns a server ready to handle requests, with the games kept
+// in the store cfg selects loaded as they are asked for, and those the move
+// log holds moves of that never reached the store replayed.
+func NewServer(cfg Config) (*Server, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	store, err := openStore(cfg)
+	if err != nil {
+		return nil, err
+	}
+	games := NewGameManager(store)
+	games.webhooks = newWebhookSender(cfg.WebhooksPrivate)
+	if cfg.WALPath != "" {
+		wal, err := openMoveLog(cfg.WALPath)
+		if err != nil {
+			store.Close()
+			return nil, err
+		}
+		if err := games.useMoveLog(wal); err != nil {
+			wal.Close()
+			store.Close()
+			return nil, err
+		}
+	}
+	if games.engine, err = newEngine(cfg); err != nil {
+		if games.wal != nil {
+			games.wal.Close()
+		}
+		store.Close()
+		return nil, err
+	}
+	s := &Server{
+		config:  cfg,
+		games:   games,
+		db:      newGameDB(),
+		bots:    newBotHub(),
+		limits:  newRateLimiter(),
+		csrfKey: newCSRFKey(cfg.CSRFKey),
+		mux:     http.NewServeMux(),
+		started: time.Now(),
+		stop:    make(chan struct{}),
+	}
+	s.graphql = newGraphQLSchema(s)
+	s.routes()
+	mws := []middleware{withRequestID}
+	if cfg.AccessLog {
+		mws = append(mws, logRequests)
+	}
+	mws = append(mws, recoverPanics)
+	s.handler = chain(http.HandlerFunc(s.dispatch), mws...)
+	if cfg.IdleTTL > 0 {
+		go s.games.reapIdle(cfg.IdleTTL)
+	}
+	if cfg.DeletedRetention > 0 {
+		go s.games.purgeDeleted(cfg.DeletedRetention)
+	}
+	return s, nil
+}
+
+// routes registers every handler with the server's mux.
+func (s *Server) routes() {
+	s.mux.HandleFunc("/{$}", s.handleIndex)
+	s.mux.HandleFunc("/new", s.handleNew)
+	s.mux.HandleFunc("/view", s.handleView)
+	s.mux.HandleFunc("/pgn/import", s.handleImportPGN)
+	s.mux.HandleFunc("/image/", s.handleGameImage)
+	s.mux.HandleFunc("/admin/pgn/import", s.handleBulkImportPGN)
+	s.mux.HandleFunc("/admin/audit", s.handleAuditLog)
+	s.mux.HandleFunc("/admin/api-keys", s.handleAPIKeys)
+	s.mux.HandleFunc("/admin/api-keys/{id}", s.handleRevokeAPIKey)
+	s.mux.HandleFunc("/games", s.handleGames)
+	s.mux.HandleFunc("/api/games", s.handleListGames)
+	s.mux.HandleFunc("/api/games/search", s.handleSearchGames)
+	s.mux.HandleFunc("/api/games/pgn", s.handleDatabaseGamePGN)
+	s.mux.HandleFunc("/api/opening/check", s.handleOpeningCheck)
+	s.mux.HandleFunc("/graphql", s.handleGraphQL)
+	s.mux.HandleFunc("/openapi.json", s.handleOpenAPI)
+	s.mux.HandleFunc("/api/docs", s.handleAPIDocs)
+	s.mux.HandleFunc("/debug/vars", s.handleDebugVars)
+	s.mux.HandleFunc("/livez", s.handleLivez)
+	s.mux.HandleFunc("/healthz", s.handleHealthz)
+	s.mux.HandleFunc("/readyz", s.handleReadyz)
+
+	// Routes of a single game
+	s.mux.HandleFunc("/game/{id}", s.gameHandler(s.handleGetBoard))
+	s.mux.HandleFunc("/game/{id}/board", s.gameHandler(s.handleBoard))
+	s.mux.HandleFunc("/game/{id}/replay", s.gameHandler(s.handleReplay))
+	s.mux.HandleFunc("/game/{id}/move", s.gameHandler(s.handleMove))
+	s.mux.HandleFunc("/game/{id}/move-text", s.gameHandler(s.handleTextMove))
+	s.mux.HandleFunc("/game/{id}/moves", s.apiGameHandler(s.handleLegalDestinations))
+	s.mux.HandleFunc("/game/{id}/reset", s.gameHandler(s.handleReset))
+	s.mux.HandleFunc("/game/{id}/resign", s.gameHandler(s.handleResign))
+	s.mux.HandleFunc("/game/{id}/delete", s.apiGameHandler(s.handleDeleteGame))
+	s.mux.HandleFunc("/game/{id}/restore", s.apiGameHandler(s.handleRestoreGame))
+	s.mux.HandleFunc("/game/{id}/undo", s.gameHandler(s.handleUndo))
+	s.mux.HandleFunc("/game/{id}/redo", s.gameHandler(s.handleRedo))
+	s.mux.HandleFunc("/game/{id}/takeback", s.gameHandler(s.handleRequestTakeback))
+	s.mux.HandleFunc("/game/{id}/respond-takeback", s.gameHandler(s.handleRespondTakeback))
+	s.mux.HandleFunc("/game/{id}/offer-draw", s.gameHandler(s.handleOfferDraw))
+	s.mux.HandleFunc("/game/{id}/respond-draw", s.gameHandler(s.handleRespondDraw))
+	s.mux.HandleFunc("/game/{id}/drop", s.gameHandler(s.handleDrop))
+	s.mux.HandleFunc("/game/{id}/promote", s.gameHandler(s.handlePromote))
+	s.mux.HandleFunc("/game/{id}/threats", s.gameHandler(s.handleThreats))
+	s.mux.HandleFunc("/game/{id}/ws", s.gameHandler(s.handleWebSocket))
+	s.mux.HandleFunc("/game/{id}/board.png", s.gameHandler(s.handleBoardPNG))
+	s.mux.HandleFunc("/game/{id}/pgn", s.gameHandler(s.handlePGN))
+	s.mux.HandleFunc("/game/{id}/variation/promote", s.gameHandler(s.handlePromoteVariation))
+	s.mux.HandleFunc("/game/{id}/api/variation", s.apiGameHandler(s.handleAddVariation))
+	s.mux.HandleFunc("/game/{id}/api/enprise", s.apiGameHandler(s.handleEnPrise))
+	s.mux.HandleFunc("/game/{id}/api/defends", s.apiGameHandler(s.handleDefends))
+	s.mux.HandleFunc("/game/{id}/api/position", s.apiGameHandler(s.handleSetPosition))
+	s.mux.HandleFunc("/game/{id}/api/fen", s.apiGameHandler(s.handleFEN))
+	s.mux.HandleFunc("/game/{id}/api/epd", s.apiGameHandler(s.handleEPD))
+	s.mux.HandleFunc("/game/{id}/api/perft", s.apiGameHandler(s.handlePerft))
+
+	// JSON API, version 1
+	s.mux.HandleFunc("/api/v1/games", s.handleAPICreateGame)
+	s.mux.HandleFunc("/api/v1/games/{id}", s.apiGameHandler(s.handleAPIGame))
+	s.mux.HandleFunc("/api/v1/games/{id}/moves", s.apiGameHandler(s.handleAPIMoves))
+	s.mux.HandleFunc("/api/v1/games/{id}/legal-moves", s.apiGameHandler(s.handleAPILegalMoves))
+	s.mux.HandleFunc("/api/v1/games/{id}/validate-move", s.apiGameHandler(s.handleAPIValidateMove))
+	s.mux.HandleFunc("/api/v1/games/{id}/analysis", s.apiGameHandler(s.handleAPIAnalysis))
+	s.mux.HandleFunc("/api/v1/games/{id}/webhooks", s.apiGameHandler(s.handleAPIWebhooks))
+	s.mux.HandleFunc("/api/v1/games/{id}/webhooks/{hook}", s.apiGameHandler(s.handleAPIDeleteWebhook))
+	s.mux.HandleFunc("/api/v1/bots", s.handleBots)
+	s.mux.HandleFunc("/api/v1/challenge/{bot}", s.handleChallengeBot)
+	s.mux.HandleFunc("/api/v1/challenges/{id}", s.handleChallenge)
+
+	// Bot API, for engines playing through an API key
+	s.mux.HandleFunc("/api/v1/bot/stream/event", s.handleBotEvents)
+	s.mux.HandleFunc("/api/v1/bot/challenge/{id}/accept", s.handleAcceptChallenge)
+	s.mux.HandleFunc("/api/v1/bot/challenge/{id}/decline", s.handleDeclineChallenge)
+	s.mux.HandleFunc("/api/v1/bot/game/{id}/stream", s.apiGameHandler(s.handleBotGameStream))
+	s.mux.HandleFunc("/api/v1/bot/game/{id}/move/{move}", s.apiGameHandler(s.handleBotMove))
+	s.mux.HandleFunc("/api/v1/bot/game/{id}/resign", s.apiGameHandler(s.handleBotResign))
+}
+
+// ServeHTTP handles a request within the middleware: it is given an ID,
+// logged, and answered 500 Internal Server Error if its handler panics.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.handler.ServeHTTP(w, r)
+}
+
+// dispatch sends a request to its handler, within the visitor's session or
+// that of the API key it carries, answering CORS preflights for the APIs
+// and refusing cross-site requests made with the session cookie. Once the
+// server is shutting down, only pages are still served; anything that would
+// change a game is refused, so no move is made after the games are saved.
+func (s *Server) dispatch(w http.ResponseWriter, r *http.Request) {
+	// Assets are the same for everyone, and are cached without a cookie
+	if strings.HasPrefix(r.URL.Path, staticPrefix) {
+		s.handleStatic(w, r)
+		return
+	}
+	if s.cors(w, r) {
+		return
+	}
+	if s.draining.Load() && r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Retry-After", "10")
+		errorsFor(r)(w, http.StatusServiceUnavailable, codeUnavailable, "Server is shutting down")
+		return
+	}
+	if token, ok := bearerAPIKey(r); ok && !s.isAdmin(r) {
+		r, ok = s.withAPIKey(w, r, token)
+		if ok {
+			s.mux.ServeHTTP(w, r)
+		}
+		return
+	}
+	r = s.withCSRFToken(withSession(w, r, s.config.CORSCredentials))
+	if !s.checkCSRF(w, r) {
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// ListenAndServe serves requests on the configured address, and gRPC calls
+// on the gRPC address if there is one, until ctx is done, then shuts down
+// gracefully: it stops accepting moves, waits up to the ShutdownTimeout for
+// the requests in flight, and saves every game in memory to the store
+// before closing it.
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	srv, certs := s.httpServer()
+	redirect := s.redirectServer(certs)
+	errc := make(chan error, 3)
+	var rpc *grpc.Server
+	endStreams := func() {}
+	if s.config.GRPCAddr != "" {
+		lis, err := net.Listen("tcp", s.config.GRPCAddr)
+		if err != nil {
+			s.games.Close()
+			return err
+		}
+		rpc, endStreams = newGRPCServer(s)
+		log.Printf("Serving gRPC on %s", s.config.GRPCAddr)
+		go func() { errc <- rpc.Serve(lis) }()
+	}
+	if redirect != nil {
+		log.Printf("Redirecting HTTP on %s to HTTPS", redirect.Addr)
+		go func() { errc <- redirect.ListenAndServe() }()
+	}
+	go func() { errc <- s.listen(srv) }()
+	select {
+	case err := <-errc:
+		if rpc != nil {
+			rpc.Stop()
+		}
+		if redirect != nil {
+			redirect.Close()
+		}
+		srv.Close()
+		s.games.Close()
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Printf("Shutting down")
+	s.draining.Store(true)
+	close(s.stop)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.config.ShutdownTimeout)
+	defer cancel()
+	rpcStopped := make(chan struct{})
+	if rpc != nil {
+		endStreams()
+		go func() {
+			rpc.GracefulStop()
+			close(rpcStopped)
+		}()
+	}
+	if redirect != nil {
+		redirect.Shutdown(shutdownCtx)
+	}
+	err := srv.Shutdown(shutdownCtx)
+	if err != nil {
+		log.Printf("shutting down: %v", err)
+	}
+	// Only wait on gRPC when it runs: with both channels ready, select could
+	// otherwise pick the timeout and stop a nil server
+	if rpc != nil {
+		select {
+		case <-rpcStopped:
+		case <-shutdownCtx.Done():
+			log.Printf("shutting down gRPC: %v", shutdownCtx.Err())
+			rpc.Stop()
+		}
+	}
+	if cerr := s.games.Close(); cerr != nil {
+		log.Printf("closing game store: %v", cerr)
+	}
+	return err
+}
+
+// gameHandler adapts a handler for one game to a route with the game ID in
+// its {id} path segment, answering 404 for unknown games. Whatever the
+// handler changed in the game is saved once it returns.
+func (s *Server) gameHandler(h func(http.ResponseWriter, *http.Request, *Game)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		g, ok := s.games.Get(id)
+		if !ok {
+			http.Error(w, "unknown game "+id, http.StatusNotFound)
+			return
+		}
+		h(w, r, g)
+		s.games.Save(g)
+	}
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rigurd/chess"
+)
+
+// testAdminToken is the admin token of the servers under test.
+const testAdminToken = "test-admin-token"
+
+// testConfig returns the configuration of a server keeping its games in
+// memory, without rate limits.
+func testConfig() Config {
+	cfg := defaultConfig()
+	cfg.Store, cfg.StoreDSN, cfg.WALPath = "memory", "", ""
+	cfg.AccessLog = false
+	cfg.AdminToken = testAdminToken
+	cfg.MoveLimit, cfg.MoveLimitIP = rateLimit{}, rateLimit{}
+	cfg.CreateLimit, cfg.CreateLimitIP = rateLimit{}, rateLimit{}
+	return cfg
+}
+
+// newTestServer returns a server of testConfig and serves it until the
+// test ends.
+func newTestServer(t *testing.T) (*Server, *httptest.Server) {
+	t.Helper()
+	s, err := NewServer(testConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(s)
+	t.Cleanup(func() {
+		ts.Close()
+		s.games.Close()
+	})
+	return s, ts
+}
+
+// newTestGame starts a game at the position of fen on s.
+func newTestGame(t *testing.T, s *Server, fen string) *Game {
+	t.Helper()
+	pos, err := chess.ParseFEN(fen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := s.games.Create()
+	g.update(func() { g.setPosition(pos) })
+	s.games.Save(g)
+	return g
+}
+
+// testClient is a visitor of a server under test, keeping its session
+// cookie between requests, and sending its CSRF token as the pages do.
+type testClient struct {
+	t    *testing.T
+	s    *Server
+	base *url.URL
+	http *http.Client
+}
+
+// newTestClient returns a visitor of the server s served by ts, with a
+// session of its own.
+func newTestClient(t *testing.T, s *Server, ts *httptest.Server) *testClient {
+	t.Helper()
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base, _ := url.Parse(ts.URL)
+	c := &testClient{t: t, s: s, base: base, http: &http.Client{
+		Jar: jar,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}}
+	c.get("/livez")
+	return c
+}
+
+// session returns the client's session.
+func (c *testClient) session() string {
+	for _, cookie := range c.http.Jar.Cookies(c.base) {
+		if cookie.Name == sessionCookie {
+			return cookie.Value
+		}
+	}
+	return ""
+}
+
+// do sends a request for path, with form as its body unless it is nil,
+// and returns the status and body of the response.
+func (c *testClient) do(method, path string, form url.Values, header http.Header) (int, string) {
+	c.t.Helper()
+	if form == nil {
+		return c.send(method, path, nil, header)
+	}
+	header = header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return c.send(method, path, strings.NewReader(form.Encode()), header)
+}
+
+// send sends a request for path with the given body and returns the status
+// and body of the response.
+func (c *testClient) send(method, path string, body io.Reader, header http.Header) (int, string) {
+	c.t.Helper()
+	req, err := http.NewRequest(method, c.base.String()+path, body)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if session := c.session(); session != "" {
+		req.Header.Set(csrfHeader, c.s.csrfTokenFor(session))
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return resp.StatusCode, string(b)
+}
+
+func (c *testClient) get(path string) (int, string) {
+	c.t.Helper()
+	return c.do(http.MethodGet, path, nil, nil)
+}
+
+func (c *testClient) post(path string, form url.Values) (int, string) {
+	c.t.Helper()
+	return c.do(http.MethodPost, path, form, nil)
+}
+
+// postJSON posts v as JSON to path.
+func (c *testClient) postJSON(path string, v any) (int, string) {
+	c.t.Helper()
+	b, err := json.Marshal(v)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return c.send(http.MethodPost, path, bytes.NewReader(b), http.Header{"Content-Type": {"application/json"}})
+}
+
+func TestShutdownWithoutGRPC(t *testing.T) {
+	cfg := testConfig()
+	cfg.Addr = "127.0.0.1:0"
+	cfg.ShutdownTimeout = time.Nanosecond
+	// The shutdown has timed out before it starts, so each run is a chance
+	// for it to trip over the gRPC server there is none of
+	for range 20 {
+		s, err := NewServer(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if err := s.ListenAndServe(ctx); err != nil && !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestStateChangingRoutesRefuseGET(t *testing.T) {
+	s, ts := newTestServer(t)
+	g := newTestGame(t, s, chess.StartFEN)
+	c := newTestClient(t, s, ts)
+	if status, body := c.post(gamePath(g, "/move-text"), url.Values{"move": {"e4"}}); status != http.StatusOK {
+		t.Fatalf("e4: status %d: %s", status, body)
+	}
+	var before string
+	g.do(func() { before = g.FEN() })
+
+	paths := []string{"/new", "/pgn/import", "/admin/pgn/import", "/graphql", "/api/opening/check"}
+	for _, suffix := range []string{
+		"/move", "/move-text", "/reset", "/resign", "/delete", "/restore", "/undo", "/redo",
+		"/takeback", "/respond-takeback", "/offer-draw", "/respond-draw", "/drop", "/promote",
+		"/threats", "/variation/promote", "/api/variation", "/api/position",
+	} {
+		paths = append(paths, gamePath(g, suffix))
+	}
+	for _, path := range paths {
+		if status, body := c.get(path); status != http.StatusMethodNotAllowed {
+			t.Errorf("GET %s: status %d, want %d: %s", path, status, http.StatusMethodNotAllowed, body)
+		}
+	}
+
+	g.do(func() {
+		if fen := g.FEN(); fen != before {
+			t.Errorf("the GETs changed the game from %s to %s", before, fen)
+		}
+	})
+}
+
+func TestNewAndResetByPOST(t *testing.T) {
+	s, ts := newTestServer(t)
+	c := newTestClient(t, s, ts)
+	const fen = "4k3/8/8/8/8/8/4P3/4K3 w - - 0 1"
+
+	// As the page's form posts it, with the token in a field
+	resp, err := c.http.PostForm(ts.URL+"/new", url.Values{"fen": {fen}, csrfField: {s.csrfTokenFor(c.session())}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("POST /new: status %d, want %d", resp.StatusCode, http.StatusSeeOther)
+	}
+	id := strings.TrimPrefix(resp.Header.Get("Location"), "/game/")
+	g, ok := s.games.Get(id)
+	if !ok {
+		t.Fatalf("POST /new redirected to %q, not to a new game", resp.Header.Get("Location"))
+	}
+	g.do(func() {
+		if got := g.FEN(); got != fen {
+			t.Errorf("new game at %s, want %s", got, fen)
+		}
+	})
+
+	if status, body := c.post(gamePath(g, "/move-text"), url.Values{"move": {"e4"}}); status != http.StatusOK {
+		t.Fatalf("e4: status %d: %s", status, body)
+	}
+	if status, body := c.post(gamePath(g, "/reset"), nil); status != http.StatusOK {
+		t.Fatalf("POST reset: status %d: %s", status, body)
+	}
+	g.do(func() {
+		if got := g.FEN(); got != chess.StartFEN {
+			t.Errorf("reset game at %s, want the start", got)
+		}
+	})
+}
+
+func TestIndexCreatesGamesOnlyOnPOST(t *testing.T) {
+	s, ts := newTestServer(t)
+	c := newTestClient(t, s, ts)
+	created := func() int {
+		t.Helper()
+		events, err := s.games.store.AuditLog(auditFilter{Action: "create"}, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(events)
+	}
+
+	status, body := c.get("/")
+	if status != http.StatusOK || !strings.Contains(body, `<form id="new-game" action="/new" method="post">`) {
+		t.Errorf("GET /: status %d, want the landing page: %s", status, body)
+	}
+	if status, _ := c.do(http.MethodHead, "/", nil, nil); status != http.StatusOK {
+		t.Errorf("HEAD /: status %d, want %d", status, http.StatusOK)
+	}
+	if n := created(); n != 0 {
+		t.Fatalf("%d games created by GET and HEAD", n)
+	}
+
+	if status, _ := c.post("/", nil); status != http.StatusSeeOther {
+		t.Errorf("POST /: status %d, want %d", status, http.StatusSeeOther)
+	}
+	if n := created(); n != 1 {
+		t.Errorf("%d games created by POST, want 1", n)
+	}
+}
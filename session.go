@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/rigurd/chess"
+)
+
+const (
+	sessionCookie = "rigurd_session"
+	sessionMaxAge = 365 * 24 * 60 * 60 // seconds
+)
+
+// sessionKey is the context key of the request's session ID.
+type sessionKey struct{}
+
+// withSession attaches the visitor's session ID to the request, assigning a
+// new one in a cookie on their first visit. With crossSite set the cookie
+// is also sent by clients on other sites allowed to use it by CORS, which
+// browsers only allow over HTTPS.
+func withSession(w http.ResponseWriter, r *http.Request, crossSite bool) *http.Request {
+	id := ""
+	if c, err := r.Cookie(sessionCookie); err == nil && validSessionID(c.Value) {
+		id = c.Value
+	} else {
+		id = newSessionID()
+		cookie := &http.Cookie{
+			Name:     sessionCookie,
+			Value:    id,
+			Path:     "/",
+			MaxAge:   sessionMaxAge,
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		}
+		if crossSite {
+			cookie.SameSite = http.SameSiteNoneMode
+			cookie.Secure = true
+		}
+		if r.TLS != nil {
+			cookie.Secure = true
+		}
+		http.SetCookie(w, cookie)
+	}
+	return r.WithContext(context.WithValue(r.Context(), sessionKey{}, id))
+}
+
+// newSessionID returns a random, unguessable session ID.
+func newSessionID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// validSessionID reports whether id could have come from newSessionID, so
+// arbitrary cookie values are not trusted as identities.
+func validSessionID(id string) bool {
+	b, err := hex.DecodeString(id)
+	return err == nil && len(b) == 16
+}
+
+// sessionOf returns the session ID attached by withSession.
+func sessionOf(r *http.Request) string {
+	id, _ := r.Context().Value(sessionKey{}).(string)
+	return id
+}
+
+// sideOf returns the side the session plays in g, if any.
+func (g *Game) sideOf(session string) (chess.PieceColor, bool) {
+	for side, player := range g.Players {
+		if player == session {
+			return side, true
+		}
+	}
+	return "", false
+}
+
+// claimSide reports whether the session may act for side. A side nobody
+// plays yet is bound to the first session to act for it, unless that
+// session already plays the other side. It must be called from a command
+// of g's goroutine.
+func (g *Game) claimSide(session string, side chess.PieceColor) bool {
+	if player := g.Players[side]; player != "" {
+		return player == session
+	}
+	if _, seated := g.sideOf(session); seated {
+		return false
+	}
+	g.Players[side] = session
+	return true
+}
+
+// requireSide answers 403 Forbidden unless the request's session may act
+// for side in g, claiming the side when it is free. It must be called from
+// a command of g's goroutine.
+func requireSide(w http.ResponseWriter, r *http.Request, g *Game, side chess.PieceColor) bool {
+	if !g.claimSide(sessionOf(r), side) {
+		http.Error(w, "You are not playing "+string(side)+" in this game", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// requirePlayer answers 403 Forbidden unless the request's session plays
+// either side of g. It must be called from a command of g's goroutine.
+func requirePlayer(w http.ResponseWriter, r *http.Request, g *Game) bool {
+	if _, seated := g.sideOf(sessionOf(r)); !seated {
+		http.Error(w, "Only the players can do that", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// seatText describes the viewer's part in g for the page header.
+func seatText(g *Game, session string) string {
+	if side, ok := g.sideOf(session); ok {
+		if g.Players[chess.Opponent(side)] == computerPlayer {
+			return "You are playing " + string(side) + " against the computer"
+		}
+		return "You are playing " + string(side)
+	}
+	return "Make a move to take a free side, or watch"
+}
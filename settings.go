@@ -0,0 +1,43 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/rigurd/chess"
+)
+
+// GameSettings holds the per-game options chosen when a game is started.
+type GameSettings struct {
+	TouchMove bool // a touched piece that can move must be moved
+	AutoQueen bool // promote straight to a queen without asking
+
+	// Webhooks are told of the game's events. Unlike the options, they are
+	// kept across resets.
+	Webhooks []Webhook `json:",omitempty"`
+	// ComputerLevel is the difficulty the computer plays at, in a game
+	// against it. Like the webhooks, it is kept across resets.
+	ComputerLevel string `json:",omitempty"`
+}
+
+// keptOnReset returns the settings a reset leaves as they are.
+func (s GameSettings) keptOnReset() GameSettings {
+	return GameSettings{Webhooks: s.Webhooks, ComputerLevel: s.ComputerLevel}
+}
+
+// settingsFromRequest reads game options from reset parameters such as
+// touchmove=1 or autoqueen=1, over kept, the settings left by the reset.
+func settingsFromRequest(r *http.Request, kept GameSettings) GameSettings {
+	kept.TouchMove = r.FormValue("touchmove") == "1"
+	kept.AutoQueen = r.FormValue("autoqueen") == "1"
+	return kept
+}
+
+// errTouchMove rejects moving a different piece than the one touched.
+var errTouchMove = errors.New("Touch-move: the selected piece must be moved.")
+
+// touchMoveLocked reports whether the touch-move rule binds the player to
+// the selected piece: it is on, and the piece has at least one legal move.
+func touchMoveLocked(g *Game) bool {
+	return g.Settings.TouchMove && g.SelectedSquare != nil && len(chess.GenerateLegalMoves(&g.GameState, *g.SelectedSquare)) > 0
+}
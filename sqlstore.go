@@ -0,0 +1,600 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// sqlMigrations create and upgrade the tables games are kept in, with
+// {timestamp} standing for the timestamp type. Migration n brings the
+// database from version n to n+1, and the versions applied are recorded in
+// schema_migrations; add a migration to change the tables, never edit one.
+//
+// A game's mainline is stored a move per row, so playing a move only adds a
+// row. Finished games move to archived_games, with their moves in one
+// column and the fields they are searched by in others.
+var sqlMigrations = []string{
+	// The tables as they were before migrations were recorded, hence IF
+	// NOT EXISTS
+	`
+CREATE TABLE IF NOT EXISTS games (
+	id         TEXT PRIMARY KEY,
+	variant    TEXT NOT NULL,
+	start_fen  TEXT NOT NULL,
+	result     TEXT NOT NULL,
+	end_reason TEXT NOT NULL,
+	draw_offer TEXT NOT NULL,
+	white      TEXT NOT NULL, -- session playing white, or ''
+	black      TEXT NOT NULL,
+	tags       TEXT NOT NULL, -- JSON
+	settings   TEXT NOT NULL, -- JSON
+	updated_at {timestamp} NOT NULL
+);
+CREATE TABLE IF NOT EXISTS moves (
+	game_id   TEXT NOT NULL REFERENCES games(id) ON DELETE CASCADE,
+	ply       INTEGER NOT NULL,
+	uci       TEXT NOT NULL,
+	san       TEXT NOT NULL,
+	played_at {timestamp} NOT NULL,
+	PRIMARY KEY (game_id, ply)
+);
+CREATE TABLE IF NOT EXISTS archived_games (
+	game_id      TEXT NOT NULL,
+	variant      TEXT NOT NULL,
+	start_fen    TEXT NOT NULL,
+	result       TEXT NOT NULL,
+	end_reason   TEXT NOT NULL,
+	white        TEXT NOT NULL,
+	black        TEXT NOT NULL,
+	tags         TEXT NOT NULL,
+	settings     TEXT NOT NULL,
+	moves        TEXT NOT NULL, -- JSON
+	player_white TEXT NOT NULL, -- name from the tags, in lower case
+	player_black TEXT NOT NULL,
+	date         TEXT NOT NULL, -- PGN date, YYYY.MM.DD
+	eco          TEXT NOT NULL,
+	opening      TEXT NOT NULL,
+	plies        INTEGER NOT NULL,
+	archived_at  {timestamp} NOT NULL,
+	PRIMARY KEY (game_id, archived_at)
+);`,
+	// The gameSchema each game was written in, 0 for those written before
+	`
+ALTER TABLE games ADD COLUMN schema_version INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE archived_games ADD COLUMN schema_version INTEGER NOT NULL DEFAULT 0;`,
+	// Who did what to each game, and when
+	`
+CREATE TABLE audit_log (
+	at      {timestamp} NOT NULL,
+	game_id TEXT NOT NULL,
+	action  TEXT NOT NULL,
+	detail  TEXT NOT NULL,
+	session TEXT NOT NULL,
+	ip      TEXT NOT NULL
+);
+CREATE INDEX audit_log_game ON audit_log (game_id, at);
+CREATE INDEX audit_log_session ON audit_log (session, at);`,
+	// When each game was soft-deleted, or NULL
+	`
+ALTER TABLE games ADD COLUMN deleted_at {timestamp};
+ALTER TABLE archived_games ADD COLUMN deleted_at {timestamp};`,
+	// The API keys issued, by the hash of each key
+	`
+CREATE TABLE api_keys (
+	hash       TEXT PRIMARY KEY,
+	id         TEXT NOT NULL UNIQUE,
+	name       TEXT NOT NULL,
+	scope      TEXT NOT NULL,
+	created_at {timestamp} NOT NULL
+);`,
+}
+
+// sqlStore is a GameStore in a SQL database, SQLite or PostgreSQL, so a
+// restart of the server does not lose the games being played. Only what is
+// needed to carry on playing is kept: the position a game started from,
+// its mainline, result, players and settings. Annotations, variations and
+// the redo stack are not.
+type sqlStore struct {
+	db       *sql.DB
+	postgres bool // numbers query placeholders $1, $2, ... rather than ?
+}
+
+// openSQLiteStore opens the SQLite database at path, creating it and its
+// tables if needed.
+func openSQLiteStore(path string) (*sqlStore, error) {
+	db, err := sql.Open("sqlite3", "file:"+path+"?_foreign_keys=on&_busy_timeout=5000")
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows one writer at a time anyway
+	db.SetMaxOpenConns(1)
+	return newSQLStore(db, false, "TIMESTAMP")
+}
+
+// openPostgresStore connects to the PostgreSQL database at dsn, e.g.
+// "postgres://rigurd@localhost/rigurd", creating its tables if needed.
+func openPostgresStore(dsn string) (*sqlStore, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+	return newSQLStore(db, true, "TIMESTAMPTZ")
+}
+
+// newSQLStore creates or upgrades the tables of a store in db as needed.
+func newSQLStore(db *sql.DB, postgres bool, timestamp string) (*sqlStore, error) {
+	st := &sqlStore{db: db, postgres: postgres}
+	if err := st.migrate(timestamp); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrating tables: %w", err)
+	}
+	return st, nil
+}
+
+// migrate applies the sqlMigrations the database lacks, all in one
+// transaction so a failed upgrade leaves the tables as they were.
+func (st *sqlStore) migrate(timestamp string) error {
+	tx, err := st.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if st.postgres {
+		// Servers starting together take turns, the first one migrating
+		if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(7369746)`); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER NOT NULL)`); err != nil {
+		return err
+	}
+	var version int
+	if err := tx.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version); err != nil {
+		return err
+	}
+	if version > len(sqlMigrations) {
+		return fmt.Errorf("database at version %d, newer than this server's %d", version, len(sqlMigrations))
+	}
+	for ; version < len(sqlMigrations); version++ {
+		if _, err := tx.Exec(strings.ReplaceAll(sqlMigrations[version], "{timestamp}", timestamp)); err != nil {
+			return fmt.Errorf("migration %d: %w", version+1, err)
+		}
+		if _, err := tx.Exec(st.rebind(`INSERT INTO schema_migrations (version) VALUES (?)`), version+1); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// rebind rewrites the ? placeholders of query for the database.
+func (st *sqlStore) rebind(query string) string {
+	if !st.postgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, ch := range query {
+		if ch == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(ch)
+	}
+	return b.String()
+}
+
+func (st *sqlStore) Create(g *Game) error {
+	tx, err := st.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	row := rowOf(g)
+	res, err := tx.Exec(st.rebind(`INSERT INTO games
+		(id, schema_version, variant, start_fen, result, end_reason, draw_offer, white, black, tags, settings, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO NOTHING`),
+		g.ID, row.Schema, row.Variant, row.StartFEN, row.Result, row.EndReason, row.DrawOffer,
+		row.White, row.Black, row.Tags, row.Settings, time.Now())
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return errGameExists
+	}
+	moves := mainline(g)
+	if err := st.insertMoves(tx, g, moves, 0); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	g.stored = storedGame{row: row, moves: moves}
+	return nil
+}
+
+// SaveMove deletes the moves taken back since g was last saved and adds
+// those played since.
+func (st *sqlStore) SaveMove(g *Game) error {
+	row := rowOf(g)
+	moves := mainline(g)
+	keep := 0
+	for keep < len(moves) && keep < len(g.stored.moves) && moves[keep] == g.stored.moves[keep] {
+		keep++
+	}
+	if row == g.stored.row && keep == len(moves) && keep == len(g.stored.moves) {
+		return nil
+	}
+
+	tx, err := st.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	_, err = tx.Exec(st.rebind(`INSERT INTO games
+		(id, schema_version, variant, start_fen, result, end_reason, draw_offer, white, black, tags, settings, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			schema_version = excluded.schema_version, variant = excluded.variant, start_fen = excluded.start_fen,
+			result = excluded.result, end_reason = excluded.end_reason,
+			draw_offer = excluded.draw_offer, white = excluded.white, black = excluded.black,
+			tags = excluded.tags, settings = excluded.settings, updated_at = excluded.updated_at`),
+		g.ID, row.Schema, row.Variant, row.StartFEN, row.Result, row.EndReason, row.DrawOffer,
+		row.White, row.Black, row.Tags, row.Settings, time.Now())
+	if err != nil {
+		return err
+	}
+	if keep < len(g.stored.moves) {
+		if _, err := tx.Exec(st.rebind(`DELETE FROM moves WHERE game_id = ? AND ply >= ?`), g.ID, keep); err != nil {
+			return err
+		}
+	}
+	if err := st.insertMoves(tx, g, moves, keep); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	g.stored = storedGame{row: row, moves: moves}
+	return nil
+}
+
+// insertMoves adds the moves of g from ply on.
+func (st *sqlStore) insertMoves(tx *sql.Tx, g *Game, moves []string, from int) error {
+	for ply := from; ply < len(moves); ply++ {
+		rec := g.History[ply]
+		_, err := tx.Exec(st.rebind(`INSERT INTO moves (game_id, ply, uci, san, played_at) VALUES (?, ?, ?, ?, ?)`),
+			g.ID, ply, moves[ply], rec.SAN, rec.Time)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Load falls back to the archive for a game no longer in progress.
+func (st *sqlStore) Load(id string) (*Game, error) {
+	var row gameRow
+	err := st.db.QueryRow(st.rebind(`SELECT schema_version, variant, start_fen, result, end_reason, draw_offer, white, black, tags, settings
+		FROM games WHERE id = ?`), id).Scan(&row.Schema, &row.Variant, &row.StartFEN, &row.Result, &row.EndReason,
+		&row.DrawOffer, &row.White, &row.Black, &row.Tags, &row.Settings)
+	if errors.Is(err, sql.ErrNoRows) {
+		return st.loadArchived(id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := st.db.Query(st.rebind(`SELECT uci, played_at FROM moves WHERE game_id = ? ORDER BY ply`), id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var moves []storedMove
+	for rows.Next() {
+		var m storedMove
+		if err := rows.Scan(&m.UCI, &m.Time); err != nil {
+			return nil, err
+		}
+		moves = append(moves, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return restoreGame(id, row, moves)
+}
+
+// loadArchived reads the game's latest archived copy back.
+func (st *sqlStore) loadArchived(id string) (*Game, error) {
+	var row gameRow
+	var moves string
+	err := st.db.QueryRow(st.rebind(`SELECT schema_version, variant, start_fen, result, end_reason, white, black, tags, settings, moves
+		FROM archived_games WHERE game_id = ? ORDER BY archived_at DESC LIMIT 1`), id).Scan(&row.Schema, &row.Variant,
+		&row.StartFEN, &row.Result, &row.EndReason, &row.White, &row.Black, &row.Tags, &row.Settings, &moves)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errGameNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var played []storedMove
+	if err := json.Unmarshal([]byte(moves), &played); err != nil {
+		return nil, fmt.Errorf("game %s moves: %w", id, err)
+	}
+	g, err := restoreGame(id, row, played)
+	if err != nil {
+		return nil, err
+	}
+	// Nothing of the game is left in games
+	g.stored = storedGame{}
+	g.archived = true
+	return g, nil
+}
+
+func (st *sqlStore) List() ([]GameSummary, error) {
+	rows, err := st.db.Query(`SELECT g.id, g.tags, g.result, g.end_reason, g.updated_at,
+		(SELECT COUNT(*) FROM moves m WHERE m.game_id = g.id)
+		FROM games g WHERE g.deleted_at IS NULL ORDER BY g.updated_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []GameSummary
+	for rows.Next() {
+		var s GameSummary
+		var tags string
+		if err := rows.Scan(&s.ID, &tags, &s.Result, &s.EndReason, &s.Updated, &s.Plies); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(tags), &s.Tags); err != nil {
+			return nil, fmt.Errorf("game %s tags: %w", s.ID, err)
+		}
+		list = append(list, s)
+	}
+	return list, rows.Err()
+}
+
+// Archive copies the game to archived_games and deletes it from games,
+// taking its moves with it.
+func (st *sqlStore) Archive(g *Game) error {
+	row := rowOf(g)
+	moves, _ := json.Marshal(storedMoves(g))
+	tx, err := st.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	_, err = tx.Exec(st.rebind(`INSERT INTO archived_games
+		(game_id, schema_version, variant, start_fen, result, end_reason, white, black, tags, settings, moves,
+		player_white, player_black, date, eco, opening, plies, archived_at, deleted_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?,
+		(SELECT deleted_at FROM games WHERE id = ?))`),
+		g.ID, row.Schema, row.Variant, row.StartFEN, row.Result, row.EndReason, row.White, row.Black, row.Tags,
+		row.Settings, string(moves), strings.ToLower(g.Tags.White), strings.ToLower(g.Tags.Black),
+		g.Tags.Date, g.Opening.Code, g.Opening.Name, len(g.History), time.Now(), g.ID)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(st.rebind(`DELETE FROM games WHERE id = ?`), g.ID); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	g.stored = storedGame{}
+	return nil
+}
+
+func (st *sqlStore) Archived(f archiveFilter, p archivePage) ([]GameSummary, error) {
+	query := `SELECT game_id, tags, result, end_reason, eco, opening, plies, archived_at FROM archived_games WHERE deleted_at IS NULL`
+	var args []any
+	if f.Player != "" {
+		query += ` AND (player_white = ? OR player_black = ?)`
+		args = append(args, f.Player, f.Player)
+	}
+	if f.Date != "" {
+		query += ` AND date = ?`
+		args = append(args, f.Date)
+	}
+	if f.Result != "" {
+		query += ` AND result = ?`
+		args = append(args, string(f.Result))
+	}
+	if f.Opening != "" {
+		query += ` AND (eco = ? OR LOWER(opening) LIKE ?)`
+		args = append(args, f.Opening, "%"+f.Opening+"%")
+	}
+	cmp, order := "<", "DESC"
+	if p.Oldest {
+		cmp, order = ">", "ASC"
+	}
+	if p.After != (archiveCursor{}) {
+		query += ` AND (archived_at ` + cmp + ` ? OR (archived_at = ? AND game_id ` + cmp + ` ?))`
+		args = append(args, p.After.Updated, p.After.Updated, p.After.ID)
+	}
+	query += ` ORDER BY archived_at ` + order + `, game_id ` + order + ` LIMIT ?`
+	args = append(args, p.Limit)
+
+	rows, err := st.db.Query(st.rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []GameSummary
+	for rows.Next() {
+		var s GameSummary
+		var tags string
+		if err := rows.Scan(&s.ID, &tags, &s.Result, &s.EndReason, &s.ECO.Code, &s.ECO.Name, &s.Plies, &s.Updated); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(tags), &s.Tags); err != nil {
+			return nil, fmt.Errorf("game %s tags: %w", s.ID, err)
+		}
+		list = append(list, s)
+	}
+	return list, rows.Err()
+}
+
+// Delete marks the game and its archived copies deleted.
+func (st *sqlStore) Delete(id string, at time.Time) error {
+	return st.markDeleted(id, at)
+}
+
+func (st *sqlStore) Restore(id string) error {
+	return st.markDeleted(id, nil)
+}
+
+// markDeleted sets deleted_at of the game and its archived copies.
+func (st *sqlStore) markDeleted(id string, at any) error {
+	tx, err := st.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	var n int64
+	for _, query := range []string{
+		`UPDATE games SET deleted_at = ? WHERE id = ?`,
+		`UPDATE archived_games SET deleted_at = ? WHERE game_id = ?`,
+	} {
+		res, err := tx.Exec(st.rebind(query), at, id)
+		if err != nil {
+			return err
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		n += affected
+	}
+	if n == 0 {
+		return errGameNotFound
+	}
+	return tx.Commit()
+}
+
+func (st *sqlStore) Purge(before time.Time) ([]string, error) {
+	tx, err := st.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	rows, err := tx.Query(st.rebind(`SELECT id FROM games WHERE deleted_at < ?
+		UNION SELECT game_id FROM archived_games WHERE deleted_at < ?`), before, before)
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// Deleting a game takes its moves with it
+	if _, err := tx.Exec(st.rebind(`DELETE FROM games WHERE deleted_at < ?`), before); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(st.rebind(`DELETE FROM archived_games WHERE deleted_at < ?`), before); err != nil {
+		return nil, err
+	}
+	return ids, tx.Commit()
+}
+
+func (st *sqlStore) Audit(e AuditEvent) error {
+	_, err := st.db.Exec(st.rebind(`INSERT INTO audit_log (at, game_id, action, detail, session, ip) VALUES (?, ?, ?, ?, ?, ?)`),
+		e.Time, e.Game, e.Action, e.Detail, e.Session, e.IP)
+	return err
+}
+
+func (st *sqlStore) AuditLog(f auditFilter, limit int) ([]AuditEvent, error) {
+	query := `SELECT at, game_id, action, detail, session, ip FROM audit_log WHERE TRUE`
+	var args []any
+	for _, c := range []struct{ column, value string }{
+		{"game_id", f.Game}, {"session", f.Session}, {"ip", f.IP}, {"action", f.Action},
+	} {
+		if c.value != "" {
+			query += ` AND ` + c.column + ` = ?`
+			args = append(args, c.value)
+		}
+	}
+	query += ` ORDER BY at DESC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := st.db.Query(st.rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []AuditEvent
+	for rows.Next() {
+		var e AuditEvent
+		if err := rows.Scan(&e.Time, &e.Game, &e.Action, &e.Detail, &e.Session, &e.IP); err != nil {
+			return nil, err
+		}
+		list = append(list, e)
+	}
+	return list, rows.Err()
+}
+
+func (st *sqlStore) CreateAPIKey(k APIKey, hash string) error {
+	_, err := st.db.Exec(st.rebind(`INSERT INTO api_keys (hash, id, name, scope, created_at) VALUES (?, ?, ?, ?, ?)`),
+		hash, k.ID, k.Name, k.Scope, k.Created)
+	return err
+}
+
+func (st *sqlStore) APIKey(hash string) (APIKey, error) {
+	var k APIKey
+	err := st.db.QueryRow(st.rebind(`SELECT id, name, scope, created_at FROM api_keys WHERE hash = ?`), hash).
+		Scan(&k.ID, &k.Name, &k.Scope, &k.Created)
+	if errors.Is(err, sql.ErrNoRows) {
+		return APIKey{}, errAPIKeyNotFound
+	}
+	return k, err
+}
+
+func (st *sqlStore) APIKeys() ([]APIKey, error) {
+	rows, err := st.db.Query(`SELECT id, name, scope, created_at FROM api_keys ORDER BY created_at, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var keys []APIKey
+	for rows.Next() {
+		var k APIKey
+		if err := rows.Scan(&k.ID, &k.Name, &k.Scope, &k.Created); err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+func (st *sqlStore) RevokeAPIKey(id string) error {
+	res, err := st.db.Exec(st.rebind(`DELETE FROM api_keys WHERE id = ?`), id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return errAPIKeyNotFound
+	}
+	return nil
+}
+
+func (st *sqlStore) Close() error { return st.db.Close() }
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"io/fs"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rigurd/chess"
+)
+
+const (
+	// staticPrefix is the path the static assets are served under.
+	staticPrefix = "/static/"
+	// htmxCDN is where htmx is loaded from if it was not fetched into
+	// static/htmx.min.js with make htmx before building.
+	htmxCDN = "https://unpkg.com/htmx.org@1.9.10/dist/htmx.min.js"
+)
+
+// staticFiles are the stylesheet, scripts and piece images of the pages,
+// built into the binary so it needs no CDN. The piece images are drawn from
+// the silhouettes of the board images.
+//
+//go:embed static
+var staticFiles embed.FS
+
+// staticAsset is an embedded file, with the version its URLs carry.
+type staticAsset struct {
+	data    []byte
+	version string // hash of the content, changing with it
+}
+
+// staticAssets are the embedded files by their path under staticPrefix.
+var staticAssets = loadStaticAssets()
+
+// loadStaticAssets reads the embedded files and hashes them.
+func loadStaticAssets() map[string]staticAsset {
+	assets := make(map[string]staticAsset)
+	fs.WalkDir(staticFiles, "static", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := staticFiles.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(data)
+		assets[strings.TrimPrefix(path, "static/")] = staticAsset{data: data, version: hex.EncodeToString(sum[:8])}
+		return nil
+	})
+	return assets
+}
+
+// staticPath returns the URL of an embedded file, with its version in the
+// query so browsers may cache it for good and still get the new one after
+// an upgrade.
+func staticPath(name string) string {
+	return staticPrefix + name + "?v=" + staticAssets[name].version
+}
+
+// htmxPath returns the URL of htmx: the embedded copy if it was built in,
+// otherwise the CDN's.
+func htmxPath() string {
+	if _, ok := staticAssets["htmx.min.js"]; !ok {
+		return htmxCDN
+	}
+	return staticPath("htmx.min.js")
+}
+
+// piecePath returns the URL of a piece's image.
+func piecePath(p chess.Piece) string {
+	side := "b"
+	if chess.IsWhite(p) {
+		side = "w"
+	}
+	return staticPath("pieces/" + side + chess.PieceLetter(p) + ".svg")
+}
+
+// handleStatic serves an embedded file. Requested at its current version it
+// may be cached for a year; otherwise it is revalidated by its ETag.
+func (s *Server) handleStatic(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	name := strings.TrimPrefix(r.URL.Path, staticPrefix)
+	asset, ok := staticAssets[name]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if r.URL.Query().Get("v") == asset.version {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+	w.Header().Set("ETag", `"`+asset.version+`"`)
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(asset.data))
+}
@@ -0,0 +1,570 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rigurd/chess"
+)
+
+var (
+	// errGameNotFound is returned by GameStore.Load for an unknown game.
+	errGameNotFound = errors.New("game not found")
+	// errGameExists is returned by GameStore.Create for a taken game ID.
+	errGameExists = errors.New("game ID already taken")
+)
+
+// GameStore keeps games beyond the memory of one server process. The
+// GameManager saves each game to its store as it is played, and loads games
+// it does not hold from it. The methods taking a *Game must be called from a
+// command of its goroutine.
+type GameStore interface {
+	// Create stores a new game, failing with errGameExists if its ID is
+	// taken.
+	Create(g *Game) error
+	// Load reads a stored game back, failing with errGameNotFound if there
+	// is none with the ID.
+	Load(id string) (*Game, error)
+	// SaveMove writes what changed in g since it was created, loaded or
+	// last saved: moves played or taken back, and its result, players and
+	// settings.
+	SaveMove(g *Game) error
+	// List returns the games in progress, most recently played first.
+	List() ([]GameSummary, error)
+	// Archive moves a finished game to the archive, where it can still be
+	// loaded from. Saving the game once it is reset or taken back moves it
+	// out again, leaving the archived copy.
+	Archive(g *Game) error
+	// Archived returns the page p of the archived games matching f.
+	Archived(f archiveFilter, p archivePage) ([]GameSummary, error)
+	// Delete hides the game from listings as of at, leaving it to be
+	// restored, or purged once it has been deleted long enough.
+	Delete(id string, at time.Time) error
+	// Restore lists a deleted game again.
+	Restore(id string) error
+	// Purge destroys the games deleted before the given time, returning
+	// their IDs.
+	Purge(before time.Time) ([]string, error)
+	// Audit appends e to the audit log.
+	Audit(e AuditEvent) error
+	// AuditLog returns up to limit audit events matching f, most recent
+	// first.
+	AuditLog(f auditFilter, limit int) ([]AuditEvent, error)
+	// CreateAPIKey stores an API key issued, under the hash of the key.
+	CreateAPIKey(k APIKey, hash string) error
+	// APIKey returns the API key with the given hash, failing with
+	// errAPIKeyNotFound if there is none.
+	APIKey(hash string) (APIKey, error)
+	// APIKeys returns the API keys issued, oldest first.
+	APIKeys() ([]APIKey, error)
+	// RevokeAPIKey deletes the API key with the given ID, failing with
+	// errAPIKeyNotFound if there is none.
+	RevokeAPIKey(id string) error
+	// Close releases the store's connections, once the games have been
+	// saved for the last time.
+	Close() error
+}
+
+// sharedStore is a GameStore shared by several server instances, which
+// reports the games the other instances save so stale copies are dropped.
+type sharedStore interface {
+	GameStore
+	// Subscribe calls forget with the ID of each game saved by another
+	// instance. It returns when the store is closed.
+	Subscribe(forget func(id string))
+}
+
+// GameSummary describes a stored game in listings.
+type GameSummary struct {
+	ID        string        `json:"id"`
+	Tags      chess.PGNTags `json:"tags"`
+	Result    string        `json:"result"`
+	EndReason string        `json:"end_reason,omitempty"`
+	ECO       chess.ECO     `json:"eco"`
+	Plies     int           `json:"plies"`
+	Updated   time.Time     `json:"updated"` // when last played, or archived
+}
+
+// archiveFilter narrows a listing of archived games; empty fields match
+// every game. Fields are normalized by newArchiveFilter.
+type archiveFilter struct {
+	Player  string         // either player's name, in lower case
+	Date    string         // the game's PGN date, YYYY.MM.DD
+	Result  chess.EndState // e.g. chess.WhiteWins
+	Opening string         // ECO code in upper case, or part of an opening name in lower case
+}
+
+// newArchiveFilter returns a filter for the games played by player on date
+// (YYYY-MM-DD or YYYY.MM.DD) with result ("1-0" or "white wins") and
+// opening (an ECO code or part of the opening's name).
+func newArchiveFilter(player, date, result, opening string) archiveFilter {
+	f := archiveFilter{
+		Player:  strings.ToLower(strings.TrimSpace(player)),
+		Date:    strings.ReplaceAll(strings.TrimSpace(date), "-", "."),
+		Result:  chess.EndState(strings.TrimSpace(result)),
+		Opening: strings.TrimSpace(opening),
+	}
+	switch f.Result {
+	case "1-0":
+		f.Result = chess.WhiteWins
+	case "0-1":
+		f.Result = chess.BlackWins
+	case "1/2-1/2":
+		f.Result = chess.Draw
+	}
+	if isECOCode(f.Opening) {
+		f.Opening = strings.ToUpper(f.Opening)
+	} else {
+		f.Opening = strings.ToLower(f.Opening)
+	}
+	return f
+}
+
+// isECOCode reports whether s looks like an ECO code, such as "B90".
+func isECOCode(s string) bool {
+	return len(s) == 3 && strings.ContainsRune("ABCDEabcde", rune(s[0])) &&
+		s[1] >= '0' && s[1] <= '9' && s[2] >= '0' && s[2] <= '9'
+}
+
+// matches reports whether the archived game s passes the filter.
+func (f archiveFilter) matches(s GameSummary) bool {
+	switch {
+	case f.Player != "" && strings.ToLower(s.Tags.White) != f.Player && strings.ToLower(s.Tags.Black) != f.Player:
+		return false
+	case f.Date != "" && s.Tags.Date != f.Date:
+		return false
+	case f.Result != "" && chess.EndState(s.Result) != f.Result:
+		return false
+	case f.Opening != "" && s.ECO.Code != f.Opening && !strings.Contains(strings.ToLower(s.ECO.Name), f.Opening):
+		return false
+	}
+	return true
+}
+
+// archivePage selects a page of a listing of archived games, which are
+// ordered by when they were archived, then by ID.
+type archivePage struct {
+	Limit  int
+	Oldest bool          // oldest first, rather than most recent
+	After  archiveCursor // the last game of the previous page, or zero for the first page
+}
+
+// archiveCursor marks a game's place in a listing of archived games.
+type archiveCursor struct {
+	Updated time.Time
+	ID      string
+}
+
+// cursorOf returns the place of s in a listing.
+func cursorOf(s GameSummary) archiveCursor {
+	return archiveCursor{Updated: s.Updated, ID: s.ID}
+}
+
+// String returns c as an opaque token for clients to pass back.
+func (c archiveCursor) String() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(c.Updated.Format(time.RFC3339Nano) + " " + c.ID))
+}
+
+// parseArchiveCursor reads back a cursor returned by String, or the zero
+// cursor from "".
+func parseArchiveCursor(token string) (archiveCursor, error) {
+	if token == "" {
+		return archiveCursor{}, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return archiveCursor{}, errors.New("invalid cursor")
+	}
+	updated, id, ok := strings.Cut(string(data), " ")
+	t, err := time.Parse(time.RFC3339Nano, updated)
+	if !ok || err != nil {
+		return archiveCursor{}, errors.New("invalid cursor")
+	}
+	return archiveCursor{Updated: t, ID: id}, nil
+}
+
+// compare orders a before b, -1, or after it, 1, in an oldest first
+// listing.
+func (a archiveCursor) compare(b archiveCursor) int {
+	if c := a.Updated.Compare(b.Updated); c != 0 {
+		return c
+	}
+	return strings.Compare(a.ID, b.ID)
+}
+
+// apply returns the page p of list, the archived games matching a filter
+// in any order, for stores that cannot page themselves.
+func (p archivePage) apply(list []GameSummary) []GameSummary {
+	dir := 1
+	if !p.Oldest {
+		dir = -1
+	}
+	slices.SortFunc(list, func(a, b GameSummary) int {
+		return dir * cursorOf(a).compare(cursorOf(b))
+	})
+	if p.After != (archiveCursor{}) {
+		list = slices.DeleteFunc(list, func(s GameSummary) bool {
+			return dir*cursorOf(s).compare(p.After) <= 0
+		})
+	}
+	return list[:min(p.Limit, len(list))]
+}
+
+// openStore returns the store cfg selects: "memory", "sqlite" with
+// StoreDSN the database file, "postgres" with StoreDSN the connection
+// string, or "redis" with StoreDSN the server URL.
+func openStore(cfg Config) (GameStore, error) {
+	switch cfg.Store {
+	case "", "memory":
+		return newMemoryStore(), nil
+	case "sqlite":
+		return openSQLiteStore(cfg.StoreDSN)
+	case "postgres":
+		return openPostgresStore(cfg.StoreDSN)
+	case "redis":
+		return openRedisStore(cfg.StoreDSN)
+	}
+	return nil, fmt.Errorf("unknown game store %q", cfg.Store)
+}
+
+// memoryStore is a GameStore that keeps games in memory only, for
+// deployments where losing the games on a restart is acceptable.
+type memoryStore struct {
+	mu      sync.Mutex
+	games   map[string]*memoryGame
+	archive []GameSummary     // in the order the games were archived
+	audit   []AuditEvent      // in the order the events happened
+	apiKeys map[string]APIKey // by hash
+}
+
+// memoryGame is a game held by a memoryStore, with its listing.
+type memoryGame struct {
+	game     *Game
+	summary  GameSummary
+	archived bool
+	deleted  time.Time // zero unless deleted
+}
+
+// newMemoryStore returns an empty memoryStore.
+func newMemoryStore() *memoryStore {
+	return &memoryStore{games: make(map[string]*memoryGame), apiKeys: make(map[string]APIKey)}
+}
+
+// summaryOf returns the listing of g, leaving Updated for the caller.
+func summaryOf(g *Game) GameSummary {
+	return GameSummary{
+		ID:        g.ID,
+		Tags:      g.Tags,
+		Result:    string(g.Result),
+		EndReason: g.EndReason,
+		ECO:       g.Opening,
+		Plies:     len(g.History),
+	}
+}
+
+func (st *memoryStore) Create(g *Game) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.games[g.ID] != nil {
+		return errGameExists
+	}
+	mg := &memoryGame{game: g, summary: summaryOf(g)}
+	mg.summary.Updated = time.Now()
+	st.games[g.ID] = mg
+	return nil
+}
+
+func (st *memoryStore) Load(id string) (*Game, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if mg := st.games[id]; mg != nil {
+		return mg.game, nil
+	}
+	return nil, errGameNotFound
+}
+
+func (st *memoryStore) SaveMove(g *Game) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	mg := st.games[g.ID]
+	if mg == nil {
+		mg = &memoryGame{game: g}
+		st.games[g.ID] = mg
+	}
+	// Viewing a game saves it too, which does not count as playing it
+	summary := summaryOf(g)
+	summary.Updated = mg.summary.Updated
+	if summary != mg.summary {
+		summary.Updated = time.Now()
+		mg.summary = summary
+	}
+	mg.archived = false
+	return nil
+}
+
+func (st *memoryStore) List() ([]GameSummary, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	var list []GameSummary
+	for _, mg := range st.games {
+		if !mg.archived && mg.deleted.IsZero() {
+			list = append(list, mg.summary)
+		}
+	}
+	slices.SortFunc(list, func(a, b GameSummary) int {
+		return b.Updated.Compare(a.Updated)
+	})
+	return list, nil
+}
+
+func (st *memoryStore) Archive(g *Game) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	mg := st.games[g.ID]
+	if mg == nil {
+		return errGameNotFound
+	}
+	mg.archived = true
+	summary := summaryOf(g)
+	summary.Updated = time.Now()
+	st.archive = append(st.archive, summary)
+	return nil
+}
+
+func (st *memoryStore) Archived(f archiveFilter, p archivePage) ([]GameSummary, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	var list []GameSummary
+	for _, summary := range st.archive {
+		if f.matches(summary) && st.games[summary.ID].deleted.IsZero() {
+			list = append(list, summary)
+		}
+	}
+	return p.apply(list), nil
+}
+
+func (st *memoryStore) Delete(id string, at time.Time) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	mg := st.games[id]
+	if mg == nil {
+		return errGameNotFound
+	}
+	mg.deleted = at
+	return nil
+}
+
+func (st *memoryStore) Restore(id string) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	mg := st.games[id]
+	if mg == nil {
+		return errGameNotFound
+	}
+	mg.deleted = time.Time{}
+	return nil
+}
+
+func (st *memoryStore) Purge(before time.Time) ([]string, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	var ids []string
+	for id, mg := range st.games {
+		if !mg.deleted.IsZero() && mg.deleted.Before(before) {
+			ids = append(ids, id)
+			delete(st.games, id)
+		}
+	}
+	st.archive = slices.DeleteFunc(st.archive, func(s GameSummary) bool {
+		return st.games[s.ID] == nil
+	})
+	return ids, nil
+}
+
+func (st *memoryStore) Audit(e AuditEvent) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.audit = append(st.audit, e)
+	return nil
+}
+
+func (st *memoryStore) AuditLog(f auditFilter, limit int) ([]AuditEvent, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	var list []AuditEvent
+	for _, e := range slices.Backward(st.audit) {
+		if len(list) == limit {
+			break
+		}
+		if f.matches(e) {
+			list = append(list, e)
+		}
+	}
+	return list, nil
+}
+
+func (st *memoryStore) CreateAPIKey(k APIKey, hash string) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.apiKeys[hash] = k
+	return nil
+}
+
+func (st *memoryStore) APIKey(hash string) (APIKey, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	k, ok := st.apiKeys[hash]
+	if !ok {
+		return APIKey{}, errAPIKeyNotFound
+	}
+	return k, nil
+}
+
+func (st *memoryStore) APIKeys() ([]APIKey, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	keys := slices.Collect(maps.Values(st.apiKeys))
+	slices.SortFunc(keys, compareAPIKeys)
+	return keys, nil
+}
+
+func (st *memoryStore) RevokeAPIKey(id string) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	for hash, k := range st.apiKeys {
+		if k.ID == id {
+			delete(st.apiKeys, hash)
+			return nil
+		}
+	}
+	return errAPIKeyNotFound
+}
+
+func (st *memoryStore) Close() error { return nil }
+
+// gameRow is what a store holds of a game besides its moves.
+type gameRow struct {
+	Schema    int // version of the layout the game was stored in
+	Variant   string
+	StartFEN  string
+	Result    string
+	EndReason string
+	DrawOffer string
+	White     string
+	Black     string
+	Tags      string
+	Settings  string
+}
+
+// storedGame is what was last written of a game, so a save only writes
+// what changed since.
+type storedGame struct {
+	row     gameRow
+	moves   []string // UCI of each mainline move
+	version int64    // of a shared store's copy, to detect another server's writes
+}
+
+// storedMove is a mainline move as stored.
+type storedMove struct {
+	UCI  string    `json:"uci"`
+	SAN  string    `json:"san"`
+	Time time.Time `json:"time"`
+}
+
+// rowOf returns what a store holds of g besides its moves.
+func rowOf(g *Game) gameRow {
+	tags, _ := json.Marshal(g.Tags)
+	settings, _ := json.Marshal(g.Settings)
+	return gameRow{
+		Schema:    gameSchema,
+		Variant:   string(g.Variant),
+		StartFEN:  g.StartFEN,
+		Result:    string(g.Result),
+		EndReason: g.EndReason,
+		DrawOffer: string(g.DrawOffer),
+		White:     g.Players[chess.White],
+		Black:     g.Players[chess.Black],
+		Tags:      string(tags),
+		Settings:  string(settings),
+	}
+}
+
+// storedMoves returns the moves of g's mainline as stored.
+func storedMoves(g *Game) []storedMove {
+	moves := make([]storedMove, len(g.History))
+	for i, rec := range g.History {
+		moves[i] = storedMove{UCI: chess.UCI(rec.Move), SAN: rec.SAN, Time: rec.Time}
+	}
+	return moves
+}
+
+// mainline returns the UCI of each move of g's mainline.
+func mainline(g *Game) []string {
+	moves := make([]string, len(g.History))
+	for i, rec := range g.History {
+		moves[i] = chess.UCI(rec.Move)
+	}
+	return moves
+}
+
+// restoreGame rebuilds a stored game by replaying its moves from the
+// starting position, first migrating a game stored with an older schema.
+// The game is written in the current schema when next saved.
+func restoreGame(id string, row gameRow, moves []storedMove) (*Game, error) {
+	stored := row
+	if err := migrateGame(id, &row, moves); err != nil {
+		return nil, err
+	}
+	pos, err := chess.ParseFEN(row.StartFEN)
+	if err != nil {
+		return nil, fmt.Errorf("game %s: %w", id, err)
+	}
+	if chess.Variant(row.Variant) == chess.Crazyhouse && pos.Variant != chess.Crazyhouse {
+		pos.Variant = chess.Crazyhouse
+		pos.Positions = map[string]int{chess.PositionKey(pos): 1}
+	}
+	ucis := make([]string, len(moves))
+	for i, sm := range moves {
+		m, err := chess.ParseUCI(pos, sm.UCI)
+		if err != nil {
+			return nil, fmt.Errorf("game %s, move %d: %w", id, i+1, err)
+		}
+		if m.Drop != chess.Empty {
+			chess.Drop(pos, m.Drop, m.To)
+		} else {
+			chess.Play(pos, m)
+		}
+		pos.History[len(pos.History)-1].Time = sm.Time
+		ucis[i] = sm.UCI
+	}
+
+	// Resignations and agreed draws are not seen by replaying the moves
+	pos.Result = chess.EndState(row.Result)
+	pos.EndReason = row.EndReason
+	pos.DrawOffer = chess.PieceColor(row.DrawOffer)
+
+	g := &Game{ID: id, GameState: *pos, Players: make(map[chess.PieceColor]string)}
+	if row.White != "" {
+		g.Players[chess.White] = row.White
+	}
+	if row.Black != "" {
+		g.Players[chess.Black] = row.Black
+	}
+	if err := json.Unmarshal([]byte(row.Tags), &g.Tags); err != nil {
+		return nil, fmt.Errorf("game %s tags: %w", id, err)
+	}
+	if err := json.Unmarshal([]byte(row.Settings), &g.Settings); err != nil {
+		return nil, fmt.Errorf("game %s settings: %w", id, err)
+	}
+	g.stored = storedGame{row: stored, moves: ucis}
+	g.started = time.Now()
+	g.markNotified()
+	return g, nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/a-h/templ"
+	"github.com/rigurd/chess"
+)
+
+// TakebackRequest is a player's pending request to take back their last
+// move, which in a two-player game needs the opponent's consent.
+type TakebackRequest struct {
+	By    chess.PieceColor
+	Plies int // moves to take back so By is to move again
+	At    int // length of the history when asked; later moves void it
+}
+
+// twoPlayer reports whether different sessions play the two sides, so
+// undoing moves needs both players to agree.
+func (g *Game) twoPlayer() bool {
+	return g.Players[chess.White] != "" && g.Players[chess.Black] != ""
+}
+
+// pendingTakeback returns the takeback request awaiting an answer, if it
+// still applies: any move played since it was made voids it.
+func (g *Game) pendingTakeback() *TakebackRequest {
+	if g.Takeback == nil || g.Takeback.At != len(g.History) || g.Result != chess.Ongoing {
+		return nil
+	}
+	return g.Takeback
+}
+
+// takebackText describes a pending takeback request for the board.
+func takebackText(t *TakebackRequest) string {
+	if t.Plies == 1 {
+		return fmt.Sprintf("%s asks to take back their last move", t.By)
+	}
+	return fmt.Sprintf("%s asks to take back the last %d moves", t.By, t.Plies)
+}
+
+// handleRequestTakeback asks the opponent to let the requesting player take
+// back their last move. When the opponent has already replied, both moves
+// are taken back so the requester is to move again.
+func (s *Server) handleRequestTakeback(w http.ResponseWriter, r *http.Request, g *Game) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	g.update(func() {
+		side, seated := g.sideOf(sessionOf(r))
+		if !seated {
+			http.Error(w, "Only the players can do that", http.StatusForbidden)
+			return
+		}
+		plies := 1
+		if g.CurrentPlayer == side {
+			plies = 2
+		}
+		if plies > len(g.History) || g.History[len(g.History)-plies].Color != side {
+			http.Error(w, "You have no move to take back", http.StatusConflict)
+			return
+		}
+		g.Takeback = &TakebackRequest{By: side, Plies: plies, At: len(g.History)}
+		s.audit(r, g, "request-takeback", strconv.Itoa(plies))
+		templ.Handler(chessboardWithLabels(g, threatSquares(g, showThreats(r)))).ServeHTTP(w, r)
+	})
+}
+
+// handleRespondTakeback accepts (accept=1) or declines the pending takeback
+// request on behalf of the player who did not make it.
+func (s *Server) handleRespondTakeback(w http.ResponseWriter, r *http.Request, g *Game) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	g.update(func() {
+		t := g.pendingTakeback()
+		if t == nil {
+			http.Error(w, "No takeback request pending", http.StatusConflict)
+			return
+		}
+		if !requireSide(w, r, g, chess.Opponent(t.By)) {
+			return
+		}
+		g.Takeback = nil
+		if r.FormValue("accept") == "1" {
+			for range t.Plies {
+				chess.Undo(&g.GameState)
+			}
+			// The moves are gone for good, not waiting to be redone
+			g.Undone = nil
+			g.clearSelection()
+			g.LastError = nil
+			s.audit(r, g, "accept-takeback", strconv.Itoa(t.Plies))
+		} else {
+			s.audit(r, g, "decline-takeback", "")
+		}
+		templ.Handler(chessboardWithLabels(g, threatSquares(g, showThreats(r)))).ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/a-h/templ"
+	"github.com/rigurd/chess"
+)
+
+// threatsCookie keeps whether the visitor has the threats overlay on, on
+// every board they view.
+const threatsCookie = "rigurd_threats"
+
+// threatSquares returns every square the opponent of the side to move
+// attacks, or nil when the threats overlay is off. It must be called from
+// a command of g's goroutine.
+func threatSquares(g *Game, show bool) map[chess.Square]bool {
+	if !show {
+		return nil
+	}
+	threats := make(map[chess.Square]bool)
+	enemy := chess.Opponent(g.CurrentPlayer)
+	for r := 0; r < 8; r++ {
+		for c := 0; c < 8; c++ {
+			sq := chess.Square{Row: r, Col: c}
+			if len(chess.AttackersOf(&g.GameState, sq, enemy)) > 0 {
+				threats[sq] = true
+			}
+		}
+	}
+	return threats
+}
+
+// showThreats reports whether the board rendered for the request shows the
+// threats overlay: as a threats=1 or threats=0 parameter asks, and
+// otherwise as the visitor's cookie keeps it.
+func showThreats(r *http.Request) bool {
+	switch r.FormValue("threats") {
+	case "1":
+		return true
+	case "0":
+		return false
+	}
+	c, err := r.Cookie(threatsCookie)
+	return err == nil && c.Value == "1"
+}
+
+// handleThreats switches the visitor's threats overlay on or off as the
+// threats parameter asks, toggling it when there is none, and keeps the
+// choice in their cookie. It is the visitor's own view that changes, so
+// spectators may use it as well as the players.
+func (s *Server) handleThreats(w http.ResponseWriter, r *http.Request, g *Game) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	show := showThreats(r)
+	if r.FormValue("threats") == "" {
+		show = !show
+	}
+	value := "0"
+	if show {
+		value = "1"
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     threatsCookie,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   sessionMaxAge,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	g.do(func() {
+		templ.Handler(chessboardWithLabels(g, threatSquares(g, show))).ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/rigurd/chess"
+)
+
+func TestThreatsOverlayIsPerVisitor(t *testing.T) {
+	s, ts := newTestServer(t)
+	g := newTestGame(t, s, chess.StartFEN)
+	player, spectator := newTestClient(t, s, ts), newTestClient(t, s, ts)
+	threatened := func(c *testClient, path string) bool {
+		t.Helper()
+		status, body := c.get(path)
+		if status != http.StatusOK {
+			t.Fatalf("GET %s: status %d: %s", path, status, body)
+		}
+		return strings.Contains(body, "threatened")
+	}
+
+	if threatened(player, gamePath(g, "")) {
+		t.Error("the overlay is on before anyone switched it on")
+	}
+	if !threatened(player, gamePath(g, "")+"?threats=1") {
+		t.Error("threats=1 does not show the overlay")
+	}
+	if threatened(player, gamePath(g, "")) {
+		t.Error("viewing the board with threats=1 switched the overlay on for good")
+	}
+
+	if status, _ := spectator.get(gamePath(g, "/threats")); status != http.StatusMethodNotAllowed {
+		t.Errorf("GET /threats: status %d, want %d", status, http.StatusMethodNotAllowed)
+	}
+	if status, body := spectator.post(gamePath(g, "/threats"), url.Values{}); status != http.StatusOK || !strings.Contains(body, "threatened") {
+		t.Fatalf("toggling the overlay: status %d, overlay shown %v", status, strings.Contains(body, "threatened"))
+	}
+	if !threatened(spectator, gamePath(g, "")) {
+		t.Error("the overlay the spectator switched on is not kept for them")
+	}
+	if threatened(player, gamePath(g, "")) {
+		t.Error("the spectator switched the overlay on for the player too")
+	}
+	if threatened(spectator, gamePath(g, "")+"?threats=0") {
+		t.Error("threats=0 does not hide the overlay")
+	}
+
+	spectator.post(gamePath(g, "/threats"), url.Values{"threats": {"0"}})
+	if threatened(spectator, gamePath(g, "")) {
+		t.Error("threats=0 did not switch the overlay off")
+	}
+}
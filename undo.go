@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/a-h/templ"
+	"github.com/rigurd/chess"
+)
+
+// handleUndo takes back the last move of the game. Once two players have
+// joined, moves are only taken back by agreement, through a takeback
+// request.
+func (s *Server) handleUndo(w http.ResponseWriter, r *http.Request, g *Game) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	g.update(func() {
+		if !requirePlayer(w, r, g) {
+			return
+		}
+		if g.twoPlayer() {
+			http.Error(w, "Ask your opponent for a takeback instead", http.StatusConflict)
+			return
+		}
+		rec, err := chess.Undo(&g.GameState)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		s.audit(r, g, "undo", rec.SAN)
+		g.clearSelection()
+		g.LastError = nil
+		templ.Handler(chessboardWithLabels(g, threatSquares(g, showThreats(r)))).ServeHTTP(w, r)
+	})
+}
+
+// handleRedo plays the last undone move again, unless two players have
+// joined.
+func (s *Server) handleRedo(w http.ResponseWriter, r *http.Request, g *Game) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	g.update(func() {
+		if !requirePlayer(w, r, g) {
+			return
+		}
+		if g.twoPlayer() {
+			http.Error(w, "Ask your opponent for a takeback instead", http.StatusConflict)
+			return
+		}
+		if n := len(g.Undone); n > 0 {
+			if err := g.logMove(g.Undone[n-1].Move); err != nil {
+				http.Error(w, err.Error(), http.StatusServiceUnavailable)
+				return
+			}
+		}
+		if err := chess.Redo(&g.GameState); err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		s.auditMoves(r, g, len(g.History)-1)
+		g.clearSelection()
+		g.LastError = nil
+		templ.Handler(chessboardWithLabels(g, threatSquares(g, showThreats(r)))).ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/rigurd/chess"
+)
+
+// moveCheck is the outcome of trying a move without playing it.
+type moveCheck struct {
+	Legal bool `json:"legal"`
+	// PromotionNeeded is set for a pawn move to the last rank naming no
+	// piece to promote to, which is legal once one is named.
+	PromotionNeeded bool           `json:"promotion_needed,omitempty"`
+	SAN             string         `json:"san,omitempty"`
+	UCI             string         `json:"uci,omitempty"`
+	FEN             string         `json:"fen,omitempty"`   // position after the move
+	Flags           chess.MoveFlag `json:"flags,omitempty"` // e.g. ["capture","check"]
+	Result          chess.EndState `json:"result,omitempty"`
+	EndReason       string         `json:"end_reason,omitempty"`
+	Error           string         `json:"error,omitempty"` // why the move is illegal
+	Code            string         `json:"code,omitempty"`  // chess.MoveError code of Error, if any
+}
+
+// checkMove tries the typed move text on a copy of g and describes the
+// outcome. It must be called from a command of g's goroutine.
+func checkMove(g *Game, text string) moveCheck {
+	m, err := parseTextMove(g, text)
+	if err != nil {
+		if promotionNeeded(g, text) {
+			return moveCheck{Legal: true, PromotionNeeded: true}
+		}
+		var moveErr chess.MoveError
+		errors.As(err, &moveErr)
+		return moveCheck{Error: err.Error(), Code: string(moveErr)}
+	}
+
+	after := g.Clone()
+	if m.Drop != chess.Empty {
+		chess.Drop(after, m.Drop, m.To)
+	} else {
+		chess.Play(after, m)
+	}
+	rec := after.History[len(after.History)-1]
+	return moveCheck{
+		Legal:     true,
+		SAN:       rec.SAN,
+		UCI:       chess.UCI(m),
+		FEN:       after.FEN(),
+		Flags:     rec.Flags,
+		Result:    after.Result,
+		EndReason: after.EndReason,
+	}
+}
+
+// promotionNeeded reports whether text is a pawn move to the last rank that
+// would be legal if it named a piece to promote to.
+func promotionNeeded(g *Game, text string) bool {
+	text = strings.TrimSpace(text)
+	if text == "" || g.Result != chess.Ongoing {
+		return false
+	}
+	for _, promoted := range []string{text + "q", text + "=Q"} {
+		if m, err := chess.ParseMove(&g.GameState, promoted); err == nil && m.Promotion != chess.Empty {
+			return true
+		}
+	}
+	return false
+}
+
+// handleAPIValidateMove checks the move given as move=, in SAN or UCI, and
+// returns the position and flags it would lead to without playing it, for
+// previews and bots. An illegal move is still answered 200, with legal
+// false.
+func (s *Server) handleAPIValidateMove(w http.ResponseWriter, r *http.Request, g *Game) {
+	if !requireAPIMethod(w, r, http.MethodGet) {
+		return
+	}
+	text := r.FormValue("move")
+	if text == "" {
+		writeAPIError(w, http.StatusBadRequest, codeBadRequest, "move is required")
+		return
+	}
+
+	var check moveCheck
+	g.do(func() { check = checkMove(g, text) })
+
+	writeJSON(w, check)
+}
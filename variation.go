@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/a-h/templ"
+)
+
+// variationRequest is the JSON body accepted by POST /api/variation: moves in
+// SAN or UCI to store as an alternative to the mainline move at index Ply.
+type variationRequest struct {
+	Ply   int      `json:"ply"`
+	Moves []string `json:"moves"`
+}
+
+// handleAddVariation stores an analysis line alongside the game's moves, on
+// behalf of one of the players.
+func (s *Server) handleAddVariation(w http.ResponseWriter, r *http.Request, g *Game) {
+	if r.Method != http.MethodPost {
+		writeAPIError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req variationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeAPIError(w, http.StatusBadRequest, codeBadRequest, "invalid JSON: "+err.Error())
+		return
+	}
+
+	g.update(func() {
+		if !requireAPIPlayer(w, r, g) {
+			return
+		}
+		if err := g.AddVariation(req.Ply, req.Moves); err != nil {
+			writeAPIError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+			return
+		}
+		s.audit(r, g, "add-variation", strconv.Itoa(req.Ply)+": "+strings.Join(req.Moves, " "))
+		writeJSON(w, map[string]string{"status": "ok"})
+	})
+}
+
+// handlePromoteVariation makes variation n of the mainline move at index ply
+// the new mainline, moving the board to the end of it.
+func (s *Server) handlePromoteVariation(w http.ResponseWriter, r *http.Request, g *Game) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ply, err1 := strconv.Atoi(r.FormValue("ply"))
+	n, err2 := strconv.Atoi(r.FormValue("n"))
+	if err1 != nil || err2 != nil {
+		http.Error(w, "ply and n must be integers", http.StatusBadRequest)
+		return
+	}
+
+	g.update(func() {
+		if !requirePlayer(w, r, g) {
+			return
+		}
+		if err := g.PromoteVariation(ply, n); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.audit(r, g, "promote-variation", strconv.Itoa(ply)+"/"+strconv.Itoa(n))
+		g.clearSelection()
+		templ.Handler(chessboardWithLabels(g, threatSquares(g, showThreats(r)))).ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestHandleAddVariation(t *testing.T) {
+	s, ts := newTestServer(t)
+	g := newTestGame(t, s, "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1")
+	white, spectator := newTestClient(t, s, ts), newTestClient(t, s, ts)
+	if status, body := white.post(gamePath(g, "/move-text"), url.Values{"move": {"e4"}}); status != http.StatusOK {
+		t.Fatalf("e4: status %d: %s", status, body)
+	}
+	path := gamePath(g, "/api/variation")
+	line := variationRequest{Ply: 0, Moves: []string{"d4", "d5"}}
+
+	if status, _ := spectator.postJSON(path, line); status != http.StatusForbidden {
+		t.Errorf("spectator: status %d, want %d", status, http.StatusForbidden)
+	}
+	g.do(func() {
+		if n := len(g.History[0].Variations); n != 0 {
+			t.Errorf("the spectator added %d variations", n)
+		}
+	})
+
+	if status, body := white.postJSON(path, line); status != http.StatusOK {
+		t.Fatalf("player: status %d: %s", status, body)
+	}
+	g.do(func() {
+		if n := len(g.History[0].Variations); n != 1 {
+			t.Errorf("%d variations after the player's, want 1", n)
+		}
+	})
+	events, err := s.games.store.AuditLog(auditFilter{Game: g.ID, Action: "add-variation"}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Session != white.session() || events[0].Detail != "0: d4 d5" {
+		t.Errorf("audit events %+v, want the player's variation", events)
+	}
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/rigurd/chess"
+)
+
+// errMoveNotLogged is shown to a player whose move could not be written to
+// the move log, and so was not played.
+var errMoveNotLogged = errors.New("the move could not be recorded, please try again")
+
+// moveLog is a write-ahead log of the moves played, so the games live when
+// the server crashed can be rebuilt on the next start. Each accepted move
+// is appended and synced to disk before it is played; once a game is saved
+// to a store that outlives the process, a checkpoint records that the moves
+// before it need no replaying.
+type moveLog struct {
+	mu   sync.Mutex
+	path string
+	f    *os.File
+}
+
+// walEntry is one line of the move log: a move played at ply of the game,
+// or with Saved set a checkpoint of the game saved with ply moves. A move
+// carries what is needed to set the game up afresh when the store has no
+// copy of it.
+type walEntry struct {
+	Game     string    `json:"game"`
+	Ply      int       `json:"ply"`
+	Saved    bool      `json:"saved,omitempty"`
+	UCI      string    `json:"uci,omitempty"`
+	Time     time.Time `json:"time,omitzero"`
+	Variant  string    `json:"variant,omitempty"`
+	StartFEN string    `json:"start_fen,omitempty"`
+	White    string    `json:"white,omitempty"`
+	Black    string    `json:"black,omitempty"`
+}
+
+// openMoveLog opens the move log at path, creating it if need be.
+func openMoveLog(path string) (*moveLog, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &moveLog{path: path, f: f}, nil
+}
+
+// append writes e to the log, syncing it to disk unless it is a
+// checkpoint: losing one only replays moves the store already has.
+func (l *moveLog) append(e walEntry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.f.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	if e.Saved {
+		return nil
+	}
+	return l.f.Sync()
+}
+
+// read returns the entries in the log. A last line cut short by a crash
+// is skipped, its move never having been played.
+func (l *moveLog) read() ([]walEntry, error) {
+	f, err := os.Open(l.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var entries []walEntry
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 1<<20)
+	for line := 1; sc.Scan(); line++ {
+		var e walEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			log.Printf("move log line %d: %v", line, err)
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return entries, sc.Err()
+}
+
+// rewrite replaces the log with entries, in a way a crash meanwhile leaves
+// either the old log or the new one.
+func (l *moveLog) rewrite(entries []walEntry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	tmp, err := os.CreateTemp(filepath.Dir(l.path), filepath.Base(l.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	w := bufio.NewWriter(tmp)
+	for _, e := range entries {
+		data, err := json.Marshal(e)
+		if err != nil {
+			tmp.Close()
+			return err
+		}
+		w.Write(append(data, '\n'))
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), l.path); err != nil {
+		return err
+	}
+	l.f.Close()
+	l.f, err = os.OpenFile(l.path, os.O_APPEND|os.O_WRONLY, 0o644)
+	return err
+}
+
+func (l *moveLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.f.Close()
+}
+
+// logMove appends m, about to be played in g, to the game's move log. It
+// returns errMoveNotLogged if that failed, when m must not be played.
+func (g *Game) logMove(m chess.Move) error {
+	if g.wal == nil {
+		return nil
+	}
+	err := g.wal.append(walEntry{
+		Game:     g.ID,
+		Ply:      len(g.History),
+		UCI:      chess.UCI(m),
+		Time:     time.Now(),
+		Variant:  string(g.Variant),
+		StartFEN: g.StartFEN,
+		White:    g.Players[chess.White],
+		Black:    g.Players[chess.Black],
+	})
+	if err != nil {
+		log.Printf("logging move of game %s: %v", g.ID, err)
+		return errMoveNotLogged
+	}
+	g.logged = true
+	return nil
+}
+
+// play logs the legal move m and plays it, or returns errMoveNotLogged.
+func (g *Game) play(m chess.Move) error {
+	if err := g.logMove(m); err != nil {
+		return err
+	}
+	if m.Drop != chess.Empty {
+		chess.Drop(&g.GameState, m.Drop, m.To)
+	} else {
+		chess.Play(&g.GameState, m)
+	}
+	return nil
+}
+
+// useMoveLog replays the moves in l that the store lacks, saving the games
+// they rebuild, then logs the moves played from now on to l. The log is
+// left holding only what the store cannot: nothing for a store that
+// outlives the process, and the games in progress for the memory store.
+func (m *GameManager) useMoveLog(l *moveLog) error {
+	entries, err := l.read()
+	if err != nil {
+		return err
+	}
+
+	// The moves of each game since it was last saved, in the order played
+	var ids []string
+	pending := make(map[string][]walEntry)
+	for _, e := range entries {
+		if _, seen := pending[e.Game]; !seen {
+			ids = append(ids, e.Game)
+		}
+		if e.Saved {
+			pending[e.Game] = pending[e.Game][:0]
+		} else {
+			pending[e.Game] = append(pending[e.Game], e)
+		}
+	}
+
+	_, volatile := m.store.(*memoryStore)
+	var games []*Game
+	var keep []walEntry
+	for _, id := range ids {
+		moves := pending[id]
+		if len(moves) == 0 {
+			continue
+		}
+		g, err := m.replayMoves(id, moves)
+		if err != nil {
+			log.Printf("replaying moves of game %s: %v", id, err)
+			continue
+		}
+		games = append(games, g)
+		if volatile && g.Result == chess.Ongoing {
+			for ply, rec := range g.History {
+				keep = append(keep, walEntry{
+					Game: g.ID, Ply: ply, UCI: chess.UCI(rec.Move), Time: rec.Time,
+					Variant: string(g.Variant), StartFEN: g.StartFEN,
+					White: g.Players[chess.White], Black: g.Players[chess.Black],
+				})
+			}
+		}
+	}
+	if len(games) > 0 {
+		log.Printf("replayed the move log of %d games", len(games))
+	}
+	if err := l.rewrite(keep); err != nil {
+		return err
+	}
+	m.wal = l
+	for _, g := range games {
+		g.do(func() { g.wal = l })
+	}
+	return nil
+}
+
+// replayMoves plays the logged moves of the game with the given ID on its
+// stored copy, or on a game set up afresh if the store has none, and saves
+// the result, holding it in memory. A move the stored copy already has is
+// skipped, and one at a ply it has another move at takes that move back
+// first.
+func (m *GameManager) replayMoves(id string, moves []walEntry) (*Game, error) {
+	g, err := m.store.Load(id)
+	if errors.Is(err, errGameNotFound) {
+		first := moves[0]
+		fresh := newGame()
+		tags, _ := json.Marshal(fresh.Tags)
+		settings, _ := json.Marshal(fresh.Settings)
+		row := gameRow{
+			Schema:   gameSchema,
+			Variant:  first.Variant,
+			StartFEN: first.StartFEN,
+			Result:   string(chess.Ongoing),
+			White:    first.White,
+			Black:    first.Black,
+			Tags:     string(tags),
+			Settings: string(settings),
+		}
+		if g, err = restoreGame(id, row, nil); err == nil {
+			g.stored = storedGame{}
+			err = m.store.Create(g)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range moves {
+		// A side is taken by its first move, so later entries name more
+		// players
+		if e.White != "" {
+			g.Players[chess.White] = e.White
+		}
+		if e.Black != "" {
+			g.Players[chess.Black] = e.Black
+		}
+		if e.Ply > len(g.History) {
+			return nil, fmt.Errorf("move %d logged without the moves before it", e.Ply+1)
+		}
+		if e.Ply < len(g.History) && chess.UCI(g.History[e.Ply].Move) == e.UCI {
+			continue
+		}
+		for len(g.History) > e.Ply {
+			chess.Undo(&g.GameState)
+		}
+		mv, err := chess.ParseUCI(&g.GameState, e.UCI)
+		if err != nil {
+			return nil, fmt.Errorf("move %d: %w", e.Ply+1, err)
+		}
+		g.play(mv)
+		g.History[len(g.History)-1].Time = e.Time
+	}
+	g.Undone = nil
+
+	m.mu.Lock()
+	m.games[id] = g
+	m.mu.Unlock()
+	m.Save(g)
+	return g, nil
+}
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/rigurd/chess"
+)
+
+const (
+	// maxWebhooks caps the webhooks of one game.
+	maxWebhooks = 5
+	// webhookTimeout bounds each attempt at delivering an event.
+	webhookTimeout = 5 * time.Second
+	// webhookAttempts is how many times an event is sent before it is
+	// given up on, waiting twice as long after each failure.
+	webhookAttempts = 3
+)
+
+// The events a webhook is sent.
+const (
+	eventStart = "start" // the first move was played
+	eventMove  = "move"  // a move was played, the first included
+	eventEnd   = "end"   // the game ended, however it did
+)
+
+// webhookEventNames are the events a webhook may ask for.
+var webhookEventNames = []string{eventStart, eventMove, eventEnd}
+
+// Webhook is a URL the events of a game are posted to as JSON, signed with
+// its secret.
+type Webhook struct {
+	ID     string
+	URL    string
+	Secret string
+	Events []string `json:",omitempty"` // all events when empty
+}
+
+// wants reports whether the webhook asked for event.
+func (h Webhook) wants(event string) bool {
+	return len(h.Events) == 0 || slices.Contains(h.Events, event)
+}
+
+// apiWebhook is a webhook as the JSON API lists it, without its secret.
+type apiWebhook struct {
+	ID     string   `json:"id"`
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+}
+
+func newAPIWebhook(h Webhook) apiWebhook {
+	events := h.Events
+	if len(events) == 0 {
+		events = webhookEventNames
+	}
+	return apiWebhook{ID: h.ID, URL: h.URL, Events: events}
+}
+
+// webhookPayload is the body of an event posted to a webhook.
+type webhookPayload struct {
+	Delivery string    `json:"delivery"` // tells retries of one event apart from others
+	Event    string    `json:"event"`
+	Time     time.Time `json:"time"`
+	Game     apiGame   `json:"game"`           // the game once the event happened
+	Move     *apiMove  `json:"move,omitempty"` // the move played, for move events
+}
+
+// notifiedState is how far a game's webhooks were told of it.
+type notifiedState struct {
+	ply    int
+	result chess.EndState
+}
+
+// markNotified records the webhooks of g as told of everything so far. It
+// must be called from a command of g's goroutine, or before g is shared.
+func (g *Game) markNotified() {
+	g.notified = notifiedState{ply: len(g.History), result: g.Result}
+}
+
+// webhookEvents returns the events of g its webhooks were not told of yet,
+// marking them told. Moves taken back are forgotten, and a reset game
+// starts afresh. It must be called from a command of g's goroutine.
+func (g *Game) webhookEvents() []webhookPayload {
+	prev := g.notified
+	g.markNotified()
+	if len(g.Settings.Webhooks) == 0 {
+		return nil
+	}
+	if g.Result == chess.Ongoing {
+		// Reset, or the end taken back
+		prev.result = chess.Ongoing
+	}
+	prev.ply = min(prev.ply, len(g.History))
+
+	now := time.Now().UTC()
+	game := newAPIGame(g, "")
+	var events []webhookPayload
+	event := func(name string, move *apiMove) {
+		events = append(events, webhookPayload{Delivery: newGameID(), Event: name, Time: now, Game: game, Move: move})
+	}
+	if prev.ply == 0 && len(g.History) > 0 {
+		event(eventStart, nil)
+	}
+	for ply := prev.ply; ply < len(g.History); ply++ {
+		m := newAPIMove(ply, g.History[ply])
+		event(eventMove, &m)
+	}
+	if prev.result == chess.Ongoing && g.Result != chess.Ongoing {
+		event(eventEnd, nil)
+	}
+	return events
+}
+
+// webhookSender posts events to webhooks.
+type webhookSender struct {
+	client *http.Client
+}
+
+// newWebhookSender returns a sender refusing to connect to loopback,
+// private and link-local addresses, so players cannot make the server call
+// services only it can reach, unless allowPrivate is set.
+func newWebhookSender(allowPrivate bool) *webhookSender {
+	dialer := &net.Dialer{Timeout: webhookTimeout}
+	if !allowPrivate {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+				ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() {
+				return fmt.Errorf("webhook address %s is not public", host)
+			}
+			return nil
+		}
+	}
+	return &webhookSender{client: &http.Client{
+		Timeout:   webhookTimeout,
+		Transport: &http.Transport{DialContext: dialer.DialContext},
+		// A redirect is a failure, not a way around the address check
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}}
+}
+
+// send posts the events to each webhook asking for them, in order, in the
+// background. A webhook that keeps failing is logged and skipped.
+func (ws *webhookSender) send(gameID string, hooks []Webhook, events []webhookPayload) {
+	hooks = slices.Clone(hooks)
+	for _, h := range hooks {
+		go func() {
+			for _, e := range events {
+				if !h.wants(e.Event) {
+					continue
+				}
+				if err := ws.deliver(h, e); err != nil {
+					log.Printf("webhook %s of game %s, %s event: %v", h.ID, gameID, e.Event, err)
+				}
+			}
+		}()
+	}
+}
+
+// deliver posts e to h, signed, retrying failures.
+func (ws *webhookSender) deliver(h Webhook, e webhookPayload) error {
+	body, _ := json.Marshal(e)
+	mac := hmac.New(sha256.New, []byte(h.Secret))
+	mac.Write(body)
+	signature := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	var err error
+	wait := time.Second
+	for attempt := 1; ; attempt++ {
+		err = ws.post(h.URL, body, e, signature)
+		if err == nil || attempt == webhookAttempts {
+			return err
+		}
+		time.Sleep(wait)
+		wait *= 2
+	}
+}
+
+// post makes one attempt at delivering e, as body, to url.
+func (ws *webhookSender) post(url string, body []byte, e webhookPayload, signature string) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "rigurd-webhooks")
+	req.Header.Set("X-Rigurd-Event", e.Event)
+	req.Header.Set("X-Rigurd-Delivery", e.Delivery)
+	req.Header.Set("X-Rigurd-Signature", signature)
+	resp, err := ws.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("answered %s", resp.Status)
+	}
+	return nil
+}
+
+// createdBy reports whether the game was created, or imported, by the
+// session, as its audit log records.
+func (s *Server) createdBy(g *Game, session string) bool {
+	for _, action := range []string{"create", "import"} {
+		events, err := s.games.store.AuditLog(auditFilter{Game: g.ID, Action: action}, 1)
+		if err != nil {
+			log.Printf("reading audit log of game %s: %v", g.ID, err)
+			return false
+		}
+		if len(events) > 0 {
+			return events[0].Session != "" && events[0].Session == session
+		}
+	}
+	return false
+}
+
+// requireCreator answers 403 Forbidden unless the request is from the
+// game's creator, or an admin's.
+func (s *Server) requireCreator(w http.ResponseWriter, r *http.Request, g *Game) bool {
+	if !s.isAdmin(r) && !s.createdBy(g, sessionOf(r)) {
+		writeAPIError(w, http.StatusForbidden, codeForbidden, "Only the game's creator can manage its webhooks")
+		return false
+	}
+	return true
+}
+
+// parseWebhookURL checks the URL of a webhook is an absolute http or https
+// one.
+func parseWebhookURL(raw string) (string, error) {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", errors.New("url must be an absolute http or https URL")
+	}
+	return u.String(), nil
+}
+
+// parseWebhookEvents reads a comma-separated list of events, all of them
+// when it is empty.
+func parseWebhookEvents(list string) ([]string, error) {
+	events := splitList(list)
+	for _, e := range events {
+		if !slices.Contains(webhookEventNames, e) {
+			return nil, fmt.Errorf("unknown event %s; give some of %s", e, strings.Join(webhookEventNames, ", "))
+		}
+	}
+	return events, nil
+}
+
+// handleAPIWebhooks lists the game's webhooks on GET, and on POST adds one
+// posting to url= the events= listed, all by default. The secret its
+// payloads are signed with is returned then only, unless given as secret=.
+func (s *Server) handleAPIWebhooks(w http.ResponseWriter, r *http.Request, g *Game) {
+	if !requireAPIMethod(w, r, http.MethodGet, http.MethodPost) {
+		return
+	}
+	if !s.requireCreator(w, r, g) {
+		return
+	}
+	if r.Method == http.MethodGet {
+		hooks := []apiWebhook{}
+		g.do(func() {
+			for _, h := range g.Settings.Webhooks {
+				hooks = append(hooks, newAPIWebhook(h))
+			}
+		})
+		writeJSON(w, hooks)
+		return
+	}
+
+	fields, ok := apiFields(w, r)
+	if !ok {
+		return
+	}
+	target, err := parseWebhookURL(fields["url"])
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+	events, err := parseWebhookEvents(fields["events"])
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+	secret := fields["secret"]
+	if secret == "" {
+		b := make([]byte, 24)
+		rand.Read(b)
+		secret = hex.EncodeToString(b)
+	}
+	h := Webhook{ID: newGameID(), URL: target, Secret: secret, Events: events}
+
+	g.update(func() {
+		if len(g.Settings.Webhooks) >= maxWebhooks {
+			writeAPIError(w, http.StatusConflict, codeConflict, fmt.Sprintf("a game has at most %d webhooks", maxWebhooks))
+			return
+		}
+		g.Settings.Webhooks = append(g.Settings.Webhooks, h)
+		s.audit(r, g, "add-webhook", target)
+		w.Header().Set("Location", "/api/v1/games/"+g.ID+"/webhooks/"+h.ID)
+		writeAPIStatus(w, http.StatusCreated, struct {
+			apiWebhook
+			Secret string `json:"secret"`
+		}{newAPIWebhook(h), secret})
+	})
+}
+
+// handleAPIDeleteWebhook removes the webhook with the ID in the path from
+// the game.
+func (s *Server) handleAPIDeleteWebhook(w http.ResponseWriter, r *http.Request, g *Game) {
+	if !requireAPIMethod(w, r, http.MethodDelete) {
+		return
+	}
+	if !s.requireCreator(w, r, g) {
+		return
+	}
+	id := r.PathValue("hook")
+	g.update(func() {
+		i := slices.IndexFunc(g.Settings.Webhooks, func(h Webhook) bool { return h.ID == id })
+		if i < 0 {
+			writeAPIError(w, http.StatusNotFound, codeNotFound, "unknown webhook "+id)
+			return
+		}
+		s.audit(r, g, "remove-webhook", g.Settings.Webhooks[i].URL)
+		g.Settings.Webhooks = slices.Delete(g.Settings.Webhooks, i, i+1)
+		w.WriteHeader(http.StatusNoContent)
+	})
+}